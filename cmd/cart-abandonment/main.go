@@ -0,0 +1,103 @@
+// Report abandoned carts
+//
+// The command runs as a scheduled AWS Lambda function when it is started by AWS Lambda.
+// Otherwise it checks the carts once and exits, so it can run as a cron job in a container.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/getsentry/sentry-go"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/retgits/acme-serverless-cart/internal/datastore/dynamodb"
+	"github.com/retgits/acme-serverless-cart/internal/datastore/mongodb"
+	"github.com/retgits/acme-serverless-cart/internal/events/publishers"
+	wflambda "github.com/wavefronthq/wavefront-lambda-go"
+)
+
+// handler handles the scheduled CloudWatch events and returns an error if anything goes wrong.
+func handler(event events.CloudWatchEvent) error {
+	return run()
+}
+
+// run reports the carts that have been idle for longer than ABANDONED_AFTER
+func run() error {
+	// Initiialize a connection to Sentry to capture errors and traces
+	sentry.Init(sentry.ClientOptions{
+		Dsn: os.Getenv("SENTRY_DSN"),
+		Transport: &sentry.HTTPSyncTransport{
+			Timeout: time.Second * 3,
+		},
+		ServerName:  os.Getenv("FUNCTION_NAME"),
+		Release:     os.Getenv("VERSION"),
+		Environment: os.Getenv("STAGE"),
+	})
+
+	idleFor := time.Hour * 24
+	if len(os.Getenv("ABANDONED_AFTER")) > 0 {
+		d, err := time.ParseDuration(os.Getenv("ABANDONED_AFTER"))
+		if err != nil {
+			return handleError("parsing ABANDONED_AFTER", err)
+		}
+		idleFor = d
+	}
+
+	// The carts are read from the same datastore the Cart service stores them in
+	var db datastore.Manager
+	switch store := os.Getenv("DATASTORE"); store {
+	case "", "dynamodb":
+		db = dynamodb.New()
+	case "mongodb":
+		db = mongodb.New()
+	default:
+		return handleError("creating datastore", fmt.Errorf("unknown DATASTORE %s", store))
+	}
+
+	publisher, err := publishers.FromEnv()
+	if err != nil {
+		return handleError("creating event publisher", err)
+	}
+
+	reported, err := cart.ReportAbandoned(db, cart.AbandonmentOptions{
+		Publisher: publisher,
+		Source:    "ReportAbandonedCarts",
+		IdleFor:   idleFor,
+	})
+
+	log.Printf("reported %d abandoned carts", len(reported))
+
+	if err != nil {
+		return handleError("reporting abandoned carts", err)
+	}
+
+	return nil
+}
+
+// handleError takes the activity where the error occured and the error object and sends a message to sentry.
+// An error with the same message is returned so it can be thrown.
+func handleError(area string, err error) error {
+	sentry.CaptureException(fmt.Errorf("error %s: %s", area, err.Error()))
+	msg := fmt.Sprintf("error %s: %s", area, err.Error())
+	log.Println(msg)
+	return errors.New(msg)
+}
+
+// The main method is executed by AWS Lambda and points to the handler. Outside
+// of AWS Lambda, the carts are checked once.
+func main() {
+	if len(os.Getenv("AWS_LAMBDA_FUNCTION_NAME")) > 0 {
+		lambda.Start(wflambda.Wrapper(handler))
+		return
+	}
+
+	if err := run(); err != nil {
+		os.Exit(1)
+	}
+}
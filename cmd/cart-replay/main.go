@@ -0,0 +1,67 @@
+// Replay a cart from the event log
+//
+// The command rebuilds a cart as it was at a point in time from the event log selected by
+// CART_EVENT_LOG, and prints the items together with the events that were replayed. It is
+// meant for support investigations, for example to see what was in a cart when a customer
+// reported a problem.
+//
+//	cart-replay -user 123 -cart work -at 2020-05-18T14:00:00Z
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/retgits/acme-serverless-cart/internal/eventlog"
+	"github.com/retgits/acme-serverless-cart/internal/eventlog/logs"
+)
+
+func main() {
+	userID := flag.String("user", "", "the user the cart belongs to")
+	cartID := flag.String("cart", "", "the ID of the cart, or empty for the default cart")
+	at := flag.String("at", "", "the time to rebuild the cart at, in RFC 3339 format (default now)")
+	flag.Parse()
+
+	if len(*userID) == 0 {
+		fmt.Fprintln(os.Stderr, "-user is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	when := time.Now()
+	if len(*at) > 0 {
+		t, err := time.Parse(time.RFC3339, *at)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -at: %s\n", err.Error())
+			os.Exit(2)
+		}
+		when = t
+	}
+
+	l, err := logs.FromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error opening event log: %s\n", err.Error())
+		os.Exit(1)
+	}
+	if l == nil {
+		fmt.Fprintln(os.Stderr, "CART_EVENT_LOG must be set to replay carts")
+		os.Exit(1)
+	}
+
+	p, err := eventlog.Replay(l, datastore.CartKey(*userID, *cartID), when)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error replaying cart: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	payload, err := p.Marshal()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error marshalling cart: %s\n", err.Error())
+		os.Exit(1)
+	}
+
+	fmt.Println(string(payload))
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"errors"
 	"net/http"
 
 	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/retgits/acme-serverless-cart/internal/inventory"
 	"github.com/valyala/fasthttp"
 )
 
@@ -11,17 +15,70 @@ import (
 func AddItemToCart(ctx *fasthttp.RequestCtx) {
 	// Create the key attributes
 	userID := ctx.UserValue("userid").(string)
+	key, err := cartKey(ctx)
+	if err != nil {
+		ErrorHandler(ctx, "AddItemToCart", "CartKey", err)
+		return
+	}
+
+	// Check that the caller is allowed to change the cart
+	err = authorize(ctx, key, datastore.RoleEditor)
+	if err != nil {
+		ErrorHandler(ctx, "AddItemToCart", "Authorize", err)
+		return
+	}
+
+	// Check that the cart didn't change since the caller read it
+	version, err := ifMatch(ctx, key)
+	if err != nil {
+		ErrorHandler(ctx, "AddItemToCart", "IfMatch", err)
+		return
+	}
 
 	// Unmarshal the item
-	item, err := acmeserverless.UnmarshalItem(ctx.Request.Body())
+	item, err := datastore.UnmarshalItem(ctx.Request.Body())
 	if err != nil {
 		ErrorHandler(ctx, "AddItemToCart", "UnmarshalItem", err)
 		return
 	}
 
+	// Validate the item against the catalog, bundles get their price and components
+	item, err = cart.ValidateItem(catalogClient, bundles, item)
+	if err != nil {
+		ErrorHandler(ctx, "AddItemToCart", "Validate", err)
+		return
+	}
+
+	// Record who added the item
+	item.AddedBy = caller(ctx)
+
+	// Get the items that are in the cart already
+	cartItems, err := db.GetItems(key)
+	if err != nil && !errors.Is(err, datastore.ErrCartNotFound) {
+		ErrorHandler(ctx, "AddItemToCart", "GetItems", err)
+		return
+	}
+
+	// Check that the cart still follows the business rules with the item
+	err = cartRules.Check(append(cartItems, item), region(ctx))
+	if err != nil {
+		ErrorHandler(ctx, "AddItemToCart", "CheckRules", err)
+		return
+	}
+
+	// Reserve stock for the item
+	if inv != nil {
+		err = inventory.Sync(inv, key, cartItems, append(cartItems, item))
+		if err != nil {
+			ErrorHandler(ctx, "AddItemToCart", "Reserve", err)
+			return
+		}
+	}
+
 	// Add the item
-	err = db.AddItem(userID, item)
+	err = cart.AddItem(db, key, item, version)
 	if err != nil {
+		revertHolds(key, cartItems, append(cartItems, item))
 		ErrorHandler(ctx, "AddItemToCart", "AddItem", err)
 		return
 	}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/retgits/acme-serverless-cart/internal/inventory"
+	"github.com/valyala/fasthttp"
+)
+
+// CheckoutCart hands the items in a cart off to the order service by sending a
+// CartCheckedOut event, and clears the cart once the event is sent
+func CheckoutCart(ctx *fasthttp.RequestCtx) {
+	// Create the key attributes
+	key, err := cartKey(ctx)
+	if err != nil {
+		ErrorHandler(ctx, "CheckoutCart", "CartKey", err)
+		return
+	}
+
+	// Only the owner can check out a cart
+	err = authorize(ctx, key, datastore.RoleOwner)
+	if err != nil {
+		ErrorHandler(ctx, "CheckoutCart", "Authorize", err)
+		return
+	}
+
+	// Check that the cart didn't change since the caller read it
+	version, err := ifMatch(ctx, key)
+	if err != nil {
+		ErrorHandler(ctx, "CheckoutCart", "IfMatch", err)
+		return
+	}
+
+	details, err := cart.Checkout(db, key, cart.CheckoutOptions{
+		Catalog:   catalogClient,
+		Publisher: publisher,
+		Source:    "CheckoutCart",
+		Archive:   checkoutArchive,
+		LockTTL:   checkoutLockTTL,
+		Version:   version,
+	})
+
+	// When prices have changed, the cart is updated and the changes are returned
+	// so the user can review them before checking out again
+	var pce *cart.PricesChangedError
+	if errors.As(err, &pce) {
+		payload, err := pce.Result.Marshal()
+		if err != nil {
+			ErrorHandler(ctx, "CheckoutCart", "Marshal", err)
+			return
+		}
+
+		ctx.SetStatusCode(http.StatusConflict)
+		ctx.Write(payload)
+		return
+	}
+
+	if err != nil {
+		ErrorHandler(ctx, "CheckoutCart", "Checkout", err)
+		return
+	}
+
+	// The cart is empty now, so its holds are released like when the cart is cleared.
+	// The checkout already succeeded, and holds expire anyway, so errors are ignored.
+	if inv != nil {
+		inventory.Sync(inv, key, details.Items, nil)
+	}
+
+	payload, err := details.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "CheckoutCart", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
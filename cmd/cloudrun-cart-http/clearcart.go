@@ -1,22 +1,64 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
 
+	"github.com/getsentry/sentry-go"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/retgits/acme-serverless-cart/internal/inventory"
 	"github.com/valyala/fasthttp"
 )
 
 // ClearCart removes all items from a cart
 func ClearCart(ctx *fasthttp.RequestCtx) {
 	// Create the key attributes
-	userID := ctx.UserValue("userid").(string)
+	key, err := cartKey(ctx)
+	if err != nil {
+		ErrorHandler(ctx, "ClearCart", "CartKey", err)
+		return
+	}
+
+	// Check that the caller is allowed to change the cart
+	err = authorize(ctx, key, datastore.RoleEditor)
+	if err != nil {
+		ErrorHandler(ctx, "ClearCart", "Authorize", err)
+		return
+	}
+
+	// Check that the cart didn't change since the caller read it
+	version, err := ifMatch(ctx, key)
+	if err != nil {
+		ErrorHandler(ctx, "ClearCart", "IfMatch", err)
+		return
+	}
+
+	// Get the items so the reserved stock can be released
+	var cartItems datastore.CartItems
+	if inv != nil {
+		cartItems, err = db.GetItems(key)
+		if err != nil {
+			ErrorHandler(ctx, "ClearCart", "GetItems", err)
+			return
+		}
+	}
 
 	// Remove the cart
-	err := db.ClearCart(userID)
+	err = cart.ClearCart(db, key, version)
 	if err != nil {
 		ErrorHandler(ctx, "ClearCart", "ClearCart", err)
 		return
 	}
 
+	// Release the reserved stock. The cart has been cleared at this point,
+	// so failures are only reported since the holds will expire anyway.
+	if inv != nil {
+		err = inventory.Sync(inv, key, cartItems, nil)
+		if err != nil {
+			sentry.CaptureException(fmt.Errorf("error in ClearCart::Release %s", err.Error()))
+		}
+	}
+
 	ctx.SetStatusCode(http.StatusOK)
 }
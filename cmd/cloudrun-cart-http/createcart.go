@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/valyala/fasthttp"
+)
+
+// CreateCart creates a new named cart for a user
+func CreateCart(ctx *fasthttp.RequestCtx) {
+	// Create the key attributes
+	userID := ctx.UserValue("userid").(string)
+	key, err := cartKey(ctx)
+	if err != nil {
+		ErrorHandler(ctx, "CreateCart", "CartKey", err)
+		return
+	}
+
+	// Only the user can create carts for themselves
+	err = authorize(ctx, key, datastore.RoleOwner)
+	if err != nil {
+		ErrorHandler(ctx, "CreateCart", "Authorize", err)
+		return
+	}
+
+	req, err := cart.UnmarshalCartRequest(ctx.Request.Body())
+	if err != nil {
+		ErrorHandler(ctx, "CreateCart", "UnmarshalCartRequest", err)
+		return
+	}
+
+	cartID, err := cart.NewCartID(req.Name)
+	if err != nil {
+		ErrorHandler(ctx, "CreateCart", "NewCartID", err)
+		return
+	}
+
+	ci := datastore.CartInfo{
+		ID:   cartID,
+		Name: req.Name,
+	}
+
+	err = db.CreateCart(userID, ci)
+	if err != nil {
+		ErrorHandler(ctx, "CreateCart", "CreateCart", err)
+		return
+	}
+
+	payload, err := json.Marshal(ci)
+	if err != nil {
+		ErrorHandler(ctx, "CreateCart", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/valyala/fasthttp"
+)
+
+// CreateGuestCart creates an empty cart for an anonymous user
+func CreateGuestCart(ctx *fasthttp.RequestCtx) {
+	guestID, err := cart.NewGuestID()
+	if err != nil {
+		ErrorHandler(ctx, "CreateGuestCart", "NewGuestID", err)
+		return
+	}
+
+	err = db.StoreItems(guestID, make(datastore.CartItems, 0))
+	if err != nil {
+		ErrorHandler(ctx, "CreateGuestCart", "StoreItems", err)
+		return
+	}
+
+	res := acmeserverless.UserIDResponse{
+		UserID: guestID,
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "CreateGuestCart", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/getsentry/sentry-go"
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/retgits/acme-serverless-cart/internal/inventory"
+	"github.com/valyala/fasthttp"
+)
+
+// DeleteCart removes a named cart of a user
+func DeleteCart(ctx *fasthttp.RequestCtx) {
+	// Create the key attributes
+	userID := ctx.UserValue("userid").(string)
+	key, err := cartKey(ctx)
+	if err != nil {
+		ErrorHandler(ctx, "DeleteCart", "CartKey", err)
+		return
+	}
+
+	// Only the owner can delete a cart
+	err = authorize(ctx, key, datastore.RoleOwner)
+	if err != nil {
+		ErrorHandler(ctx, "DeleteCart", "Authorize", err)
+		return
+	}
+
+	cartID := string(ctx.QueryArgs().Peek("cart"))
+	if len(cartID) == 0 {
+		ErrorHandler(ctx, "DeleteCart", "CartID", fmt.Errorf("%w: the cart query parameter is required", datastore.ErrInvalidRequest))
+		return
+	}
+
+	// Get the items so the reserved stock can be released
+	var cartItems datastore.CartItems
+	if inv != nil {
+		cartItems, err = db.GetItems(key)
+		if err != nil {
+			ErrorHandler(ctx, "DeleteCart", "GetItems", err)
+			return
+		}
+	}
+
+	err = db.DeleteCart(userID, cartID)
+	if err != nil {
+		ErrorHandler(ctx, "DeleteCart", "DeleteCart", err)
+		return
+	}
+
+	// Release the reserved stock. The cart has been deleted at this point,
+	// so failures are only reported since the holds will expire anyway.
+	if inv != nil {
+		err = inventory.Sync(inv, key, cartItems, nil)
+		if err != nil {
+			sentry.CaptureException(fmt.Errorf("error in DeleteCart::Release %s", err.Error()))
+		}
+	}
+
+	res := acmeserverless.UserIDResponse{
+		UserID: userID,
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "DeleteCart", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/valyala/fasthttp"
+)
+
+// DiffCart returns the changes between two versions of a cart
+func DiffCart(ctx *fasthttp.RequestCtx) {
+	// Create the key attributes
+	key, err := cartKey(ctx)
+	if err != nil {
+		ErrorHandler(ctx, "DiffCart", "CartKey", err)
+		return
+	}
+
+	// Check that the caller is allowed to see the cart
+	err = authorize(ctx, key, datastore.RoleViewer)
+	if err != nil {
+		ErrorHandler(ctx, "DiffCart", "Authorize", err)
+		return
+	}
+
+	res, err := cart.DiffVersions(db, key, string(ctx.QueryArgs().Peek("from")), string(ctx.QueryArgs().Peek("to")))
+	if err != nil {
+		ErrorHandler(ctx, "DiffCart", "DiffVersions", err)
+		return
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "DiffCart", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
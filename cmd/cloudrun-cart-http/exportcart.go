@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/valyala/fasthttp"
+)
+
+// ExportCart returns everything that is kept about a user: their carts, with the items
+// and collaborators, and their saved-for-later list
+func ExportCart(ctx *fasthttp.RequestCtx) {
+	// Create the key attributes
+	userID := ctx.UserValue("userid").(string)
+
+	// Only the user, or an admin, can export the carts of the user
+	err := authorize(ctx, userID, datastore.RoleOwner)
+	if err != nil {
+		ErrorHandler(ctx, "ExportCart", "Authorize", err)
+		return
+	}
+
+	res, err := cart.Export(db, userID)
+	if err != nil {
+		ErrorHandler(ctx, "ExportCart", "Export", err)
+		return
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "ExportCart", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
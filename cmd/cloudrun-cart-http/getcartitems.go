@@ -3,7 +3,8 @@ package main
 import (
 	"net/http"
 
-	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
 	"github.com/valyala/fasthttp"
 )
 
@@ -11,21 +12,53 @@ import (
 func GetCartItems(ctx *fasthttp.RequestCtx) {
 	// Create the key attributes
 	userID := ctx.UserValue("userid").(string)
+	key, err := cartKey(ctx)
+	if err != nil {
+		ErrorHandler(ctx, "GetCartItems", "CartKey", err)
+		return
+	}
+
+	// Check that the caller is allowed to see the cart
+	err = authorize(ctx, key, datastore.RoleViewer)
+	if err != nil {
+		ErrorHandler(ctx, "GetCartItems", "Authorize", err)
+		return
+	}
+
+	// The version is read before the items, so the tag never has a newer version than the items
+	version, err := cart.CurrentVersion(db, key)
+	if err != nil {
+		ErrorHandler(ctx, "GetCartItems", "CurrentVersion", err)
+		return
+	}
 
-	items, err := db.GetItems(userID)
+	items, err := db.GetItems(key)
 	if err != nil {
 		ErrorHandler(ctx, "GetCartItems", "GetItem", err)
 		return
 	}
 
-	ct := acmeserverless.Cart{
+	// Tell the caller when the cart they already have is unchanged
+	etag, err := cart.ETag(version, items)
+	if err != nil {
+		ErrorHandler(ctx, "GetCartItems", "ETag", err)
+		return
+	}
+
+	ctx.Response.Header.Set(cart.ETagHeader, etag)
+	if cart.MatchesETag(string(ctx.Request.Header.Peek(cart.IfNoneMatchHeader)), etag, true) {
+		ctx.SetStatusCode(http.StatusNotModified)
+		return
+	}
+
+	ct := datastore.Cart{
 		Items:  items,
 		UserID: userID,
 	}
 
 	payload, err := ct.Marshal()
 	if err != nil {
-		ErrorHandler(ctx, "ModifyCartItem", "Marshal", err)
+		ErrorHandler(ctx, "GetCartItems", "Marshal", err)
 		return
 	}
 
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/valyala/fasthttp"
+)
+
+// GetCartMembers returns the owner and the collaborators of a cart
+func GetCartMembers(ctx *fasthttp.RequestCtx) {
+	// Create the key attributes
+	key, err := cartKey(ctx)
+	if err != nil {
+		ErrorHandler(ctx, "GetCartMembers", "CartKey", err)
+		return
+	}
+
+	// Check that the caller is allowed to see the cart
+	err = authorize(ctx, key, datastore.RoleViewer)
+	if err != nil {
+		ErrorHandler(ctx, "GetCartMembers", "Authorize", err)
+		return
+	}
+
+	members, err := db.Members(key)
+	if err != nil {
+		ErrorHandler(ctx, "GetCartMembers", "Members", err)
+		return
+	}
+
+	owner, cartID := datastore.SplitCartKey(key)
+
+	res := cart.MembersResponse{
+		Owner:   owner,
+		Cart:    cartID,
+		Role:    datastore.RoleOwner,
+		Members: members,
+	}
+
+	for _, m := range members {
+		if m.UserID == caller(ctx) {
+			res.Role = m.Role
+		}
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "GetCartMembers", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
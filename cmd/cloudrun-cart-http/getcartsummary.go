@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/valyala/fasthttp"
+)
+
+// GetCartSummary gets the lines, totals, and discounts of the cart of a user
+func GetCartSummary(ctx *fasthttp.RequestCtx) {
+	// Create the key attributes
+	key, err := cartKey(ctx)
+	if err != nil {
+		ErrorHandler(ctx, "GetCartSummary", "CartKey", err)
+		return
+	}
+
+	// Check that the caller is allowed to see the cart
+	err = authorize(ctx, key, datastore.RoleViewer)
+	if err != nil {
+		ErrorHandler(ctx, "GetCartSummary", "Authorize", err)
+		return
+	}
+
+	policy, err := datastore.ParseItemCountPolicy(os.Getenv("ITEM_COUNT_POLICY"))
+	if err != nil {
+		ErrorHandler(ctx, "GetCartSummary", "ParseItemCountPolicy", err)
+		return
+	}
+
+	items, err := db.GetItems(key)
+	if err != nil {
+		ErrorHandler(ctx, "GetCartSummary", "GetItems", err)
+		return
+	}
+
+	res := cart.Summarize(key, items, policy)
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "GetCartSummary", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
 	"github.com/valyala/fasthttp"
 )
 
@@ -11,8 +12,20 @@ import (
 func GetCartValue(ctx *fasthttp.RequestCtx) {
 	// Create the key attributes
 	userID := ctx.UserValue("userid").(string)
+	key, err := cartKey(ctx)
+	if err != nil {
+		ErrorHandler(ctx, "GetCartValue", "CartKey", err)
+		return
+	}
+
+	// Check that the caller is allowed to see the cart
+	err = authorize(ctx, key, datastore.RoleViewer)
+	if err != nil {
+		ErrorHandler(ctx, "GetCartValue", "Authorize", err)
+		return
+	}
 
-	value, err := db.ValueInCart(userID)
+	value, err := db.ValueInCart(key)
 	if err != nil {
 		ErrorHandler(ctx, "GetCartValue", "ValueInCart", err)
 		return
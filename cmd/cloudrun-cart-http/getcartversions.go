@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/valyala/fasthttp"
+)
+
+// GetCartVersions returns the versions of a cart that are kept, newest first
+func GetCartVersions(ctx *fasthttp.RequestCtx) {
+	// Create the key attributes
+	key, err := cartKey(ctx)
+	if err != nil {
+		ErrorHandler(ctx, "GetCartVersions", "CartKey", err)
+		return
+	}
+
+	// Check that the caller is allowed to see the cart
+	err = authorize(ctx, key, datastore.RoleViewer)
+	if err != nil {
+		ErrorHandler(ctx, "GetCartVersions", "Authorize", err)
+		return
+	}
+
+	versions, err := db.Versions(key)
+	if err != nil {
+		ErrorHandler(ctx, "GetCartVersions", "Versions", err)
+		return
+	}
+
+	userID, cartID := datastore.SplitCartKey(key)
+	res := cart.VersionsResponse{
+		UserID:   userID,
+		Cart:     cartID,
+		Versions: versions,
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "GetCartVersions", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/valyala/fasthttp"
+)
+
+// GetSavedItems returns the items a user saved for later
+func GetSavedItems(ctx *fasthttp.RequestCtx) {
+	// Create the key attributes
+	userID := ctx.UserValue("userid").(string)
+
+	// Only the user can see their saved-for-later list
+	err := authorize(ctx, userID, datastore.RoleOwner)
+	if err != nil {
+		ErrorHandler(ctx, "GetSavedItems", "Authorize", err)
+		return
+	}
+
+	items, err := db.GetSavedItems(userID)
+	if err != nil {
+		ErrorHandler(ctx, "GetSavedItems", "GetSavedItems", err)
+		return
+	}
+
+	res := cart.SavedResponse{
+		Saved:  items,
+		UserID: userID,
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "GetSavedItems", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
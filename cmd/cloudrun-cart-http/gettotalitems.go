@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
 	"github.com/valyala/fasthttp"
 )
 
@@ -11,8 +12,20 @@ import (
 func GetTotalItems(ctx *fasthttp.RequestCtx) {
 	// Create the key attributes
 	userID := ctx.UserValue("userid").(string)
+	key, err := cartKey(ctx)
+	if err != nil {
+		ErrorHandler(ctx, "GetTotalItems", "CartKey", err)
+		return
+	}
+
+	// Check that the caller is allowed to see the cart
+	err = authorize(ctx, key, datastore.RoleViewer)
+	if err != nil {
+		ErrorHandler(ctx, "GetTotalItems", "Authorize", err)
+		return
+	}
 
-	items, err := db.ItemsInCart(userID)
+	items, err := db.ItemsInCart(key)
 	if err != nil {
 		ErrorHandler(ctx, "GetTotalItems", "ItemsInCart", err)
 		return
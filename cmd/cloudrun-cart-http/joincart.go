@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/valyala/fasthttp"
+)
+
+// JoinCart accepts an invite and makes the user a collaborator on the cart of another user
+func JoinCart(ctx *fasthttp.RequestCtx) {
+	// Create the key attributes
+	userID := ctx.UserValue("userid").(string)
+
+	// Users can only accept invites for themselves
+	err := authorize(ctx, userID, datastore.RoleOwner)
+	if err != nil {
+		ErrorHandler(ctx, "JoinCart", "Authorize", err)
+		return
+	}
+
+	req, err := cart.UnmarshalJoinRequest(ctx.Request.Body())
+	if err != nil {
+		ErrorHandler(ctx, "JoinCart", "UnmarshalJoinRequest", err)
+		return
+	}
+
+	invite, err := cart.ParseInviteToken(inviteSecret, req.Token)
+	if err != nil {
+		ErrorHandler(ctx, "JoinCart", "ParseInviteToken", err)
+		return
+	}
+
+	owner, cartID := datastore.SplitCartKey(invite.Key)
+	if owner == userID {
+		ErrorHandler(ctx, "JoinCart", "Owner", fmt.Errorf("%w: user %s already owns cart %s", datastore.ErrInvalidRequest, userID, cartID))
+		return
+	}
+
+	err = db.SetMember(invite.Key, datastore.Member{
+		UserID: userID,
+		Role:   invite.Role,
+	})
+	if err != nil {
+		ErrorHandler(ctx, "JoinCart", "SetMember", err)
+		return
+	}
+
+	res := cart.MembersResponse{
+		Owner: owner,
+		Cart:  cartID,
+		Role:  invite.Role,
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "JoinCart", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
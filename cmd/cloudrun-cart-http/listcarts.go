@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/valyala/fasthttp"
+)
+
+// ListCarts lists the default cart and the named carts of a user
+func ListCarts(ctx *fasthttp.RequestCtx) {
+	// Create the key attributes
+	userID := ctx.UserValue("userid").(string)
+
+	// Only the user can see the list of their carts
+	err := authorize(ctx, userID, datastore.RoleOwner)
+	if err != nil {
+		ErrorHandler(ctx, "ListCarts", "Authorize", err)
+		return
+	}
+
+	carts, err := db.ListCarts(userID)
+	if err != nil {
+		ErrorHandler(ctx, "ListCarts", "ListCarts", err)
+		return
+	}
+
+	res := cart.CartsResponse{
+		Carts:  carts,
+		UserID: userID,
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "ListCarts", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
@@ -1,17 +1,38 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/fasthttp/router"
 	"github.com/getsentry/sentry-go"
 	sentryfasthttp "github.com/getsentry/sentry-go/fasthttp"
+	"github.com/retgits/acme-serverless-cart/internal/audit"
+	"github.com/retgits/acme-serverless-cart/internal/auth"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/catalog"
+	"github.com/retgits/acme-serverless-cart/internal/catalog/httpclient"
 	"github.com/retgits/acme-serverless-cart/internal/datastore"
 	"github.com/retgits/acme-serverless-cart/internal/datastore/mongodb"
+	"github.com/retgits/acme-serverless-cart/internal/eventlog/logs"
+	"github.com/retgits/acme-serverless-cart/internal/events"
+	"github.com/retgits/acme-serverless-cart/internal/events/publishers"
+	"github.com/retgits/acme-serverless-cart/internal/idempotency"
+	"github.com/retgits/acme-serverless-cart/internal/inventory"
+	"github.com/retgits/acme-serverless-cart/internal/inventory/sources"
+	"github.com/retgits/acme-serverless-cart/internal/openapi"
+	"github.com/retgits/acme-serverless-cart/internal/problem"
+	"github.com/retgits/acme-serverless-cart/internal/ratelimit"
+	ratelimitdynamodb "github.com/retgits/acme-serverless-cart/internal/ratelimit/dynamodb"
+	ratelimitmemory "github.com/retgits/acme-serverless-cart/internal/ratelimit/memory"
+	"github.com/retgits/acme-serverless-cart/internal/rules"
 	gcrwavefront "github.com/retgits/gcr-wavefront"
 	"github.com/valyala/fasthttp"
 )
@@ -21,24 +42,421 @@ const (
 )
 
 var (
-	db datastore.Manager
+	db            datastore.Manager
+	catalogClient catalog.CatalogClient
+	inv           inventory.Inventory
+	cartRules     *rules.Rules
+	bundles       cart.Bundles
+	inviteSecret  []byte
+	inviteTTL     time.Duration
+
+	publisher       events.Publisher
+	checkoutArchive bool
+	checkoutLockTTL time.Duration
+
+	idempotencyTTL time.Duration
+
+	apiSpec       *openapi.Spec
+	authenticator auth.Authenticator
+	auditRecorder audit.Recorder
+
+	userLimiter    ratelimit.Limiter
+	addressLimiter ratelimit.Limiter
+	trustedProxies int
+)
+
+const (
+	// adminAccess is the user value that is set on requests that use the rights of an admin
+	adminAccess = "adminAccess"
+
+	// authenticatedUser is the user value with the ID of the user that made an authenticated request
+	authenticatedUser = "authenticatedUser"
 )
 
 // CORSHandler sets CORS headers for the preflight request
 func CORSHandler(ctx *fasthttp.RequestCtx) {
 	ctx.Response.Header.Add("Access-Control-Allow-Credentials", "true")
-	ctx.Response.Header.Add("Access-Control-Allow-Headers", "Authorization")
-	ctx.Response.Header.Add("Access-Control-Allow-Methods", "GET, POST")
+	ctx.Response.Header.Add("Access-Control-Allow-Headers", "Authorization, X-User-ID, X-Region, If-Match, If-None-Match, Idempotency-Key")
+	ctx.Response.Header.Add("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE")
 	ctx.Response.Header.Add("Access-Control-Allow-Origin", "*")
 	ctx.Response.Header.Add("Access-Control-Max-Age", "3600")
 	ctx.Response.SetStatusCode(http.StatusNoContent)
 }
 
 // ErrorHandler takes the activity where the error occured and the error object and sends a message to sentry.
+// The error is returned to the client as problem details.
 func ErrorHandler(ctx *fasthttp.RequestCtx, function string, method string, err error) {
-	sentry.CaptureException(fmt.Errorf("error in %s::%s %s", function, method, err.Error()))
-	ctx.SetStatusCode(http.StatusBadRequest)
-	ctx.SetBodyString(err.Error())
+	id := requestID(ctx)
+	sentry.CaptureException(fmt.Errorf("error in %s::%s (request %s) %s", function, method, id, err.Error()))
+
+	p := problem.FromError(err, id)
+	payload, err := p.Marshal()
+	if err != nil {
+		ctx.SetStatusCode(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Response.Header.Set(cart.RequestIDHeader, id)
+	ctx.SetContentType(problem.ContentType)
+	ctx.SetStatusCode(p.Status)
+	ctx.Write(payload)
+}
+
+// requestID returns the ID of the request from the X-Request-ID header, or a new ID
+// when the header isn't set
+func requestID(ctx *fasthttp.RequestCtx) string {
+	if id := ctx.Request.Header.Peek(cart.RequestIDHeader); len(id) > 0 {
+		return string(id)
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatUint(ctx.ID(), 10)
+	}
+	return hex.EncodeToString(b)
+}
+
+// cartKey returns the key of the cart that the request is for, based on the
+// userid path parameter and the optional cart query parameter
+func cartKey(ctx *fasthttp.RequestCtx) (string, error) {
+	return cart.Key(ctx.UserValue("userid").(string), string(ctx.QueryArgs().Peek("cart")))
+}
+
+// pathItemID returns the itemid in the path, or an empty string on routes that don't have one
+func pathItemID(ctx *fasthttp.RequestCtx) string {
+	itemID, _ := ctx.UserValue("itemid").(string)
+	return itemID
+}
+
+// caller returns the ID of the user making the request. That is the user in the
+// X-User-ID header, or the user in the path when the header isn't set.
+func caller(ctx *fasthttp.RequestCtx) string {
+	if c := ctx.Request.Header.Peek(cart.CallerHeader); len(c) > 0 {
+		return string(c)
+	}
+	userID, _ := ctx.UserValue("userid").(string)
+	return userID
+}
+
+// clientIP returns the IP address of the client that made the request, taken from the
+// X-Forwarded-For header when the service runs behind RATE_LIMIT_TRUSTED_PROXIES proxies
+func clientIP(ctx *fasthttp.RequestCtx) string {
+	return ratelimit.ClientIP(ctx.RemoteIP().String(), string(ctx.Request.Header.Peek("X-Forwarded-For")), trustedProxies)
+}
+
+// region returns the region the order will be shipped to, from the X-Region header
+func region(ctx *fasthttp.RequestCtx) string {
+	return string(ctx.Request.Header.Peek(rules.RegionHeader))
+}
+
+// authorize checks that the user making the request has at least the role on the cart with
+// the key. Admins that use a support operation are allowed access to every cart.
+func authorize(ctx *fasthttp.RequestCtx, key string, role datastore.Role) error {
+	if admin, _ := ctx.UserValue(adminAccess).(bool); admin {
+		return nil
+	}
+	return cart.Authorize(db, key, caller(ctx), role)
+}
+
+// idempotent wraps a handler, so requests with an Idempotency-Key header are only handled once
+func idempotent(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		idempotencyKey := string(ctx.Request.Header.Peek(idempotency.Header))
+		if len(idempotencyKey) == 0 || !idempotency.Applies(string(ctx.Method())) {
+			h(ctx)
+			return
+		}
+
+		key := idempotency.Key(caller(ctx), clientIP(ctx), idempotencyKey)
+		fingerprint := idempotency.Fingerprint(string(ctx.Method()), string(ctx.Path()), string(ctx.QueryArgs().QueryString()), ctx.Request.Body())
+
+		res, err := idempotency.Begin(db, key, fingerprint, idempotencyTTL)
+		if err != nil {
+			ErrorHandler(ctx, "Idempotency", "Begin", err)
+			return
+		}
+
+		// The request was made before, so the response is returned again
+		if res != nil {
+			ctx.Response.Header.Set(idempotency.ReplayedHeader, "true")
+			ctx.SetContentType(res.ContentType)
+			ctx.SetStatusCode(res.StatusCode)
+			ctx.WriteString(res.Body)
+			return
+		}
+
+		h(ctx)
+
+		// The response was made already, so failing to store it only means a retry is handled again
+		err = idempotency.End(db, key, idempotency.Response{
+			StatusCode:  ctx.Response.StatusCode(),
+			ContentType: string(ctx.Response.Header.ContentType()),
+			Body:        string(ctx.Response.Body()),
+		})
+		if err != nil {
+			sentry.CaptureException(fmt.Errorf("error in Idempotency::End %s", err.Error()))
+		}
+	}
+}
+
+// authenticated wraps a handler, so requests are authenticated and checked before they
+// are handled. The ID of the authenticated user replaces the X-User-ID header of the request.
+func authenticated(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		// Keep the ID of the request, so the audit log and errors use the same ID
+		reqID := requestID(ctx)
+		ctx.Request.Header.Set(cart.RequestIDHeader, reqID)
+
+		route, _ := ctx.UserValue(router.MatchedRoutePathParam).(string)
+		userID, _ := ctx.UserValue("userid").(string)
+		r := auth.Request{
+			RequestID: reqID,
+			Method:    string(ctx.Method()),
+			Route:     route,
+			Path:      string(ctx.Path()),
+			UserID:    userID,
+			Cart:      string(ctx.QueryArgs().Peek("cart")),
+			Caller:    string(ctx.Request.Header.Peek(cart.CallerHeader)),
+		}
+
+		if authenticator == nil {
+			if err := auth.CheckUnauthenticated(auditRecorder, r); err != nil {
+				ErrorHandler(ctx, "Authenticate", "CheckUnauthenticated", err)
+				return
+			}
+			h(ctx)
+			return
+		}
+
+		// Only the authenticated user can be the caller
+		ctx.Request.Header.Del(cart.CallerHeader)
+
+		if auth.Anonymous(r) {
+			h(ctx)
+			return
+		}
+
+		id, err := authenticator.Authenticate(string(ctx.Request.Header.Peek(auth.Header)))
+		if err != nil {
+			if errors.Is(err, auth.ErrUnauthorized) {
+				ctx.Response.Header.Set(auth.ChallengeHeader, auth.Challenge)
+			}
+			ErrorHandler(ctx, "Authenticate", "Request", err)
+			return
+		}
+
+		admin, err := auth.Check(db, auditRecorder, id, r)
+		if err != nil {
+			ErrorHandler(ctx, "Authenticate", "Check", err)
+			return
+		}
+
+		ctx.Request.Header.Set(cart.CallerHeader, id.UserID)
+		ctx.SetUserValue(authenticatedUser, id.UserID)
+		ctx.SetUserValue(adminAccess, admin)
+		h(ctx)
+	}
+}
+
+// rateLimited wraps a handler, so clients that make too many requests get a 429 Too Many
+// Requests status. It runs before requests are authenticated, so clients are limited by
+// their IP address and a client sending lots of invalid tokens is stopped before the
+// tokens are verified.
+func rateLimited(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		if limit(ctx, addressLimiter, ratelimit.IPKey(clientIP(ctx))) {
+			h(ctx)
+		}
+	}
+}
+
+// userRateLimited wraps a handler, so authenticated users that make too many requests get
+// a 429 Too Many Requests status, from whichever address they make them. Requests that
+// aren't authenticated are only limited by rateLimited.
+func userRateLimited(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		userID, _ := ctx.UserValue(authenticatedUser).(string)
+		if len(userID) == 0 || limit(ctx, userLimiter, ratelimit.UserKey(userID)) {
+			h(ctx)
+		}
+	}
+}
+
+// limit takes a token from the bucket with the key in the limiter, and returns true when
+// the request can be handled. Otherwise a 429 Too Many Requests status has been set. The limiter fails
+// open: when the buckets can't be checked, like when a shared backend is down, the error
+// is reported and the request is handled, since failing every request would turn an
+// outage of the limiter into an outage of the Cart service.
+func limit(ctx *fasthttp.RequestCtx, limiter ratelimit.Limiter, key string) bool {
+	if limiter == nil {
+		return true
+	}
+
+	decision, err := limiter.Allow(key)
+	if err != nil {
+		sentry.CaptureException(fmt.Errorf("error in RateLimit::Allow %s", err.Error()))
+		return true
+	}
+
+	if !decision.Allowed {
+		ctx.Response.Header.Set(ratelimit.RetryAfterHeader, ratelimit.RetryAfterSeconds(decision))
+		ErrorHandler(ctx, "RateLimit", "Allow", fmt.Errorf("%w: %s has to wait %s", ratelimit.ErrRateLimited, key, decision.RetryAfter))
+		return false
+	}
+
+	return true
+}
+
+// validated wraps a handler, so requests are checked against the OpenAPI specification
+// before they are handled
+func validated(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		query := make(map[string]string)
+		ctx.QueryArgs().VisitAll(func(k []byte, v []byte) {
+			query[string(k)] = string(v)
+		})
+
+		headers := make(map[string]string)
+		ctx.Request.Header.VisitAll(func(k []byte, v []byte) {
+			headers[string(k)] = string(v)
+		})
+
+		err := apiSpec.Validate(openapi.Request{
+			Method:  string(ctx.Method()),
+			Path:    string(ctx.Path()),
+			Query:   query,
+			Headers: headers,
+			Body:    ctx.Request.Body(),
+		})
+		if err != nil {
+			ErrorHandler(ctx, "Validate", "Request", err)
+			return
+		}
+
+		h(ctx)
+	}
+}
+
+// revertHolds restores the holds of the cart with the key after the items were synced with
+// the inventory, when the change to the cart couldn't be stored
+func revertHolds(key string, before datastore.CartItems, after datastore.CartItems) {
+	if inv != nil {
+		inventory.Revert(inv, key, before, after)
+	}
+}
+
+// ifMatch checks that the cart with the key matches the If-Match header of the request, and
+// returns the version of the cart the change has to be stored at (see cart.CheckIfMatch)
+func ifMatch(ctx *fasthttp.RequestCtx, key string) (int64, error) {
+	return cart.CheckIfMatch(db, key, string(ctx.Request.Header.Peek(cart.IfMatchHeader)))
+}
+
+// addRoutes adds the routes of the Cart service to the router. Every handler is wrapped
+// with wrap, and the routes that change carts honour the Idempotency-Key header.
+func addRoutes(r *router.Router, wrap func(fasthttp.RequestHandler) fasthttp.RequestHandler) {
+	r.POST("/cart/item/add/{userid}", wrap(idempotent(AddItemToCart)))
+	r.GET("/cart/all", wrap(GetAllCarts))
+	r.GET("/cart/clear/{userid}", wrap(ClearCart))
+	r.POST("/cart/item/modify/{userid}", wrap(idempotent(ModifyCartItem)))
+	r.POST("/cart/item/remove/{userid}", wrap(idempotent(RemoveCartItem)))
+	r.GET("/cart/items/{userid}", wrap(GetCartItems))
+	r.POST("/cart/modify/{userid}", wrap(idempotent(ModifyCart)))
+	r.GET("/cart/total/{userid}", wrap(GetCartValue))
+	r.GET("/cart/items/total/{userid}", wrap(GetTotalItems))
+	r.POST("/cart/reprice/{userid}", wrap(idempotent(RepriceCart)))
+	r.POST("/cart/guest", wrap(idempotent(CreateGuestCart)))
+	r.POST("/cart/merge", wrap(idempotent(MergeCart)))
+	r.GET("/cart/list/{userid}", wrap(ListCarts))
+	r.POST("/cart/create/{userid}", wrap(idempotent(CreateCart)))
+	r.POST("/cart/rename/{userid}", wrap(idempotent(RenameCart)))
+	r.POST("/cart/delete/{userid}", wrap(idempotent(DeleteCart)))
+	r.GET("/cart/saved/{userid}", wrap(GetSavedItems))
+	r.POST("/cart/item/save/{userid}", wrap(idempotent(SaveForLater)))
+	r.POST("/cart/saved/restore/{userid}", wrap(idempotent(MoveToCart)))
+	r.POST("/cart/share/{userid}", wrap(idempotent(ShareCart)))
+	r.POST("/cart/join/{userid}", wrap(idempotent(JoinCart)))
+	r.GET("/cart/members/{userid}", wrap(GetCartMembers))
+	r.POST("/cart/members/remove/{userid}", wrap(idempotent(RemoveCartMember)))
+	r.POST("/cart/checkout/{userid}", wrap(idempotent(CheckoutCart)))
+	r.GET("/cart/versions/{userid}", wrap(GetCartVersions))
+	r.GET("/cart/summary/{userid}", wrap(GetCartSummary))
+	r.GET("/cart/diff/{userid}", wrap(DiffCart))
+	r.POST("/cart/undo/{userid}", wrap(idempotent(UndoCart)))
+	r.GET("/cart/export/{userid}", wrap(ExportCart))
+
+	// Add the resource oriented routes of the v2 API. The routes above are kept as aliases.
+	r.GET("/v2/carts/{userid}", wrap(GetCartItems))
+	r.PUT("/v2/carts/{userid}", wrap(idempotent(ModifyCart)))
+	r.PATCH("/v2/carts/{userid}", wrap(idempotent(PatchCart)))
+	r.DELETE("/v2/carts/{userid}", wrap(ClearCart))
+	r.POST("/v2/carts/{userid}/items", wrap(idempotent(AddItemToCart)))
+	r.PATCH("/v2/carts/{userid}/items/{itemid}", wrap(idempotent(ModifyCartItem)))
+	r.DELETE("/v2/carts/{userid}/items/{itemid}", wrap(RemoveCartItem))
+}
+
+// addressLimitFactor is how many times more requests can be made from an address than by
+// a single user, when RATE_LIMIT_ADDRESS isn't set. Many users can share an address, like
+// the users behind the proxy of an office.
+const addressLimitFactor = 10
+
+// newLimiters creates the limiter for users, with the rate in RATE_LIMIT, and the limiter
+// for the addresses of clients, with the rate in RATE_LIMIT_ADDRESS or ten times the rate
+// of users when it isn't set. The token buckets are kept in memory, or in the DynamoDB
+// table in RATE_LIMIT_TABLE when RATE_LIMIT_BACKEND is dynamodb. It returns nil limiters
+// if RATE_LIMIT isn't set.
+func newLimiters() (ratelimit.Limiter, ratelimit.Limiter, error) {
+	if len(os.Getenv("RATE_LIMIT")) == 0 {
+		return nil, nil, nil
+	}
+
+	userLimit, err := limitFromEnv("RATE_LIMIT", "RATE_LIMIT_BURST")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	addressLimit := ratelimit.Limit{
+		Rate:  userLimit.Rate * addressLimitFactor,
+		Burst: userLimit.Burst * addressLimitFactor,
+	}
+	if len(os.Getenv("RATE_LIMIT_ADDRESS")) > 0 {
+		addressLimit, err = limitFromEnv("RATE_LIMIT_ADDRESS", "RATE_LIMIT_ADDRESS_BURST")
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	switch backend := os.Getenv("RATE_LIMIT_BACKEND"); backend {
+	case "", "memory":
+		return ratelimitmemory.New(userLimit), ratelimitmemory.New(addressLimit), nil
+	case "dynamodb":
+		table := os.Getenv("RATE_LIMIT_TABLE")
+		if len(table) == 0 {
+			return nil, nil, fmt.Errorf("RATE_LIMIT_TABLE must be set to keep rate limits in DynamoDB")
+		}
+		return ratelimitdynamodb.New(table, userLimit), ratelimitdynamodb.New(table, addressLimit), nil
+	default:
+		return nil, nil, fmt.Errorf("unknown RATE_LIMIT_BACKEND %s", backend)
+	}
+}
+
+// limitFromEnv returns the limit for the rate in the environment variable rateKey, with the
+// burst in burstKey
+func limitFromEnv(rateKey string, burstKey string) (ratelimit.Limit, error) {
+	burst := 0
+	if len(os.Getenv(burstKey)) > 0 {
+		b, err := strconv.Atoi(os.Getenv(burstKey))
+		if err != nil {
+			return ratelimit.Limit{}, fmt.Errorf("invalid %s: %s", burstKey, err.Error())
+		}
+		burst = b
+	}
+
+	limit, err := ratelimit.ParseLimit(os.Getenv(rateKey), burst)
+	if err != nil {
+		return ratelimit.Limit{}, fmt.Errorf("invalid %s: %s", rateKey, err.Error())
+	}
+
+	return limit, nil
 }
 
 func main() {
@@ -102,19 +520,132 @@ func main() {
 	// are sent to sentry before sending data to Wavefront
 	router := router.New()
 	router.GlobalOPTIONS = CORSHandler
+	router.SaveMatchedRoutePath = true
 
 	// Add routes to the router
-	router.POST("/cart/item/add/{userid}", cfg.WrapFastHTTPRequest(sentryHandler.Handle(AddItemToCart)))
-	router.GET("/cart/all", cfg.WrapFastHTTPRequest(sentryHandler.Handle(GetAllCarts)))
-	router.GET("/cart/clear/{userid}", cfg.WrapFastHTTPRequest(sentryHandler.Handle(ClearCart)))
-	router.POST("/cart/item/modify/{userid}", cfg.WrapFastHTTPRequest(sentryHandler.Handle(ModifyCartItem)))
-	router.GET("/cart/items/{userid}", cfg.WrapFastHTTPRequest(sentryHandler.Handle(GetCartItems)))
-	router.POST("/cart/modify/{userid}", cfg.WrapFastHTTPRequest(sentryHandler.Handle(ModifyCart)))
-	router.GET("/cart/total/{userid}", cfg.WrapFastHTTPRequest(sentryHandler.Handle(GetCartValue)))
-	router.GET("/cart/items/total/{userid}", cfg.WrapFastHTTPRequest(sentryHandler.Handle(GetTotalItems)))
-
-	// Create an instance of the datastore manager
-	db = mongodb.New()
+	addRoutes(router, func(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return cfg.WrapFastHTTPRequest(sentryHandler.Handle(rateLimited(authenticated(userRateLimited(validated(h))))))
+	})
+
+	// Create an instance of the datastore manager, which appends the changes to the
+	// event log when CART_EVENT_LOG is set
+	var err error
+	db, err = logs.Wrap(mongodb.New())
+	if err != nil {
+		log.Fatalf("error configuring event log: %s", err.Error())
+	}
+
+	// Create a client for the catalog so prices can be validated
+	if catalogURL := os.Getenv("CATALOG_URL"); len(catalogURL) > 0 {
+		catalogClient = httpclient.New(catalogURL)
+	} else {
+		log.Println("CATALOG_URL is not set, items will not be validated against the catalog")
+	}
+
+	// Configure the invites to shared carts
+	inviteSecret = []byte(os.Getenv("CART_INVITE_SECRET"))
+	if len(inviteSecret) == 0 {
+		log.Println("CART_INVITE_SECRET is not set, carts can't be shared")
+	}
+
+	inviteTTL = time.Hour * 72
+	if len(os.Getenv("CART_INVITE_TTL")) > 0 {
+		d, err := time.ParseDuration(os.Getenv("CART_INVITE_TTL"))
+		if err != nil {
+			log.Fatalf("invalid CART_INVITE_TTL: %s", err.Error())
+		}
+		inviteTTL = d
+	}
+
+	// Configure how carts are checked out
+	publisher, err = publishers.FromEnv()
+	if err != nil {
+		log.Fatalf("error configuring event publisher: %s", err.Error())
+	}
+	if len(os.Getenv("EVENT_PUBLISHER")) == 0 {
+		log.Println("WARNING: EVENT_PUBLISHER is not set, checkout events are only kept in memory")
+	}
+
+	checkoutArchive, err = cart.ParseCheckoutMode(os.Getenv("CHECKOUT_MODE"))
+	if err != nil {
+		log.Fatalf("invalid CHECKOUT_MODE: %s", err.Error())
+	}
+
+	checkoutLockTTL = time.Minute
+	if len(os.Getenv("CHECKOUT_LOCK_TTL")) > 0 {
+		d, err := time.ParseDuration(os.Getenv("CHECKOUT_LOCK_TTL"))
+		if err != nil {
+			log.Fatalf("invalid CHECKOUT_LOCK_TTL: %s", err.Error())
+		}
+		checkoutLockTTL = d
+	}
+
+	// Create the inventory so stock can be reserved
+	inv, err = sources.FromEnv()
+	if err != nil {
+		log.Fatalf("error configuring inventory: %s", err.Error())
+	}
+	if inv == nil {
+		log.Println("INVENTORY_BACKEND and INVENTORY_FILE are not set, stock will not be reserved")
+	}
+
+	// Configure how users are authenticated
+	authenticator, err = auth.FromEnv()
+	if err != nil {
+		log.Fatalf("error configuring authentication: %s", err.Error())
+	}
+	if authenticator == nil {
+		log.Println("AUTH_JWKS_URL and AUTH_JWKS_FILE are not set, requests will not be authenticated")
+	}
+
+	// Configure where admin access is recorded
+	auditRecorder, err = audit.FromEnv()
+	if err != nil {
+		log.Fatalf("error configuring audit log: %s", err.Error())
+	}
+
+	// Configure how many requests clients can make
+	userLimiter, addressLimiter, err = newLimiters()
+	if err != nil {
+		log.Fatalf("error configuring rate limits: %s", err.Error())
+	}
+	if userLimiter == nil {
+		log.Println("RATE_LIMIT is not set, requests will not be rate limited")
+	}
+
+	if len(os.Getenv("RATE_LIMIT_TRUSTED_PROXIES")) > 0 {
+		trustedProxies, err = strconv.Atoi(os.Getenv("RATE_LIMIT_TRUSTED_PROXIES"))
+		if err != nil {
+			log.Fatalf("invalid RATE_LIMIT_TRUSTED_PROXIES: %s", err.Error())
+		}
+	}
+
+	// Load the OpenAPI specification that requests are validated against
+	apiSpec, err = openapi.Load()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	// Configure how long idempotency keys are kept
+	idempotencyTTL, err = idempotency.TTLFromEnv()
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	// Load the bundles that can be added to carts
+	bundles, err = cart.BundlesFromEnv()
+	if err != nil {
+		log.Fatalf("error loading bundles: %s", err.Error())
+	}
+
+	// Load the business rules for carts
+	cartRules, err = rules.FromEnv()
+	if err != nil {
+		log.Fatalf("error loading rules: %s", err.Error())
+	}
+	if cartRules == nil {
+		log.Println("CART_RULES and CART_RULES_FILE are not set, business rules will not be enforced")
+	}
 
 	// Start the server
 	log.Printf("successfully started %s server", servicename)
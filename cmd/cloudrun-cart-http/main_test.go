@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fasthttp/router"
+	"github.com/retgits/acme-serverless-cart/internal/ratelimit"
+	"github.com/valyala/fasthttp"
+)
+
+// specRoutes returns the method and path of every operation in the OpenAPI specification
+func specRoutes(t *testing.T) map[string]bool {
+	data, err := ioutil.ReadFile("../../api/openapi.json")
+	if err != nil {
+		t.Fatalf("error reading OpenAPI specification: %s", err.Error())
+	}
+
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		t.Fatalf("error parsing OpenAPI specification: %s", err.Error())
+	}
+
+	routes := make(map[string]bool)
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			if method == "parameters" {
+				continue
+			}
+			routes[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+	return routes
+}
+
+// diff returns the routes in a that aren't in b, sorted
+func diff(a map[string]bool, b map[string]bool) []string {
+	res := make([]string, 0)
+	for r := range a {
+		if !b[r] {
+			res = append(res, r)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestRoutesMatchSpec(t *testing.T) {
+	r := router.New()
+	addRoutes(r, func(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return h
+	})
+
+	routes := make(map[string]bool)
+	for method, paths := range r.List() {
+		for _, path := range paths {
+			routes[method+" "+path] = true
+		}
+	}
+
+	spec := specRoutes(t)
+	for _, route := range diff(routes, spec) {
+		t.Errorf("route %s is not in api/openapi.json", route)
+	}
+	for _, route := range diff(spec, routes) {
+		t.Errorf("route %s in api/openapi.json is not served by the router", route)
+	}
+}
+
+// stubLimiter is a Limiter that denies the keys in deny, or fails when err is set
+type stubLimiter struct {
+	keys []string
+	deny map[string]bool
+	err  error
+}
+
+func (l *stubLimiter) Allow(key string) (ratelimit.Decision, error) {
+	l.keys = append(l.keys, key)
+	if l.err != nil {
+		return ratelimit.Decision{}, l.err
+	}
+	if l.deny[key] {
+		return ratelimit.Decision{RetryAfter: time.Second}, nil
+	}
+	return ratelimit.Decision{Allowed: true}, nil
+}
+
+// useLimiter replaces the limiters of users and addresses for the duration of the test
+func useLimiter(t *testing.T, l ratelimit.Limiter) {
+	prevUser, prevAddress := userLimiter, addressLimiter
+	userLimiter, addressLimiter = l, l
+	t.Cleanup(func() {
+		userLimiter, addressLimiter = prevUser, prevAddress
+	})
+}
+
+// newRequestCtx returns the context of a request from the IP address
+func newRequestCtx(ip string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Init(&fasthttp.Request{}, &net.TCPAddr{IP: net.ParseIP(ip)}, nil)
+	return ctx
+}
+
+func TestRateLimitedBeforeAuthentication(t *testing.T) {
+	l := &stubLimiter{deny: map[string]bool{"ip:192.0.2.1": true}}
+	useLimiter(t, l)
+
+	reached := false
+	h := rateLimited(func(ctx *fasthttp.RequestCtx) {
+		reached = true
+	})
+
+	ctx := newRequestCtx("192.0.2.1")
+	h(ctx)
+
+	if reached {
+		t.Error("expected the request to be limited before it is authenticated")
+	}
+	if ctx.Response.StatusCode() != http.StatusTooManyRequests {
+		t.Errorf("expected status %d, got %d", http.StatusTooManyRequests, ctx.Response.StatusCode())
+	}
+	if retry := string(ctx.Response.Header.Peek(ratelimit.RetryAfterHeader)); retry != "1" {
+		t.Errorf("expected a Retry-After of 1 second, got %q", retry)
+	}
+}
+
+func TestUserRateLimited(t *testing.T) {
+	l := &stubLimiter{deny: map[string]bool{"user:dan": true}}
+	useLimiter(t, l)
+
+	handled := 0
+	h := userRateLimited(func(ctx *fasthttp.RequestCtx) {
+		handled++
+	})
+
+	// Requests that aren't authenticated are only limited by their address
+	h(newRequestCtx("192.0.2.1"))
+
+	ctx := newRequestCtx("192.0.2.1")
+	ctx.SetUserValue(authenticatedUser, "dan")
+	h(ctx)
+
+	if handled != 1 {
+		t.Errorf("expected only the request that isn't authenticated to be handled, got %d", handled)
+	}
+	if strings.Join(l.keys, ",") != "user:dan" {
+		t.Errorf("expected only the bucket of dan to be used, got %v", l.keys)
+	}
+}
+
+func TestRateLimitedFailsOpen(t *testing.T) {
+	useLimiter(t, &stubLimiter{err: errors.New("table not found")})
+
+	handled := 0
+	h := rateLimited(userRateLimited(func(ctx *fasthttp.RequestCtx) {
+		handled++
+		ctx.SetStatusCode(http.StatusOK)
+	}))
+
+	ctx := newRequestCtx("192.0.2.1")
+	ctx.SetUserValue(authenticatedUser, "dan")
+	h(ctx)
+
+	if handled != 1 || ctx.Response.StatusCode() != http.StatusOK {
+		t.Errorf("expected the request to be handled when the limiter fails, got status %d", ctx.Response.StatusCode())
+	}
+}
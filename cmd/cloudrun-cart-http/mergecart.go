@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/retgits/acme-serverless-cart/internal/inventory"
+	"github.com/valyala/fasthttp"
+)
+
+// MergeCart merges the cart of an anonymous user into the cart of a user
+func MergeCart(ctx *fasthttp.RequestCtx) {
+	req, err := cart.UnmarshalMergeRequest(ctx.Request.Body())
+	if err != nil {
+		ErrorHandler(ctx, "MergeCart", "UnmarshalMergeRequest", err)
+		return
+	}
+
+	// Only the user can merge a cart into their own cart
+	c := caller(ctx)
+	if len(c) == 0 {
+		c = req.UserID
+	}
+
+	err = cart.Authorize(db, req.UserID, c, datastore.RoleOwner)
+	if err != nil {
+		ErrorHandler(ctx, "MergeCart", "Authorize", err)
+		return
+	}
+
+	res, err := cart.MergeCarts(db, req, os.Getenv("MERGE_POLICY"))
+	if err != nil {
+		ErrorHandler(ctx, "MergeCart", "MergeCarts", err)
+		return
+	}
+
+	// Move the reserved stock from the guest to the user
+	if inv != nil {
+		err = inventory.Sync(inv, req.GuestID, res.GuestItems, nil)
+		if err != nil {
+			ErrorHandler(ctx, "MergeCart", "Release", err)
+			return
+		}
+
+		err = inventory.Sync(inv, req.UserID, res.UserItems, res.Items)
+		if err != nil {
+			inventory.Sync(inv, req.GuestID, nil, res.GuestItems)
+			ErrorHandler(ctx, "MergeCart", "Reserve", err)
+			return
+		}
+	}
+
+	err = db.MoveCart(req.GuestID, req.UserID, res.Items)
+	if err != nil {
+		revertHolds(req.UserID, res.UserItems, res.Items)
+		revertHolds(req.GuestID, res.GuestItems, nil)
+		ErrorHandler(ctx, "MergeCart", "MoveCart", err)
+		return
+	}
+
+	ct := datastore.Cart{
+		Items:  res.Items,
+		UserID: req.UserID,
+	}
+
+	payload, err := ct.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "MergeCart", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
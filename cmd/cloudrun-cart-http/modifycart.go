@@ -1,9 +1,13 @@
 package main
 
 import (
+	"errors"
 	"net/http"
 
 	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/retgits/acme-serverless-cart/internal/inventory"
 	"github.com/valyala/fasthttp"
 )
 
@@ -11,15 +15,67 @@ import (
 func ModifyCart(ctx *fasthttp.RequestCtx) {
 	// Create the key attributes
 	userID := ctx.UserValue("userid").(string)
+	key, err := cartKey(ctx)
+	if err != nil {
+		ErrorHandler(ctx, "ModifyCart", "CartKey", err)
+		return
+	}
+
+	// Check that the caller is allowed to change the cart
+	err = authorize(ctx, key, datastore.RoleEditor)
+	if err != nil {
+		ErrorHandler(ctx, "ModifyCart", "Authorize", err)
+		return
+	}
 
-	crt, err := acmeserverless.UnmarshalCart(string(ctx.Request.Body()))
+	// Check that the cart didn't change since the caller read it
+	version, err := ifMatch(ctx, key)
+	if err != nil {
+		ErrorHandler(ctx, "ModifyCart", "IfMatch", err)
+		return
+	}
+
+	crt, err := datastore.UnmarshalCart(string(ctx.Request.Body()))
 	if err != nil {
 		ErrorHandler(ctx, "ModifyCart", "UnmarshalCart", err)
 		return
 	}
 
-	err = db.StoreItems(userID, crt.Items)
+	// Validate the items against the catalog, bundles get their price and components
+	crt.Items, err = cart.ValidateItems(catalogClient, bundles, crt.Items)
+	if err != nil {
+		ErrorHandler(ctx, "ModifyCart", "ValidateItems", err)
+		return
+	}
+
+	cartItems, err := db.GetItems(key)
+	if err != nil && !errors.Is(err, datastore.ErrCartNotFound) {
+		ErrorHandler(ctx, "ModifyCart", "GetItems", err)
+		return
+	}
+
+	// Lines that were already in the cart keep who added them
+	cart.Attribute(cartItems, crt.Items, caller(ctx))
+
+	// Check that the cart follows the business rules
+	err = cartRules.Check(crt.Items, region(ctx))
+	if err != nil {
+		ErrorHandler(ctx, "ModifyCart", "CheckRules", err)
+		return
+	}
+
+	// Update the reserved stock to match the new cart
+	if inv != nil {
+		err = inventory.Sync(inv, key, cartItems, crt.Items)
+		if err != nil {
+			ErrorHandler(ctx, "ModifyCart", "Reserve", err)
+			return
+		}
+	}
+
+	err = cart.StoreItems(db, key, crt.Items, version)
 	if err != nil {
+		revertHolds(key, cartItems, crt.Items)
 		ErrorHandler(ctx, "ModifyCart", "StoreItems", err)
 		return
 	}
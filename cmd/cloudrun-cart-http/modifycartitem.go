@@ -4,6 +4,9 @@ import (
 	"net/http"
 
 	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/retgits/acme-serverless-cart/internal/inventory"
 	"github.com/valyala/fasthttp"
 )
 
@@ -11,27 +14,73 @@ import (
 func ModifyCartItem(ctx *fasthttp.RequestCtx) {
 	// Create the key attributes
 	userID := ctx.UserValue("userid").(string)
+	key, err := cartKey(ctx)
+	if err != nil {
+		ErrorHandler(ctx, "ModifyCartItem", "CartKey", err)
+		return
+	}
+
+	// Check that the caller is allowed to change the cart
+	err = authorize(ctx, key, datastore.RoleEditor)
+	if err != nil {
+		ErrorHandler(ctx, "ModifyCartItem", "Authorize", err)
+		return
+	}
+
+	// Check that the cart didn't change since the caller read it
+	version, err := ifMatch(ctx, key)
+	if err != nil {
+		ErrorHandler(ctx, "ModifyCartItem", "IfMatch", err)
+		return
+	}
 
-	cartItems, err := db.GetItems(userID)
+	cartItems, err := db.GetItems(key)
 	if err != nil {
 		ErrorHandler(ctx, "ModifyCartItem", "GetItems", err)
 		return
 	}
 
-	item, err := acmeserverless.UnmarshalItem(ctx.Request.Body())
+	item, err := cart.RequestItem(ctx.Request.Body(), pathItemID(ctx))
 	if err != nil {
 		ErrorHandler(ctx, "ModifyCartItem", "UnmarshalItem", err)
 		return
 	}
 
+	// Validate the item against the catalog, bundles get their price and components
+	item, err = cart.ValidateItem(catalogClient, bundles, item)
+	if err != nil {
+		ErrorHandler(ctx, "ModifyCartItem", "Validate", err)
+		return
+	}
+
+	modifiedItems := make(datastore.CartItems, len(cartItems))
 	for idx, cci := range cartItems {
-		if *cci.ItemID == *item.ItemID {
-			cartItems[idx] = item
+		modifiedItems[idx] = cci
+		if cci.SameLine(item) {
+			item.AddedBy = cci.AddedBy
+			modifiedItems[idx] = item
+		}
+	}
+
+	// Check that the cart follows the business rules
+	err = cartRules.Check(modifiedItems, region(ctx))
+	if err != nil {
+		ErrorHandler(ctx, "ModifyCartItem", "CheckRules", err)
+		return
+	}
+
+	// Update the reserved stock for the item
+	if inv != nil {
+		err = inventory.Sync(inv, key, cartItems, modifiedItems)
+		if err != nil {
+			ErrorHandler(ctx, "ModifyCartItem", "Reserve", err)
+			return
 		}
 	}
 
-	err = db.StoreItems(userID, cartItems)
+	err = cart.StoreItems(db, key, modifiedItems, version)
 	if err != nil {
+		revertHolds(key, cartItems, modifiedItems)
 		ErrorHandler(ctx, "ModifyCartItem", "StoreItems", err)
 		return
 	}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/retgits/acme-serverless-cart/internal/inventory"
+	"github.com/valyala/fasthttp"
+)
+
+// MoveToCart moves an item from the saved-for-later list of the user back to a cart
+func MoveToCart(ctx *fasthttp.RequestCtx) {
+	// Create the key attributes
+	userID := ctx.UserValue("userid").(string)
+	key, err := cartKey(ctx)
+	if err != nil {
+		ErrorHandler(ctx, "MoveToCart", "CartKey", err)
+		return
+	}
+
+	// Only the owner has a saved-for-later list
+	err = authorize(ctx, key, datastore.RoleOwner)
+	if err != nil {
+		ErrorHandler(ctx, "MoveToCart", "Authorize", err)
+		return
+	}
+
+	// Check that the cart didn't change since the caller read it
+	version, err := ifMatch(ctx, key)
+	if err != nil {
+		ErrorHandler(ctx, "MoveToCart", "IfMatch", err)
+		return
+	}
+
+	item, err := datastore.UnmarshalItem(ctx.Request.Body())
+	if err != nil {
+		ErrorHandler(ctx, "MoveToCart", "UnmarshalItem", err)
+		return
+	}
+
+	if item.ItemID == nil {
+		ErrorHandler(ctx, "MoveToCart", "ItemID", fmt.Errorf("%w: item has no itemid", datastore.ErrInvalidRequest))
+		return
+	}
+
+	// Reserve stock for the item before it is back in the cart
+	var cartItems, newItems datastore.CartItems
+	if inv != nil {
+		cartItems, err = db.GetItems(key)
+		if err != nil {
+			ErrorHandler(ctx, "MoveToCart", "GetItems", err)
+			return
+		}
+
+		savedItems, err := db.GetSavedItems(userID)
+		if err != nil {
+			ErrorHandler(ctx, "MoveToCart", "GetSavedItems", err)
+			return
+		}
+
+		_, newItems, err = datastore.MoveItem(savedItems, cartItems, item)
+		if err != nil {
+			ErrorHandler(ctx, "MoveToCart", "MoveItem", err)
+			return
+		}
+
+		err = inventory.Sync(inv, key, cartItems, newItems)
+		if err != nil {
+			ErrorHandler(ctx, "MoveToCart", "Reserve", err)
+			return
+		}
+	}
+
+	err = cart.MoveToCart(db, key, item, version)
+	if err != nil {
+		revertHolds(key, cartItems, newItems)
+		ErrorHandler(ctx, "MoveToCart", "MoveToCart", err)
+		return
+	}
+
+	res := acmeserverless.UserIDResponse{
+		UserID: userID,
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "MoveToCart", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
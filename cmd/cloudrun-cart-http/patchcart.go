@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/retgits/acme-serverless-cart/internal/inventory"
+	"github.com/valyala/fasthttp"
+)
+
+// PatchCart applies a JSON Merge Patch or JSON Patch to the items in a cart
+func PatchCart(ctx *fasthttp.RequestCtx) {
+	// Create the key attributes
+	userID := ctx.UserValue("userid").(string)
+	key, err := cartKey(ctx)
+	if err != nil {
+		ErrorHandler(ctx, "PatchCart", "CartKey", err)
+		return
+	}
+
+	// Check that the caller is allowed to change the cart
+	err = authorize(ctx, key, datastore.RoleEditor)
+	if err != nil {
+		ErrorHandler(ctx, "PatchCart", "Authorize", err)
+		return
+	}
+
+	// Check that the cart didn't change since the caller read it
+	version, err := ifMatch(ctx, key)
+	if err != nil {
+		ErrorHandler(ctx, "PatchCart", "IfMatch", err)
+		return
+	}
+
+	cartItems, err := db.GetItems(key)
+	if err != nil && !errors.Is(err, datastore.ErrCartNotFound) {
+		ErrorHandler(ctx, "PatchCart", "GetItems", err)
+		return
+	}
+
+	items, err := cart.PatchItems(cartItems, string(ctx.Request.Header.ContentType()), ctx.Request.Body())
+	if err != nil {
+		ErrorHandler(ctx, "PatchCart", "PatchItems", err)
+		return
+	}
+
+	// Validate the items against the catalog, bundles get their price and components
+	items, err = cart.ValidateItems(catalogClient, bundles, items)
+	if err != nil {
+		ErrorHandler(ctx, "PatchCart", "ValidateItems", err)
+		return
+	}
+
+	// Lines that were already in the cart keep who added them
+	cart.Attribute(cartItems, items, caller(ctx))
+
+	// Check that the cart follows the business rules
+	err = cartRules.Check(items, region(ctx))
+	if err != nil {
+		ErrorHandler(ctx, "PatchCart", "CheckRules", err)
+		return
+	}
+
+	// Update the reserved stock to match the patched cart
+	if inv != nil {
+		err = inventory.Sync(inv, key, cartItems, items)
+		if err != nil {
+			ErrorHandler(ctx, "PatchCart", "Reserve", err)
+			return
+		}
+	}
+
+	err = cart.StoreItems(db, key, items, version)
+	if err != nil {
+		revertHolds(key, cartItems, items)
+		ErrorHandler(ctx, "PatchCart", "StoreItems", err)
+		return
+	}
+
+	res := acmeserverless.UserIDResponse{
+		UserID: userID,
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "PatchCart", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/retgits/acme-serverless-cart/internal/inventory"
+	"github.com/valyala/fasthttp"
+)
+
+// RemoveCartItem removes a single item from a cart
+func RemoveCartItem(ctx *fasthttp.RequestCtx) {
+	// Create the key attributes
+	userID := ctx.UserValue("userid").(string)
+	key, err := cartKey(ctx)
+	if err != nil {
+		ErrorHandler(ctx, "RemoveCartItem", "CartKey", err)
+		return
+	}
+
+	// Check that the caller is allowed to change the cart
+	err = authorize(ctx, key, datastore.RoleEditor)
+	if err != nil {
+		ErrorHandler(ctx, "RemoveCartItem", "Authorize", err)
+		return
+	}
+
+	// Check that the cart didn't change since the caller read it
+	version, err := ifMatch(ctx, key)
+	if err != nil {
+		ErrorHandler(ctx, "RemoveCartItem", "IfMatch", err)
+		return
+	}
+
+	item, err := cart.RequestItem(ctx.Request.Body(), pathItemID(ctx))
+	if err != nil {
+		ErrorHandler(ctx, "RemoveCartItem", "UnmarshalItem", err)
+		return
+	}
+
+	cartItems, err := db.GetItems(key)
+	if err != nil {
+		ErrorHandler(ctx, "RemoveCartItem", "GetItems", err)
+		return
+	}
+
+	remainingItems := make(datastore.CartItems, 0, len(cartItems))
+	for _, cci := range cartItems {
+		if cci.SameLine(item) {
+			continue
+		}
+		remainingItems = append(remainingItems, cci)
+	}
+
+	// Release the reserved stock for the item
+	if inv != nil {
+		err = inventory.Sync(inv, key, cartItems, remainingItems)
+		if err != nil {
+			ErrorHandler(ctx, "RemoveCartItem", "Release", err)
+			return
+		}
+	}
+
+	err = cart.StoreItems(db, key, remainingItems, version)
+	if err != nil {
+		revertHolds(key, cartItems, remainingItems)
+		ErrorHandler(ctx, "RemoveCartItem", "StoreItems", err)
+		return
+	}
+
+	res := acmeserverless.UserIDResponse{
+		UserID: userID,
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "RemoveCartItem", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/valyala/fasthttp"
+)
+
+// RemoveCartMember removes a collaborator from a cart
+func RemoveCartMember(ctx *fasthttp.RequestCtx) {
+	// Create the key attributes
+	userID := ctx.UserValue("userid").(string)
+	key, err := cartKey(ctx)
+	if err != nil {
+		ErrorHandler(ctx, "RemoveCartMember", "CartKey", err)
+		return
+	}
+
+	req, err := cart.UnmarshalMemberRequest(ctx.Request.Body())
+	if err != nil {
+		ErrorHandler(ctx, "RemoveCartMember", "UnmarshalMemberRequest", err)
+		return
+	}
+
+	// Collaborators can leave a cart, but only the owner can remove others
+	if req.UserID != caller(ctx) {
+		err = authorize(ctx, key, datastore.RoleOwner)
+		if err != nil {
+			ErrorHandler(ctx, "RemoveCartMember", "Authorize", err)
+			return
+		}
+	}
+
+	err = db.RemoveMember(key, req.UserID)
+	if err != nil {
+		ErrorHandler(ctx, "RemoveCartMember", "RemoveMember", err)
+		return
+	}
+
+	res := acmeserverless.UserIDResponse{
+		UserID: userID,
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "RemoveCartMember", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/valyala/fasthttp"
+)
+
+// RenameCart changes the name of a cart of a user
+func RenameCart(ctx *fasthttp.RequestCtx) {
+	// Create the key attributes
+	userID := ctx.UserValue("userid").(string)
+	key, err := cartKey(ctx)
+	if err != nil {
+		ErrorHandler(ctx, "RenameCart", "CartKey", err)
+		return
+	}
+
+	// Only the owner can rename a cart
+	err = authorize(ctx, key, datastore.RoleOwner)
+	if err != nil {
+		ErrorHandler(ctx, "RenameCart", "Authorize", err)
+		return
+	}
+
+	cartID := string(ctx.QueryArgs().Peek("cart"))
+	if len(cartID) == 0 {
+		cartID = datastore.DefaultCart
+	}
+
+	req, err := cart.UnmarshalCartRequest(ctx.Request.Body())
+	if err != nil {
+		ErrorHandler(ctx, "RenameCart", "UnmarshalCartRequest", err)
+		return
+	}
+
+	err = db.RenameCart(userID, cartID, req.Name)
+	if err != nil {
+		ErrorHandler(ctx, "RenameCart", "RenameCart", err)
+		return
+	}
+
+	payload, err := json.Marshal(datastore.CartInfo{
+		ID:   cartID,
+		Name: req.Name,
+	})
+	if err != nil {
+		ErrorHandler(ctx, "RenameCart", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
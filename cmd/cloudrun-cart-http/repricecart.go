@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/catalog"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/valyala/fasthttp"
+)
+
+// RepriceCart updates the prices of the items in a cart to the current prices in the catalog
+func RepriceCart(ctx *fasthttp.RequestCtx) {
+	// Create the key attributes
+	userID := ctx.UserValue("userid").(string)
+	key, err := cartKey(ctx)
+	if err != nil {
+		ErrorHandler(ctx, "RepriceCart", "CartKey", err)
+		return
+	}
+
+	// Check that the caller is allowed to change the cart
+	err = authorize(ctx, key, datastore.RoleEditor)
+	if err != nil {
+		ErrorHandler(ctx, "RepriceCart", "Authorize", err)
+		return
+	}
+
+	// Check that the cart didn't change since the caller read it
+	version, err := ifMatch(ctx, key)
+	if err != nil {
+		ErrorHandler(ctx, "RepriceCart", "IfMatch", err)
+		return
+	}
+
+	if catalogClient == nil {
+		ErrorHandler(ctx, "RepriceCart", "CatalogClient", fmt.Errorf("catalog is not configured"))
+		return
+	}
+
+	cartItems, err := db.GetItems(key)
+	if err != nil {
+		ErrorHandler(ctx, "RepriceCart", "GetItems", err)
+		return
+	}
+
+	cartItems, res, err := catalog.Reprice(catalogClient, cartItems)
+	if err != nil {
+		ErrorHandler(ctx, "RepriceCart", "Reprice", err)
+		return
+	}
+
+	// Only update the cart when prices have changed
+	if len(res.Changed) > 0 {
+		err = cart.StoreItems(db, key, cartItems, version)
+		if err != nil {
+			ErrorHandler(ctx, "RepriceCart", "StoreItems", err)
+			return
+		}
+	}
+
+	res.UserID = userID
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "RepriceCart", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/retgits/acme-serverless-cart/internal/inventory"
+	"github.com/valyala/fasthttp"
+)
+
+// SaveForLater moves an item from a cart to the saved-for-later list of the user
+func SaveForLater(ctx *fasthttp.RequestCtx) {
+	// Create the key attributes
+	userID := ctx.UserValue("userid").(string)
+	key, err := cartKey(ctx)
+	if err != nil {
+		ErrorHandler(ctx, "SaveForLater", "CartKey", err)
+		return
+	}
+
+	// Only the owner has a saved-for-later list
+	err = authorize(ctx, key, datastore.RoleOwner)
+	if err != nil {
+		ErrorHandler(ctx, "SaveForLater", "Authorize", err)
+		return
+	}
+
+	// Check that the cart didn't change since the caller read it
+	version, err := ifMatch(ctx, key)
+	if err != nil {
+		ErrorHandler(ctx, "SaveForLater", "IfMatch", err)
+		return
+	}
+
+	item, err := datastore.UnmarshalItem(ctx.Request.Body())
+	if err != nil {
+		ErrorHandler(ctx, "SaveForLater", "UnmarshalItem", err)
+		return
+	}
+
+	if item.ItemID == nil {
+		ErrorHandler(ctx, "SaveForLater", "ItemID", fmt.Errorf("%w: item has no itemid", datastore.ErrInvalidRequest))
+		return
+	}
+
+	// Saved items don't hold any stock
+	var cartItems, remainingItems datastore.CartItems
+	if inv != nil {
+		cartItems, err = db.GetItems(key)
+		if err != nil {
+			ErrorHandler(ctx, "SaveForLater", "GetItems", err)
+			return
+		}
+
+		remainingItems, _, err = datastore.MoveItem(cartItems, nil, item)
+		if err != nil {
+			ErrorHandler(ctx, "SaveForLater", "MoveItem", err)
+			return
+		}
+
+		err = inventory.Sync(inv, key, cartItems, remainingItems)
+		if err != nil {
+			ErrorHandler(ctx, "SaveForLater", "Release", err)
+			return
+		}
+	}
+
+	err = cart.SaveForLater(db, key, item, version)
+	if err != nil {
+		revertHolds(key, cartItems, remainingItems)
+		ErrorHandler(ctx, "SaveForLater", "SaveForLater", err)
+		return
+	}
+
+	res := acmeserverless.UserIDResponse{
+		UserID: userID,
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "SaveForLater", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
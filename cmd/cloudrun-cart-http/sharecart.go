@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/valyala/fasthttp"
+)
+
+// ShareCart creates an invite token that gives another user access to a cart
+func ShareCart(ctx *fasthttp.RequestCtx) {
+	// Create the key attributes
+	key, err := cartKey(ctx)
+	if err != nil {
+		ErrorHandler(ctx, "ShareCart", "CartKey", err)
+		return
+	}
+
+	// Only the owner can share a cart
+	err = authorize(ctx, key, datastore.RoleOwner)
+	if err != nil {
+		ErrorHandler(ctx, "ShareCart", "Authorize", err)
+		return
+	}
+
+	req, err := cart.UnmarshalShareRequest(ctx.Request.Body())
+	if err != nil {
+		ErrorHandler(ctx, "ShareCart", "UnmarshalShareRequest", err)
+		return
+	}
+
+	role, err := cart.ParseRole(req.Role)
+	if err != nil {
+		ErrorHandler(ctx, "ShareCart", "ParseRole", err)
+		return
+	}
+
+	// Make sure the cart exists before inviting others to it
+	_, err = db.Members(key)
+	if err != nil {
+		ErrorHandler(ctx, "ShareCart", "Members", err)
+		return
+	}
+
+	expires := time.Now().Add(inviteTTL)
+
+	token, err := cart.NewInviteToken(inviteSecret, cart.Invite{
+		Key:     key,
+		Role:    role,
+		Expires: expires.Unix(),
+	})
+	if err != nil {
+		ErrorHandler(ctx, "ShareCart", "NewInviteToken", err)
+		return
+	}
+
+	res := cart.ShareResponse{
+		Token:   token,
+		Role:    role,
+		Expires: expires.UTC(),
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "ShareCart", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/retgits/acme-serverless-cart/internal/inventory"
+	"github.com/valyala/fasthttp"
+)
+
+// UndoCart reverts a cart to the version before the last change
+func UndoCart(ctx *fasthttp.RequestCtx) {
+	// Create the key attributes
+	userID := ctx.UserValue("userid").(string)
+	key, err := cartKey(ctx)
+	if err != nil {
+		ErrorHandler(ctx, "UndoCart", "CartKey", err)
+		return
+	}
+
+	// Check that the caller is allowed to change the cart
+	err = authorize(ctx, key, datastore.RoleEditor)
+	if err != nil {
+		ErrorHandler(ctx, "UndoCart", "Authorize", err)
+		return
+	}
+
+	// Check that the cart didn't change since the caller read it
+	version, err := ifMatch(ctx, key)
+	if err != nil {
+		ErrorHandler(ctx, "UndoCart", "IfMatch", err)
+		return
+	}
+
+	current, previous, err := cart.PreviousVersion(db, key)
+	if err != nil {
+		ErrorHandler(ctx, "UndoCart", "PreviousVersion", err)
+		return
+	}
+
+	// The previous version is only restored when the cart is still at the version the caller read
+	if version != cart.AnyVersion && version != current.Version {
+		ErrorHandler(ctx, "UndoCart", "IfMatch", fmt.Errorf("%w: cart %s has changed", cart.ErrPreconditionFailed, key))
+		return
+	}
+
+	// Reserve stock for the items of the previous version
+	if inv != nil {
+		err = inventory.Sync(inv, key, current.Items, previous.Items)
+		if err != nil {
+			ErrorHandler(ctx, "UndoCart", "Reserve", err)
+			return
+		}
+	}
+
+	err = db.StoreItemsIfVersion(key, previous.Items, current.Version)
+	if err != nil {
+		revertHolds(key, current.Items, previous.Items)
+		ErrorHandler(ctx, "UndoCart", "StoreItemsIfVersion", err)
+		return
+	}
+
+	res := acmeserverless.UserIDResponse{
+		UserID: userID,
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		ErrorHandler(ctx, "UndoCart", "Marshal", err)
+		return
+	}
+
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.Write(payload)
+}
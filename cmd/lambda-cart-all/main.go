@@ -2,6 +2,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -11,7 +12,11 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/getsentry/sentry-go"
+	"github.com/retgits/acme-serverless-cart/internal/auth"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
 	"github.com/retgits/acme-serverless-cart/internal/datastore/dynamodb"
+	"github.com/retgits/acme-serverless-cart/internal/openapi"
+	"github.com/retgits/acme-serverless-cart/internal/problem"
 	wflambda "github.com/wavefronthq/wavefront-lambda-go"
 )
 
@@ -28,14 +33,15 @@ func handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyRespo
 		Environment: os.Getenv("STAGE"),
 	})
 
-	// Create headers if they don't exist and add
-	// the CORS required headers, otherwise the response
-	// will not be accepted by browsers.
-	headers := request.Headers
-	if headers == nil {
-		headers = make(map[string]string)
+	// Create the headers of the response with the CORS required
+	// headers, otherwise the response will not be accepted by
+	// browsers. Headers of the request, like the Authorization
+	// header, are never sent back.
+	headers := map[string]string{
+		"Access-Control-Allow-Origin": "*",
+		"Content-Type":                "application/json",
+		cart.RequestIDHeader:          request.RequestContext.RequestID,
 	}
-	headers["Access-Control-Allow-Origin"] = "*"
 
 	dynamoStore := dynamodb.New()
 
@@ -59,19 +65,16 @@ func handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyRespo
 }
 
 // handleError takes the activity where the error occured and the error object and sends a message to sentry.
-// The original error, together with the appropriate API Gateway Proxy Response, is returned so it can be thrown.
+// The error is returned as problem details in the appropriate API Gateway Proxy Response.
 func handleError(area string, headers map[string]string, err error) (events.APIGatewayProxyResponse, error) {
-	sentry.CaptureException(fmt.Errorf("error %s: %s", area, err.Error()))
-	msg := fmt.Sprintf("error %s: %s", area, err.Error())
+	msg := fmt.Sprintf("error %s (request %s): %s", area, headers[cart.RequestIDHeader], err.Error())
+	sentry.CaptureException(errors.New(msg))
 	log.Println(msg)
-	return events.APIGatewayProxyResponse{
-		StatusCode: http.StatusBadRequest,
-		Body:       msg,
-		Headers:    headers,
-	}, nil
+	return problem.Response(headers, err), nil
 }
 
 // The main method is executed by AWS Lambda and points to the handler
 func main() {
-	lambda.Start(wflambda.Wrapper(handler))
+	// Requests are authenticated and validated against the OpenAPI specification
+	lambda.Start(wflambda.Wrapper(auth.Lambda(dynamodb.New(), openapi.Lambda(handler, handleError), handleError)))
 }
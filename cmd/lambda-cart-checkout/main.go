@@ -0,0 +1,178 @@
+// Check out a cart
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/getsentry/sentry-go"
+	"github.com/retgits/acme-serverless-cart/internal/auth"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/catalog/httpclient"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/retgits/acme-serverless-cart/internal/datastore/dynamodb"
+	"github.com/retgits/acme-serverless-cart/internal/eventlog/logs"
+	"github.com/retgits/acme-serverless-cart/internal/events/publishers"
+	"github.com/retgits/acme-serverless-cart/internal/idempotency"
+	"github.com/retgits/acme-serverless-cart/internal/inventory"
+	"github.com/retgits/acme-serverless-cart/internal/inventory/sources"
+	"github.com/retgits/acme-serverless-cart/internal/openapi"
+	"github.com/retgits/acme-serverless-cart/internal/problem"
+	wflambda "github.com/wavefronthq/wavefront-lambda-go"
+)
+
+// handler handles the API Gateway events and returns an error if anything goes wrong.
+func handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Initiialize a connection to Sentry to capture errors and traces
+	sentry.Init(sentry.ClientOptions{
+		Dsn: os.Getenv("SENTRY_DSN"),
+		Transport: &sentry.HTTPSyncTransport{
+			Timeout: time.Second * 3,
+		},
+		ServerName:  os.Getenv("FUNCTION_NAME"),
+		Release:     os.Getenv("VERSION"),
+		Environment: os.Getenv("STAGE"),
+	})
+
+	// Create the headers of the response with the CORS required
+	// headers, otherwise the response will not be accepted by
+	// browsers. Headers of the request, like the Authorization
+	// header, are never sent back.
+	headers := map[string]string{
+		"Access-Control-Allow-Origin": "*",
+		"Content-Type":                "application/json",
+		cart.RequestIDHeader:          request.RequestContext.RequestID,
+	}
+
+	// Create the key attributes
+	userID := request.PathParameters["userid"]
+	key, err := cart.Key(userID, request.QueryStringParameters["cart"])
+	if err != nil {
+		return handleError("creating cart key", headers, err)
+	}
+
+	// Changes are appended to the event log when CART_EVENT_LOG is set
+	dynamoStore, err := logs.Wrap(dynamodb.New())
+	if err != nil {
+		return handleError("opening event log", headers, err)
+	}
+
+	// Stock is reserved when an inventory is configured
+	inv, err := sources.FromEnv()
+	if err != nil {
+		return handleError("configuring inventory", headers, err)
+	}
+
+	// Check that the cart didn't change since the caller read it
+	version, err := cart.CheckIfMatch(dynamoStore, key, cart.IfMatchFromHeaders(request.Headers))
+	if err != nil {
+		return handleError("checking cart version", headers, err)
+	}
+
+	// Only the owner can check out a cart
+	err = cart.Authorize(dynamoStore, key, cart.Caller(request.Headers, userID), datastore.RoleOwner)
+	if err != nil {
+		return handleError("authorizing user", headers, err)
+	}
+
+	publisher, err := publishers.FromEnv()
+	if err != nil {
+		return handleError("creating event publisher", headers, err)
+	}
+
+	archive, err := cart.ParseCheckoutMode(os.Getenv("CHECKOUT_MODE"))
+	if err != nil {
+		return handleError("parsing CHECKOUT_MODE", headers, err)
+	}
+
+	lockTTL := time.Minute
+	if len(os.Getenv("CHECKOUT_LOCK_TTL")) > 0 {
+		lockTTL, err = time.ParseDuration(os.Getenv("CHECKOUT_LOCK_TTL"))
+		if err != nil {
+			return handleError("parsing CHECKOUT_LOCK_TTL", headers, err)
+		}
+	}
+
+	opts := cart.CheckoutOptions{
+		Publisher: publisher,
+		Source:    "CheckoutCart",
+		Archive:   archive,
+		LockTTL:   lockTTL,
+		Version:   version,
+	}
+
+	// Validate the prices against the catalog
+	if catalogURL := os.Getenv("CATALOG_URL"); len(catalogURL) > 0 {
+		opts.Catalog = httpclient.New(catalogURL)
+	}
+
+	details, err := cart.Checkout(dynamoStore, key, opts)
+
+	// When prices have changed, the cart is updated and the changes are returned
+	// so the user can review them before checking out again
+	var pce *cart.PricesChangedError
+	if errors.As(err, &pce) {
+		payload, err := pce.Result.Marshal()
+		if err != nil {
+			return handleError("marshalling response", headers, err)
+		}
+
+		response := events.APIGatewayProxyResponse{
+			StatusCode: http.StatusConflict,
+			Body:       string(payload),
+			Headers:    headers,
+		}
+
+		return response, nil
+	}
+
+	if err != nil {
+		return handleError("checking out cart", headers, err)
+	}
+
+	// The items are handed off to the order service, so the stock doesn't need to be held anymore
+	if inv != nil {
+		err = inventory.Sync(inv, key, details.Items, nil)
+		if err != nil {
+			sentry.CaptureException(fmt.Errorf("error releasing stock (request %s): %s", headers[cart.RequestIDHeader], err.Error()))
+		}
+	}
+
+	payload, err := details.Marshal()
+	if err != nil {
+		return handleError("marshalling response", headers, err)
+	}
+
+	response := events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(payload),
+		Headers:    headers,
+	}
+
+	return response, nil
+}
+
+// handleError takes the activity where the error occured and the error object and sends a message to sentry.
+// The error is returned as problem details in the appropriate API Gateway Proxy Response.
+func handleError(area string, headers map[string]string, err error) (events.APIGatewayProxyResponse, error) {
+	msg := fmt.Sprintf("error %s (request %s): %s", area, headers[cart.RequestIDHeader], err.Error())
+	sentry.CaptureException(errors.New(msg))
+	log.Println(msg)
+	return problem.Response(headers, err), nil
+}
+
+// The main method is executed by AWS Lambda and points to the handler
+func main() {
+	db := dynamodb.New()
+
+	// Requests are authenticated and validated against the OpenAPI specification, and
+	// requests with an Idempotency-Key header are only handled once
+	h := openapi.Lambda(idempotency.Lambda(db, handler, handleError), handleError)
+	lambda.Start(wflambda.Wrapper(auth.Lambda(db, h, handleError)))
+}
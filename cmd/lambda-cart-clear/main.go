@@ -2,6 +2,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -11,7 +12,15 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/getsentry/sentry-go"
+	"github.com/retgits/acme-serverless-cart/internal/auth"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
 	"github.com/retgits/acme-serverless-cart/internal/datastore/dynamodb"
+	"github.com/retgits/acme-serverless-cart/internal/eventlog/logs"
+	"github.com/retgits/acme-serverless-cart/internal/inventory"
+	"github.com/retgits/acme-serverless-cart/internal/inventory/sources"
+	"github.com/retgits/acme-serverless-cart/internal/openapi"
+	"github.com/retgits/acme-serverless-cart/internal/problem"
 	wflambda "github.com/wavefronthq/wavefront-lambda-go"
 )
 
@@ -28,24 +37,64 @@ func handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyRespo
 		Environment: os.Getenv("STAGE"),
 	})
 
-	// Create headers if they don't exist and add
-	// the CORS required headers, otherwise the response
-	// will not be accepted by browsers.
-	headers := request.Headers
-	if headers == nil {
-		headers = make(map[string]string)
+	// Create the headers of the response with the CORS required
+	// headers, otherwise the response will not be accepted by
+	// browsers. Headers of the request, like the Authorization
+	// header, are never sent back.
+	headers := map[string]string{
+		"Access-Control-Allow-Origin": "*",
+		"Content-Type":                "application/json",
+		cart.RequestIDHeader:          request.RequestContext.RequestID,
 	}
-	headers["Access-Control-Allow-Origin"] = "*"
 
 	// Create the key attributes
 	userID := request.PathParameters["userid"]
+	key, err := cart.Key(userID, request.QueryStringParameters["cart"])
+	if err != nil {
+		return handleError("creating cart key", headers, err)
+	}
+
+	// Changes are appended to the event log when CART_EVENT_LOG is set
+	dynamoStore, err := logs.Wrap(dynamodb.New())
+	if err != nil {
+		return handleError("opening event log", headers, err)
+	}
+
+	// Stock is reserved when an inventory is configured
+	inv, err := sources.FromEnv()
+	if err != nil {
+		return handleError("configuring inventory", headers, err)
+	}
 
-	dynamoStore := dynamodb.New()
-	err := dynamoStore.ClearCart(userID)
+	// Check that the cart didn't change since the caller read it
+	version, err := cart.CheckIfMatch(dynamoStore, key, cart.IfMatchFromHeaders(request.Headers))
+	if err != nil {
+		return handleError("checking cart version", headers, err)
+	}
+
+	// Get the items so the reserved stock can be released
+	var cartItems datastore.CartItems
+	if inv != nil {
+		cartItems, err = dynamoStore.GetItems(key)
+		if err != nil {
+			return handleError("getting items", headers, err)
+		}
+	}
+
+	err = cart.ClearCart(dynamoStore, key, version)
 	if err != nil {
 		return handleError("clearing cart", headers, err)
 	}
 
+	// Release the reserved stock. The cart has been cleared at this point,
+	// so failures are only reported since the holds will expire anyway.
+	if inv != nil {
+		err = inventory.Sync(inv, key, cartItems, nil)
+		if err != nil {
+			sentry.CaptureException(fmt.Errorf("error releasing stock (request %s): %s", headers[cart.RequestIDHeader], err.Error()))
+		}
+	}
+
 	response := events.APIGatewayProxyResponse{
 		StatusCode: http.StatusOK,
 		Headers:    headers,
@@ -55,19 +104,16 @@ func handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyRespo
 }
 
 // handleError takes the activity where the error occured and the error object and sends a message to sentry.
-// The original error, together with the appropriate API Gateway Proxy Response, is returned so it can be thrown.
+// The error is returned as problem details in the appropriate API Gateway Proxy Response.
 func handleError(area string, headers map[string]string, err error) (events.APIGatewayProxyResponse, error) {
-	sentry.CaptureException(fmt.Errorf("error %s: %s", area, err.Error()))
-	msg := fmt.Sprintf("error %s: %s", area, err.Error())
+	msg := fmt.Sprintf("error %s (request %s): %s", area, headers[cart.RequestIDHeader], err.Error())
+	sentry.CaptureException(errors.New(msg))
 	log.Println(msg)
-	return events.APIGatewayProxyResponse{
-		StatusCode: http.StatusBadRequest,
-		Body:       msg,
-		Headers:    headers,
-	}, nil
+	return problem.Response(headers, err), nil
 }
 
 // The main method is executed by AWS Lambda and points to the handler
 func main() {
-	lambda.Start(wflambda.Wrapper(handler))
+	// Requests are authenticated and validated against the OpenAPI specification
+	lambda.Start(wflambda.Wrapper(auth.Lambda(dynamodb.New(), openapi.Lambda(handler, handleError), handleError)))
 }
@@ -0,0 +1,133 @@
+// Delete a named cart of a user
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/getsentry/sentry-go"
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-cart/internal/auth"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/retgits/acme-serverless-cart/internal/datastore/dynamodb"
+	"github.com/retgits/acme-serverless-cart/internal/eventlog/logs"
+	"github.com/retgits/acme-serverless-cart/internal/idempotency"
+	"github.com/retgits/acme-serverless-cart/internal/inventory"
+	"github.com/retgits/acme-serverless-cart/internal/inventory/sources"
+	"github.com/retgits/acme-serverless-cart/internal/openapi"
+	"github.com/retgits/acme-serverless-cart/internal/problem"
+	wflambda "github.com/wavefronthq/wavefront-lambda-go"
+)
+
+// handler handles the API Gateway events and returns an error if anything goes wrong.
+func handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Initiialize a connection to Sentry to capture errors and traces
+	sentry.Init(sentry.ClientOptions{
+		Dsn: os.Getenv("SENTRY_DSN"),
+		Transport: &sentry.HTTPSyncTransport{
+			Timeout: time.Second * 3,
+		},
+		ServerName:  os.Getenv("FUNCTION_NAME"),
+		Release:     os.Getenv("VERSION"),
+		Environment: os.Getenv("STAGE"),
+	})
+
+	// Create the headers of the response with the CORS required
+	// headers, otherwise the response will not be accepted by
+	// browsers. Headers of the request, like the Authorization
+	// header, are never sent back.
+	headers := map[string]string{
+		"Access-Control-Allow-Origin": "*",
+		"Content-Type":                "application/json",
+		cart.RequestIDHeader:          request.RequestContext.RequestID,
+	}
+
+	// Create the key attributes
+	userID := request.PathParameters["userid"]
+	cartID := request.QueryStringParameters["cart"]
+	if _, err := cart.Key(userID, cartID); err != nil {
+		return handleError("creating cart key", headers, err)
+	}
+	if len(cartID) == 0 {
+		return handleError("deleting cart", headers, fmt.Errorf("%w: the cart query parameter is required", datastore.ErrInvalidRequest))
+	}
+
+	// Changes are appended to the event log when CART_EVENT_LOG is set
+	dynamoStore, err := logs.Wrap(dynamodb.New())
+	if err != nil {
+		return handleError("opening event log", headers, err)
+	}
+
+	// Stock is reserved when an inventory is configured
+	inv, err := sources.FromEnv()
+	if err != nil {
+		return handleError("configuring inventory", headers, err)
+	}
+
+	// Get the items so the reserved stock can be released
+	key := datastore.CartKey(userID, cartID)
+	var cartItems datastore.CartItems
+	if inv != nil {
+		cartItems, err = dynamoStore.GetItems(key)
+		if err != nil {
+			return handleError("getting items", headers, err)
+		}
+	}
+
+	err = dynamoStore.DeleteCart(userID, cartID)
+	if err != nil {
+		return handleError("deleting cart", headers, err)
+	}
+
+	// Release the reserved stock. The cart has been deleted at this point,
+	// so failures are only reported since the holds will expire anyway.
+	if inv != nil {
+		err = inventory.Sync(inv, key, cartItems, nil)
+		if err != nil {
+			sentry.CaptureException(fmt.Errorf("error releasing stock (request %s): %s", headers[cart.RequestIDHeader], err.Error()))
+		}
+	}
+
+	res := acmeserverless.UserIDResponse{
+		UserID: userID,
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		return handleError("marshalling response", headers, err)
+	}
+
+	response := events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(payload),
+		Headers:    headers,
+	}
+
+	return response, nil
+}
+
+// handleError takes the activity where the error occured and the error object and sends a message to sentry.
+// The error is returned as problem details in the appropriate API Gateway Proxy Response.
+func handleError(area string, headers map[string]string, err error) (events.APIGatewayProxyResponse, error) {
+	msg := fmt.Sprintf("error %s (request %s): %s", area, headers[cart.RequestIDHeader], err.Error())
+	sentry.CaptureException(errors.New(msg))
+	log.Println(msg)
+	return problem.Response(headers, err), nil
+}
+
+// The main method is executed by AWS Lambda and points to the handler
+func main() {
+	db := dynamodb.New()
+
+	// Requests are authenticated and validated against the OpenAPI specification, and
+	// requests with an Idempotency-Key header are only handled once
+	h := openapi.Lambda(idempotency.Lambda(db, handler, handleError), handleError)
+	lambda.Start(wflambda.Wrapper(auth.Lambda(db, h, handleError)))
+}
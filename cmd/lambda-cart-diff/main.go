@@ -0,0 +1,87 @@
+// Compare two versions of a cart
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/getsentry/sentry-go"
+	"github.com/retgits/acme-serverless-cart/internal/auth"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore/dynamodb"
+	"github.com/retgits/acme-serverless-cart/internal/openapi"
+	"github.com/retgits/acme-serverless-cart/internal/problem"
+	wflambda "github.com/wavefronthq/wavefront-lambda-go"
+)
+
+// handler handles the API Gateway events and returns an error if anything goes wrong.
+func handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Initiialize a connection to Sentry to capture errors and traces
+	sentry.Init(sentry.ClientOptions{
+		Dsn: os.Getenv("SENTRY_DSN"),
+		Transport: &sentry.HTTPSyncTransport{
+			Timeout: time.Second * 3,
+		},
+		ServerName:  os.Getenv("FUNCTION_NAME"),
+		Release:     os.Getenv("VERSION"),
+		Environment: os.Getenv("STAGE"),
+	})
+
+	// Create the headers of the response with the CORS required
+	// headers, otherwise the response will not be accepted by
+	// browsers. Headers of the request, like the Authorization
+	// header, are never sent back.
+	headers := map[string]string{
+		"Access-Control-Allow-Origin": "*",
+		"Content-Type":                "application/json",
+		cart.RequestIDHeader:          request.RequestContext.RequestID,
+	}
+
+	// Create the key attributes
+	userID := request.PathParameters["userid"]
+	key, err := cart.Key(userID, request.QueryStringParameters["cart"])
+	if err != nil {
+		return handleError("creating cart key", headers, err)
+	}
+
+	dynamoStore := dynamodb.New()
+
+	res, err := cart.DiffVersions(dynamoStore, key, request.QueryStringParameters["from"], request.QueryStringParameters["to"])
+	if err != nil {
+		return handleError("comparing versions", headers, err)
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		return handleError("marshalling response", headers, err)
+	}
+
+	response := events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(payload),
+		Headers:    headers,
+	}
+
+	return response, nil
+}
+
+// handleError takes the activity where the error occured and the error object and sends a message to sentry.
+// The error is returned as problem details in the appropriate API Gateway Proxy Response.
+func handleError(area string, headers map[string]string, err error) (events.APIGatewayProxyResponse, error) {
+	msg := fmt.Sprintf("error %s (request %s): %s", area, headers[cart.RequestIDHeader], err.Error())
+	sentry.CaptureException(errors.New(msg))
+	log.Println(msg)
+	return problem.Response(headers, err), nil
+}
+
+// The main method is executed by AWS Lambda and points to the handler
+func main() {
+	// Requests are authenticated and validated against the OpenAPI specification
+	lambda.Start(wflambda.Wrapper(auth.Lambda(dynamodb.New(), openapi.Lambda(handler, handleError), handleError)))
+}
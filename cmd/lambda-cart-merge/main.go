@@ -0,0 +1,138 @@
+// Merge a guest cart into the cart of a user
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/getsentry/sentry-go"
+	"github.com/retgits/acme-serverless-cart/internal/auth"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/retgits/acme-serverless-cart/internal/datastore/dynamodb"
+	"github.com/retgits/acme-serverless-cart/internal/eventlog/logs"
+	"github.com/retgits/acme-serverless-cart/internal/idempotency"
+	"github.com/retgits/acme-serverless-cart/internal/inventory"
+	"github.com/retgits/acme-serverless-cart/internal/inventory/sources"
+	"github.com/retgits/acme-serverless-cart/internal/openapi"
+	"github.com/retgits/acme-serverless-cart/internal/problem"
+	wflambda "github.com/wavefronthq/wavefront-lambda-go"
+)
+
+// handler handles the API Gateway events and returns an error if anything goes wrong.
+func handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Initiialize a connection to Sentry to capture errors and traces
+	sentry.Init(sentry.ClientOptions{
+		Dsn: os.Getenv("SENTRY_DSN"),
+		Transport: &sentry.HTTPSyncTransport{
+			Timeout: time.Second * 3,
+		},
+		ServerName:  os.Getenv("FUNCTION_NAME"),
+		Release:     os.Getenv("VERSION"),
+		Environment: os.Getenv("STAGE"),
+	})
+
+	// Create the headers of the response with the CORS required
+	// headers, otherwise the response will not be accepted by
+	// browsers. Headers of the request, like the Authorization
+	// header, are never sent back.
+	headers := map[string]string{
+		"Access-Control-Allow-Origin": "*",
+		"Content-Type":                "application/json",
+		cart.RequestIDHeader:          request.RequestContext.RequestID,
+	}
+
+	req, err := cart.UnmarshalMergeRequest([]byte(request.Body))
+	if err != nil {
+		return handleError("unmarshalling merge request", headers, err)
+	}
+
+	// Only the user can merge a cart into their own cart
+	err = cart.Authorize(dynamodb.New(), req.UserID, cart.Caller(request.Headers, req.UserID), datastore.RoleOwner)
+	if err != nil {
+		return handleError("authorizing user", headers, err)
+	}
+
+	// Changes are appended to the event log when CART_EVENT_LOG is set
+	dynamoStore, err := logs.Wrap(dynamodb.New())
+	if err != nil {
+		return handleError("opening event log", headers, err)
+	}
+
+	// Stock is reserved when an inventory is configured
+	inv, err := sources.FromEnv()
+	if err != nil {
+		return handleError("configuring inventory", headers, err)
+	}
+
+	res, err := cart.MergeCarts(dynamoStore, req, os.Getenv("MERGE_POLICY"))
+	if err != nil {
+		return handleError("merging carts", headers, err)
+	}
+
+	// Move the reserved stock from the guest to the user
+	if inv != nil {
+		err = inventory.Sync(inv, req.GuestID, res.GuestItems, nil)
+		if err != nil {
+			return handleError("releasing stock", headers, err)
+		}
+
+		err = inventory.Sync(inv, req.UserID, res.UserItems, res.Items)
+		if err != nil {
+			inventory.Revert(inv, req.GuestID, res.GuestItems, nil)
+			return handleError("reserving stock", headers, err)
+		}
+	}
+
+	err = dynamoStore.MoveCart(req.GuestID, req.UserID, res.Items)
+	if err != nil {
+		if inv != nil {
+			inventory.Revert(inv, req.UserID, res.UserItems, res.Items)
+			inventory.Revert(inv, req.GuestID, res.GuestItems, nil)
+		}
+		return handleError("storing merged cart", headers, err)
+	}
+
+	ct := datastore.Cart{
+		Items:  res.Items,
+		UserID: req.UserID,
+	}
+
+	payload, err := ct.Marshal()
+	if err != nil {
+		return handleError("marshalling response", headers, err)
+	}
+
+	response := events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(payload),
+		Headers:    headers,
+	}
+
+	return response, nil
+}
+
+// handleError takes the activity where the error occured and the error object and sends a message to sentry.
+// The error is returned as problem details in the appropriate API Gateway Proxy Response.
+func handleError(area string, headers map[string]string, err error) (events.APIGatewayProxyResponse, error) {
+	msg := fmt.Sprintf("error %s (request %s): %s", area, headers[cart.RequestIDHeader], err.Error())
+	sentry.CaptureException(errors.New(msg))
+	log.Println(msg)
+	return problem.Response(headers, err), nil
+}
+
+// The main method is executed by AWS Lambda and points to the handler
+func main() {
+	db := dynamodb.New()
+
+	// Requests are authenticated and validated against the OpenAPI specification, and
+	// requests with an Idempotency-Key header are only handled once
+	h := openapi.Lambda(idempotency.Lambda(db, handler, handleError), handleError)
+	lambda.Start(wflambda.Wrapper(auth.Lambda(db, h, handleError)))
+}
@@ -2,6 +2,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,7 +13,19 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/getsentry/sentry-go"
 	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-cart/internal/auth"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/catalog"
+	"github.com/retgits/acme-serverless-cart/internal/catalog/httpclient"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
 	"github.com/retgits/acme-serverless-cart/internal/datastore/dynamodb"
+	"github.com/retgits/acme-serverless-cart/internal/eventlog/logs"
+	"github.com/retgits/acme-serverless-cart/internal/idempotency"
+	"github.com/retgits/acme-serverless-cart/internal/inventory"
+	"github.com/retgits/acme-serverless-cart/internal/inventory/sources"
+	"github.com/retgits/acme-serverless-cart/internal/openapi"
+	"github.com/retgits/acme-serverless-cart/internal/problem"
+	"github.com/retgits/acme-serverless-cart/internal/rules"
 	wflambda "github.com/wavefronthq/wavefront-lambda-go"
 )
 
@@ -29,27 +42,94 @@ func handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyRespo
 		Environment: os.Getenv("STAGE"),
 	})
 
-	// Create headers if they don't exist and add
-	// the CORS required headers, otherwise the response
-	// will not be accepted by browsers.
-	headers := request.Headers
-	if headers == nil {
-		headers = make(map[string]string)
+	// Create the headers of the response with the CORS required
+	// headers, otherwise the response will not be accepted by
+	// browsers. Headers of the request, like the Authorization
+	// header, are never sent back.
+	headers := map[string]string{
+		"Access-Control-Allow-Origin": "*",
+		"Content-Type":                "application/json",
+		cart.RequestIDHeader:          request.RequestContext.RequestID,
 	}
-	headers["Access-Control-Allow-Origin"] = "*"
 
 	// Create the key attributes
 	userID := request.PathParameters["userid"]
+	key, err := cart.Key(userID, request.QueryStringParameters["cart"])
+	if err != nil {
+		return handleError("creating cart key", headers, err)
+	}
+
+	// Changes are appended to the event log when CART_EVENT_LOG is set
+	dynamoStore, err := logs.Wrap(dynamodb.New())
+	if err != nil {
+		return handleError("opening event log", headers, err)
+	}
+
+	// Stock is reserved when an inventory is configured
+	inv, err := sources.FromEnv()
+	if err != nil {
+		return handleError("configuring inventory", headers, err)
+	}
 
-	dynamoStore := dynamodb.New()
+	// Check that the cart didn't change since the caller read it
+	version, err := cart.CheckIfMatch(dynamoStore, key, cart.IfMatchFromHeaders(request.Headers))
+	if err != nil {
+		return handleError("checking cart version", headers, err)
+	}
 
-	crt, err := acmeserverless.UnmarshalCart(request.Body)
+	crt, err := datastore.UnmarshalCart(request.Body)
 	if err != nil {
 		return handleError("unmarshalling items", headers, err)
 	}
 
-	err = dynamoStore.StoreItems(userID, crt.Items)
+	// Validate the items against the catalog, bundles get their price and components
+	var catalogClient catalog.CatalogClient
+	if catalogURL := os.Getenv("CATALOG_URL"); len(catalogURL) > 0 {
+		catalogClient = httpclient.New(catalogURL)
+	}
+
+	bundles, err := cart.BundlesFromEnv()
 	if err != nil {
+		return handleError("loading bundles", headers, err)
+	}
+
+	crt.Items, err = cart.ValidateItems(catalogClient, bundles, crt.Items)
+	if err != nil {
+		return handleError("validating items", headers, err)
+	}
+
+	cartItems, err := dynamoStore.GetItems(key)
+	if err != nil && !errors.Is(err, datastore.ErrCartNotFound) {
+		return handleError("getting items", headers, err)
+	}
+
+	// Lines that were already in the cart keep who added them
+	cart.Attribute(cartItems, crt.Items, cart.Caller(request.Headers, userID))
+
+	// Check that the cart follows the business rules
+	cartRules, err := rules.FromEnv()
+	if err != nil {
+		return handleError("loading rules", headers, err)
+	}
+
+	err = cartRules.Check(crt.Items, rules.RegionFromHeaders(request.Headers))
+	if err != nil {
+		return handleError("checking rules", headers, err)
+	}
+
+	// Update the reserved stock for the items
+	if inv != nil {
+		err = inventory.Sync(inv, key, cartItems, crt.Items)
+		if err != nil {
+			return handleError("reserving stock", headers, err)
+		}
+	}
+
+	err = cart.StoreItems(dynamoStore, key, crt.Items, version)
+	if err != nil {
+		if inv != nil {
+			inventory.Revert(inv, key, cartItems, crt.Items)
+		}
 		return handleError("storing items", headers, err)
 	}
 
@@ -72,19 +152,20 @@ func handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyRespo
 }
 
 // handleError takes the activity where the error occured and the error object and sends a message to sentry.
-// The original error, together with the appropriate API Gateway Proxy Response, is returned so it can be thrown.
+// The error is returned as problem details in the appropriate API Gateway Proxy Response.
 func handleError(area string, headers map[string]string, err error) (events.APIGatewayProxyResponse, error) {
-	sentry.CaptureException(fmt.Errorf("error %s: %s", area, err.Error()))
-	msg := fmt.Sprintf("error %s: %s", area, err.Error())
+	msg := fmt.Sprintf("error %s (request %s): %s", area, headers[cart.RequestIDHeader], err.Error())
+	sentry.CaptureException(errors.New(msg))
 	log.Println(msg)
-	return events.APIGatewayProxyResponse{
-		StatusCode: http.StatusBadRequest,
-		Body:       msg,
-		Headers:    headers,
-	}, nil
+	return problem.Response(headers, err), nil
 }
 
 // The main method is executed by AWS Lambda and points to the handler
 func main() {
-	lambda.Start(wflambda.Wrapper(handler))
+	db := dynamodb.New()
+
+	// Requests are authenticated and validated against the OpenAPI specification, and
+	// requests with an Idempotency-Key header are only handled once
+	h := openapi.Lambda(idempotency.Lambda(db, handler, handleError), handleError)
+	lambda.Start(wflambda.Wrapper(auth.Lambda(db, h, handleError)))
 }
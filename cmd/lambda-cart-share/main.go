@@ -0,0 +1,135 @@
+// Create an invite to share a cart with another user
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/getsentry/sentry-go"
+	"github.com/retgits/acme-serverless-cart/internal/auth"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/retgits/acme-serverless-cart/internal/datastore/dynamodb"
+	"github.com/retgits/acme-serverless-cart/internal/idempotency"
+	"github.com/retgits/acme-serverless-cart/internal/openapi"
+	"github.com/retgits/acme-serverless-cart/internal/problem"
+	wflambda "github.com/wavefronthq/wavefront-lambda-go"
+)
+
+// handler handles the API Gateway events and returns an error if anything goes wrong.
+func handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	// Initiialize a connection to Sentry to capture errors and traces
+	sentry.Init(sentry.ClientOptions{
+		Dsn: os.Getenv("SENTRY_DSN"),
+		Transport: &sentry.HTTPSyncTransport{
+			Timeout: time.Second * 3,
+		},
+		ServerName:  os.Getenv("FUNCTION_NAME"),
+		Release:     os.Getenv("VERSION"),
+		Environment: os.Getenv("STAGE"),
+	})
+
+	// Create the headers of the response with the CORS required
+	// headers, otherwise the response will not be accepted by
+	// browsers. Headers of the request, like the Authorization
+	// header, are never sent back.
+	headers := map[string]string{
+		"Access-Control-Allow-Origin": "*",
+		"Content-Type":                "application/json",
+		cart.RequestIDHeader:          request.RequestContext.RequestID,
+	}
+
+	// Create the key attributes
+	userID := request.PathParameters["userid"]
+	key, err := cart.Key(userID, request.QueryStringParameters["cart"])
+	if err != nil {
+		return handleError("creating cart key", headers, err)
+	}
+
+	dynamoStore := dynamodb.New()
+
+	// Only the owner can share a cart
+	err = cart.Authorize(dynamoStore, key, cart.Caller(request.Headers, userID), datastore.RoleOwner)
+	if err != nil {
+		return handleError("authorizing user", headers, err)
+	}
+
+	req, err := cart.UnmarshalShareRequest([]byte(request.Body))
+	if err != nil {
+		return handleError("unmarshalling share request", headers, err)
+	}
+
+	role, err := cart.ParseRole(req.Role)
+	if err != nil {
+		return handleError("parsing role", headers, err)
+	}
+
+	// Make sure the cart exists before inviting others to it
+	_, err = dynamoStore.Members(key)
+	if err != nil {
+		return handleError("getting members", headers, err)
+	}
+
+	ttl := time.Hour * 72
+	if len(os.Getenv("CART_INVITE_TTL")) > 0 {
+		ttl, err = time.ParseDuration(os.Getenv("CART_INVITE_TTL"))
+		if err != nil {
+			return handleError("parsing CART_INVITE_TTL", headers, err)
+		}
+	}
+
+	expires := time.Now().Add(ttl)
+
+	token, err := cart.NewInviteToken([]byte(os.Getenv("CART_INVITE_SECRET")), cart.Invite{
+		Key:     key,
+		Role:    role,
+		Expires: expires.Unix(),
+	})
+	if err != nil {
+		return handleError("creating invite", headers, err)
+	}
+
+	res := cart.ShareResponse{
+		Token:   token,
+		Role:    role,
+		Expires: expires.UTC(),
+	}
+
+	payload, err := res.Marshal()
+	if err != nil {
+		return handleError("marshalling response", headers, err)
+	}
+
+	response := events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Body:       string(payload),
+		Headers:    headers,
+	}
+
+	return response, nil
+}
+
+// handleError takes the activity where the error occured and the error object and sends a message to sentry.
+// The error is returned as problem details in the appropriate API Gateway Proxy Response.
+func handleError(area string, headers map[string]string, err error) (events.APIGatewayProxyResponse, error) {
+	msg := fmt.Sprintf("error %s (request %s): %s", area, headers[cart.RequestIDHeader], err.Error())
+	sentry.CaptureException(errors.New(msg))
+	log.Println(msg)
+	return problem.Response(headers, err), nil
+}
+
+// The main method is executed by AWS Lambda and points to the handler
+func main() {
+	db := dynamodb.New()
+
+	// Requests are authenticated and validated against the OpenAPI specification, and
+	// requests with an Idempotency-Key header are only handled once
+	h := openapi.Lambda(idempotency.Lambda(db, handler, handleError), handleError)
+	lambda.Start(wflambda.Wrapper(auth.Lambda(db, h, handleError)))
+}
@@ -9,6 +9,7 @@ require (
 	github.com/aws/aws-sdk-go v1.30.7
 	github.com/fasthttp/router v1.0.2
 	github.com/getsentry/sentry-go v0.6.0
+	github.com/nats-io/nats.go v1.9.1
 	github.com/pulumi/pulumi-aws/sdk/v2 v2.0.0
 	github.com/pulumi/pulumi/sdk/v2 v2.0.0
 	github.com/retgits/acme-serverless v0.3.0
@@ -17,4 +18,5 @@ require (
 	github.com/valyala/fasthttp v1.10.0
 	github.com/wavefronthq/wavefront-lambda-go v0.0.0-20190812171804-d9475d6695cc
 	go.mongodb.org/mongo-driver v1.4.0-beta1.0.20200416213727-891a5fc9374a
+	gopkg.in/yaml.v2 v2.2.8
 )
@@ -0,0 +1,101 @@
+// Package audit keeps a record of every request that uses, or tries to use, the rights of
+// an admin, like getting the carts of all users or clearing the cart of another user, so
+// it can be checked who accessed which carts. In order to keep the records somewhere else, the
+// Recorder interface needs to be implemented.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Event is the event of every record, so records can be found between other logs
+const Event = "admin_access"
+
+// Record describes a request that needed the rights of an admin
+type Record struct {
+	// Event is always Event
+	Event string `json:"event"`
+
+	// Time is when the request was made
+	Time time.Time `json:"time"`
+
+	// RequestID is the ID of the request
+	RequestID string `json:"requestid"`
+
+	// UserID is the ID of the user that made the request
+	UserID string `json:"userid"`
+
+	// Operation is the method and route of the request, like GET /cart/all
+	Operation string `json:"operation"`
+
+	// Path is the path of the request
+	Path string `json:"path"`
+
+	// Owner is the user whose cart was accessed, or empty for fleet-wide operations
+	Owner string `json:"owner,omitempty"`
+
+	// Cart is the name of the cart that was accessed
+	Cart string `json:"cart,omitempty"`
+
+	// Allowed is true when the request was handled
+	Allowed bool `json:"allowed"`
+}
+
+// Recorder is the interface that describes the methods a place to keep audit records
+// needs to implement.
+type Recorder interface {
+	// Record keeps the record. Requests are only handled when their record is kept.
+	Record(r Record) error
+}
+
+// writer writes records as JSON, one record per line
+type writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriter creates a Recorder that writes records to w as JSON, one record per line.
+func NewWriter(w io.Writer) Recorder {
+	return &writer{w: w}
+}
+
+// Record writes the record
+func (w *writer) Record(r Record) error {
+	r.Event = Event
+	if r.Time.IsZero() {
+		r.Time = time.Now().UTC()
+	}
+
+	payload, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	_, err = w.w.Write(append(payload, '\n'))
+	return err
+}
+
+// FromEnv creates the Recorder that appends records to the file in AUDIT_LOG_FILE. When
+// AUDIT_LOG_FILE isn't set, records are written to stdout, which ends up in the logs of
+// Cloud Run and AWS Lambda.
+func FromEnv() (Recorder, error) {
+	filename := os.Getenv("AUDIT_LOG_FILE")
+	if len(filename) == 0 {
+		return NewWriter(os.Stdout), nil
+	}
+
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open AUDIT_LOG_FILE: %s", err.Error())
+	}
+
+	return NewWriter(f), nil
+}
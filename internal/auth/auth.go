@@ -0,0 +1,260 @@
+// Package auth contains the interface that the Cart service uses to authenticate the
+// users making requests. The user ID of an authenticated request replaces the X-User-ID
+// header, so clients can't make requests on behalf of other users. Fleet-wide operations
+// can only be used by admins, and every use of the rights of an admin is recorded in the
+// audit log. In order to add a new way to authenticate users, the Authenticator interface
+// needs to be implemented.
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/retgits/acme-serverless-cart/internal/audit"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+)
+
+const (
+	// Header is the header with the credentials of a request
+	Header = "Authorization"
+
+	// ChallengeHeader is set on responses to requests that aren't authenticated
+	ChallengeHeader = "WWW-Authenticate"
+
+	// Challenge is the value of the ChallengeHeader
+	Challenge = `Bearer realm="cart"`
+
+	// GuestRoute is the route that creates guest carts, which can be used without
+	// credentials
+	GuestRoute = "/cart/guest"
+
+	// AdminAccessHeader is set by Lambda on requests that use the rights of an admin. The
+	// header is removed from the requests that clients make.
+	AdminAccessHeader = "X-Admin-Access"
+)
+
+// ErrUnauthorized is returned when a request has no valid credentials
+var ErrUnauthorized = errors.New("unauthorized")
+
+// adminOperations are the fleet-wide operations, which only admins can use
+var adminOperations = map[string]bool{
+	"GET /cart/all": true,
+}
+
+// supportOperations are the operations that admins can use on the carts of other users
+var supportOperations = map[string]bool{
+	"GET /cart/clear/{userid}":  true,
+	"DELETE /v2/carts/{userid}": true,
+	"GET /cart/export/{userid}": true,
+}
+
+// ownerOperations are the operations that cover all carts of a user, so a role on one of
+// the carts isn't enough to use them on the carts of other users
+var ownerOperations = map[string]bool{
+	"GET /cart/export/{userid}": true,
+}
+
+// Identity is the user that made a request
+type Identity struct {
+	// UserID is the ID of the user
+	UserID string
+
+	// Admin is true when the user can use fleet-wide and support operations
+	Admin bool
+}
+
+// Authenticator is the interface that describes the methods a way to authenticate
+// users needs to implement to be used by the Cart service.
+type Authenticator interface {
+	// Authenticate returns the user with the credentials in the Authorization header of
+	// a request, or ErrUnauthorized when the credentials aren't valid.
+	Authenticate(authorization string) (Identity, error)
+}
+
+// Request is a request that is checked before it is handled
+type Request struct {
+	// RequestID is the ID of the request
+	RequestID string
+
+	// Method is the HTTP method of the request
+	Method string
+
+	// Route is the route of the request, like /cart/items/{userid}
+	Route string
+
+	// Path is the path of the request
+	Path string
+
+	// UserID is the userid in the path, or empty on routes that don't have one
+	UserID string
+
+	// Cart is the cart query parameter
+	Cart string
+
+	// Caller is the X-User-ID header, which is only used when authentication is turned off
+	Caller string
+}
+
+// operation returns the method and route of the request
+func (r Request) operation() string {
+	return r.Method + " " + r.Route
+}
+
+// FromEnv creates the Authenticator that validates JSON Web Tokens with the keys from
+// AUTH_JWKS_URL or AUTH_JWKS_FILE. The ID of the user is taken from the claim in
+// AUTH_USER_CLAIM, or the sub claim when it isn't set. When AUTH_ISSUER or AUTH_AUDIENCE
+// are set, tokens must be issued by and for them. Users with the AUTH_ADMIN_SCOPE scope
+// (cart:admin when it isn't set), or the AUTH_ADMIN_ROLE role (admin when it isn't set)
+// in the AUTH_ROLES_CLAIM claim (roles when it isn't set) are admins. When neither
+// AUTH_JWKS_URL nor AUTH_JWKS_FILE is set, requests aren't authenticated and nil is
+// returned.
+func FromEnv() (Authenticator, error) {
+	url, file := os.Getenv("AUTH_JWKS_URL"), os.Getenv("AUTH_JWKS_FILE")
+
+	var source KeySource
+	switch {
+	case len(url) > 0 && len(file) > 0:
+		return nil, fmt.Errorf("only one of AUTH_JWKS_URL and AUTH_JWKS_FILE can be set")
+	case len(url) > 0:
+		source = URLKeySource(url, time.Second*5)
+	case len(file) > 0:
+		source = FileKeySource(file)
+	default:
+		return nil, nil
+	}
+
+	return NewJWT(source, JWTOptions{
+		UserClaim:  getEnv("AUTH_USER_CLAIM", "sub"),
+		Issuer:     os.Getenv("AUTH_ISSUER"),
+		Audience:   os.Getenv("AUTH_AUDIENCE"),
+		AdminScope: getEnv("AUTH_ADMIN_SCOPE", "cart:admin"),
+		AdminRole:  getEnv("AUTH_ADMIN_ROLE", "admin"),
+		RolesClaim: getEnv("AUTH_ROLES_CLAIM", "roles"),
+	})
+}
+
+// getEnv returns the environment variable with the key, or the fallback when it isn't set
+func getEnv(key string, fallback string) string {
+	if v := os.Getenv(key); len(v) > 0 {
+		return v
+	}
+	return fallback
+}
+
+// BearerToken returns the token in the Authorization header of a request, or
+// ErrUnauthorized when the header doesn't have a bearer token.
+func BearerToken(authorization string) (string, error) {
+	parts := strings.SplitN(strings.TrimSpace(authorization), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") || len(strings.TrimSpace(parts[1])) == 0 {
+		return "", fmt.Errorf("%w: the request has no bearer token", ErrUnauthorized)
+	}
+	return strings.TrimSpace(parts[1]), nil
+}
+
+// Anonymous returns true for requests that don't need credentials. Those are requests
+// to create a guest cart, and requests for guest carts, since the ID of a guest cart can't
+// be guessed.
+func Anonymous(r Request) bool {
+	return r.Route == GuestRoute || cart.IsGuestID(r.UserID)
+}
+
+// CheckUnauthenticated checks a request when authentication is turned off. Since admins
+// can't be recognized, fleet-wide operations and operations that cover all carts of a user
+// are never allowed, and support operations only on the carts of the caller.
+func CheckUnauthenticated(recorder audit.Recorder, r Request) error {
+	op := r.operation()
+	switch {
+	case adminOperations[op], ownerOperations[op]:
+	case supportOperations[op] && len(r.Caller) > 0 && r.Caller != r.UserID:
+	default:
+		return nil
+	}
+
+	err := recorder.Record(record(r, r.Caller, false))
+	if err != nil {
+		return err
+	}
+
+	return fmt.Errorf("%w: %s needs an admin, but authentication is turned off", cart.ErrForbidden, r.operation())
+}
+
+// Check checks that the authenticated user is allowed to make the request. Fleet-wide
+// operations can only be used by admins. Users can always make requests for their own
+// carts, and requests for carts of other users need a role on the cart: viewer to read
+// it, editor to change it. Admins can use support operations, like clearing a cart, on
+// the carts of all users. Check returns true when the request uses the rights of an
+// admin. Those requests, and support operations that are denied, are recorded with the
+// recorder.
+func Check(db datastore.Manager, recorder audit.Recorder, id Identity, r Request) (bool, error) {
+	if adminOperations[r.operation()] {
+		err := recorder.Record(record(r, id.UserID, id.Admin))
+		if err != nil {
+			return false, err
+		}
+
+		if !id.Admin {
+			return false, fmt.Errorf("%w: %s needs an admin", cart.ErrForbidden, r.operation())
+		}
+		return true, nil
+	}
+
+	var err error
+	if ownerOperations[r.operation()] && id.UserID != r.UserID {
+		err = fmt.Errorf("%w: only %s can use %s", cart.ErrForbidden, r.UserID, r.operation())
+	} else {
+		err = CheckUser(db, id.UserID, r.UserID, r.Cart, r.Method)
+	}
+	if err == nil || !errors.Is(err, cart.ErrForbidden) || !supportOperations[r.operation()] {
+		return false, err
+	}
+
+	rerr := recorder.Record(record(r, id.UserID, id.Admin))
+	if rerr != nil {
+		return false, rerr
+	}
+
+	if !id.Admin {
+		return false, err
+	}
+	return true, nil
+}
+
+// record returns the audit record of a request
+func record(r Request, userID string, allowed bool) audit.Record {
+	return audit.Record{
+		RequestID: r.RequestID,
+		UserID:    userID,
+		Operation: r.operation(),
+		Path:      r.Path,
+		Owner:     r.UserID,
+		Cart:      r.Cart,
+		Allowed:   allowed,
+	}
+}
+
+// CheckUser checks that the authenticated caller is allowed to make a request for the
+// cart of the user in the path. Users can always make requests for their own carts, and
+// requests for carts of other users need a role on the cart: viewer to read it, editor to
+// change it. Routes without a user in the path are always allowed.
+func CheckUser(db datastore.Manager, caller string, userID string, cartName string, method string) error {
+	if len(userID) == 0 || caller == userID {
+		return nil
+	}
+
+	key, err := cart.Key(userID, cartName)
+	if err != nil {
+		return err
+	}
+
+	role := datastore.RoleEditor
+	if method == http.MethodGet || method == http.MethodHead {
+		role = datastore.RoleViewer
+	}
+
+	return cart.Authorize(db, key, caller, role)
+}
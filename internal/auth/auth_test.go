@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/retgits/acme-serverless-cart/internal/audit"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+)
+
+// records keeps audit records in memory
+type records []audit.Record
+
+func (r *records) Record(rec audit.Record) error {
+	*r = append(*r, rec)
+	return nil
+}
+
+func TestCheckSupportOperations(t *testing.T) {
+	export := Request{
+		Method: "GET",
+		Route:  "/cart/export/{userid}",
+		Path:   "/cart/export/dan",
+		UserID: "dan",
+	}
+
+	tests := []struct {
+		name    string
+		id      Identity
+		admin   bool
+		err     error
+		records int
+	}{
+		{"owner", Identity{UserID: "dan"}, false, nil, 0},
+		{"admin", Identity{UserID: "support-1", Admin: true}, true, nil, 1},
+		{"other user", Identity{UserID: "erin"}, false, cart.ErrForbidden, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var recorder records
+
+			// Exporting doesn't depend on roles on the carts, so no datastore is needed
+			admin, err := Check(nil, &recorder, tt.id, export)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if admin != tt.admin {
+				t.Errorf("expected admin access %t, got %t", tt.admin, admin)
+			}
+			if len(recorder) != tt.records {
+				t.Fatalf("expected %d audit records, got %d", tt.records, len(recorder))
+			}
+			if tt.records > 0 && (recorder[0].Allowed != tt.id.Admin || recorder[0].UserID != tt.id.UserID || recorder[0].Owner != "dan") {
+				t.Errorf("unexpected audit record %+v", recorder[0])
+			}
+		})
+	}
+}
+
+func TestCheckUnauthenticated(t *testing.T) {
+	tests := []struct {
+		name    string
+		r       Request
+		allowed bool
+	}{
+		{"all carts", Request{Method: "GET", Route: "/cart/all"}, false},
+		{"export", Request{Method: "GET", Route: "/cart/export/{userid}", UserID: "dan", Caller: "dan"}, false},
+		{"clear own cart", Request{Method: "GET", Route: "/cart/clear/{userid}", UserID: "dan"}, true},
+		{"clear own cart as caller", Request{Method: "DELETE", Route: "/v2/carts/{userid}", UserID: "dan", Caller: "dan"}, true},
+		{"clear cart of other user", Request{Method: "GET", Route: "/cart/clear/{userid}", UserID: "dan", Caller: "erin"}, false},
+		{"items of cart", Request{Method: "GET", Route: "/cart/items/{userid}", UserID: "dan", Caller: "erin"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var recorder records
+
+			err := CheckUnauthenticated(&recorder, tt.r)
+			if tt.allowed {
+				if err != nil || len(recorder) != 0 {
+					t.Errorf("expected the request to be allowed without an audit record, got %v and %d records", err, len(recorder))
+				}
+				return
+			}
+			if !errors.Is(err, cart.ErrForbidden) {
+				t.Errorf("expected ErrForbidden, got %v", err)
+			}
+			if len(recorder) != 1 || recorder[0].Allowed {
+				t.Errorf("expected a record of the denied request, got %+v", recorder)
+			}
+		})
+	}
+}
@@ -0,0 +1,531 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // SHA-256 is used by RS256, PS256, and ES256
+	_ "crypto/sha512" // SHA-384 and SHA-512 are used by the other algorithms
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// keysTTL is how long keys are used before they are loaded again
+	keysTTL = time.Hour
+
+	// keysMinAge is how long keys are used before they are loaded again because a token
+	// is signed with a key that isn't known yet, so tokens with made up key IDs can't be
+	// used to overload the source of the keys
+	keysMinAge = time.Minute
+
+	// defaultLeeway is the clock skew allowed when checking the exp and nbf claims
+	defaultLeeway = time.Minute
+
+	// minRSABits is the size RSA keys need to have at least to be used
+	minRSABits = 2048
+)
+
+// KeySource returns a JSON Web Key Set (RFC 7517) with the public keys that tokens are
+// signed with.
+type KeySource func() ([]byte, error)
+
+// URLKeySource returns the key set at the URL, like the jwks_uri of an OpenID Connect
+// provider.
+func URLKeySource(url string, timeout time.Duration) KeySource {
+	client := &http.Client{Timeout: timeout}
+
+	return func() ([]byte, error) {
+		res, err := client.Get(url)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get keys from %s: %s", url, err.Error())
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unable to get keys from %s: status %d", url, res.StatusCode)
+		}
+
+		return ioutil.ReadAll(res.Body)
+	}
+}
+
+// FileKeySource returns the key set in the file.
+func FileKeySource(path string) KeySource {
+	return func() ([]byte, error) {
+		return ioutil.ReadFile(path)
+	}
+}
+
+// JWTOptions are the claims that tokens are checked against
+type JWTOptions struct {
+	// UserClaim is the claim with the ID of the user
+	UserClaim string
+
+	// Issuer is the iss claim tokens must have, or empty to allow any issuer
+	Issuer string
+
+	// Audience is the audience that must be in the aud claim of tokens, or empty to
+	// allow any audience
+	Audience string
+
+	// Leeway is the clock skew allowed when checking the exp and nbf claims, or zero
+	// to allow one minute
+	Leeway time.Duration
+
+	// AdminScope is the scope, in the scope or scp claim, that makes a user an admin, or
+	// empty when admins aren't recognized by their scope
+	AdminScope string
+
+	// AdminRole is the role, in the RolesClaim, that makes a user an admin, or empty when
+	// admins aren't recognized by their role
+	AdminRole string
+
+	// RolesClaim is the claim with the roles of the user
+	RolesClaim string
+}
+
+// JWT authenticates users with JSON Web Tokens (RFC 7519) that are sent as bearer
+// tokens. Tokens must be signed with one of the keys from the KeySource using RS256,
+// RS384, RS512, PS256, PS384, PS512, ES256, ES384, or ES512, and must have an exp claim.
+// RSA keys must be at least 2048 bits.
+type JWT struct {
+	source  KeySource
+	options JWTOptions
+
+	mu     sync.Mutex
+	keys   []publicKey
+	loaded time.Time
+}
+
+// publicKey is a key from a JSON Web Key Set
+type publicKey struct {
+	id  string
+	alg string
+	key crypto.PublicKey
+}
+
+// jwk is a JSON Web Key with the members of RSA and EC public keys
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// jwtHeader is the JOSE header of a token
+type jwtHeader struct {
+	Alg  string   `json:"alg"`
+	Kid  string   `json:"kid"`
+	Crit []string `json:"crit"`
+}
+
+// algorithms are the signature algorithms tokens can be signed with, and the hash they use
+var algorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"PS256": crypto.SHA256,
+	"PS384": crypto.SHA384,
+	"PS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+// curves are the elliptic curves of EC keys, and the algorithm that uses them
+var curves = map[string]struct {
+	curve elliptic.Curve
+	alg   string
+}{
+	"P-256": {elliptic.P256(), "ES256"},
+	"P-384": {elliptic.P384(), "ES384"},
+	"P-521": {elliptic.P521(), "ES512"},
+}
+
+// NewJWT creates a JWT Authenticator that validates tokens with the keys from the
+// source. The keys are loaded when the first token is validated.
+func NewJWT(source KeySource, options JWTOptions) (*JWT, error) {
+	if len(options.UserClaim) == 0 {
+		return nil, fmt.Errorf("the claim with the ID of the user must be set")
+	}
+	if options.Leeway == 0 {
+		options.Leeway = defaultLeeway
+	}
+
+	return &JWT{
+		source:  source,
+		options: options,
+	}, nil
+}
+
+// Authenticate returns the user in the bearer token of the Authorization header
+func (j *JWT) Authenticate(authorization string) (Identity, error) {
+	token, err := BearerToken(authorization)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	claims, err := j.verify(token)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	return j.check(claims)
+}
+
+// verify checks the signature of the token and returns its claims
+func (j *JWT) verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: the token is not a signed JWT", ErrUnauthorized)
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: the header of the token is not valid: %s", ErrUnauthorized, err.Error())
+	}
+	if len(header.Crit) > 0 {
+		return nil, fmt.Errorf("%w: the token has critical header parameters %s", ErrUnauthorized, strings.Join(header.Crit, ", "))
+	}
+
+	hash, ok := algorithms[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("%w: the token is signed with unsupported algorithm %q", ErrUnauthorized, header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: the signature of the token is not valid", ErrUnauthorized)
+	}
+
+	keys, err := j.keysFor(header)
+	if err != nil {
+		return nil, err
+	}
+
+	h := hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	digest := h.Sum(nil)
+
+	verified := false
+	for _, k := range keys {
+		if verifySignature(header.Alg, hash, k.key, digest, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("%w: the signature of the token is not valid", ErrUnauthorized)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: the claims of the token are not valid: %s", ErrUnauthorized, err.Error())
+	}
+
+	return claims, nil
+}
+
+// check validates the registered claims of a token and returns the user
+func (j *JWT) check(claims map[string]interface{}) (Identity, error) {
+	now := time.Now()
+
+	exp, ok := numericDate(claims["exp"])
+	if !ok {
+		return Identity{}, fmt.Errorf("%w: the token has no exp claim", ErrUnauthorized)
+	}
+	if now.After(exp.Add(j.options.Leeway)) {
+		return Identity{}, fmt.Errorf("%w: the token expired at %s", ErrUnauthorized, exp.UTC().Format(time.RFC3339))
+	}
+
+	if _, found := claims["nbf"]; found {
+		nbf, ok := numericDate(claims["nbf"])
+		if !ok || now.Add(j.options.Leeway).Before(nbf) {
+			return Identity{}, fmt.Errorf("%w: the token is not valid yet", ErrUnauthorized)
+		}
+	}
+
+	if len(j.options.Issuer) > 0 {
+		if iss, _ := claims["iss"].(string); iss != j.options.Issuer {
+			return Identity{}, fmt.Errorf("%w: the token is issued by %q", ErrUnauthorized, iss)
+		}
+	}
+
+	if len(j.options.Audience) > 0 && !hasAudience(claims["aud"], j.options.Audience) {
+		return Identity{}, fmt.Errorf("%w: the token is not meant for %q", ErrUnauthorized, j.options.Audience)
+	}
+
+	var userID string
+	switch v := claims[j.options.UserClaim].(type) {
+	case string:
+		userID = v
+	case json.Number:
+		userID = v.String()
+	}
+	if len(userID) == 0 {
+		return Identity{}, fmt.Errorf("%w: the token has no %s claim", ErrUnauthorized, j.options.UserClaim)
+	}
+
+	admin := false
+	if len(j.options.AdminScope) > 0 {
+		admin = contains(claims["scope"], j.options.AdminScope) || contains(claims["scp"], j.options.AdminScope)
+	}
+	if len(j.options.AdminRole) > 0 && len(j.options.RolesClaim) > 0 {
+		admin = admin || contains(claims[j.options.RolesClaim], j.options.AdminRole)
+	}
+
+	return Identity{UserID: userID, Admin: admin}, nil
+}
+
+// keysFor returns the keys that can have signed a token with the header. The keys are
+// loaded again when they are too old, or when no key has the key ID of the token.
+func (j *JWT) keysFor(header jwtHeader) ([]publicKey, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	age := time.Since(j.loaded)
+	if j.keys == nil || age > keysTTL {
+		if err := j.load(); err != nil {
+			return nil, err
+		}
+		age = 0
+	}
+
+	keys := matchingKeys(j.keys, header)
+	if len(keys) == 0 && age > keysMinAge {
+		if err := j.load(); err != nil {
+			return nil, err
+		}
+		keys = matchingKeys(j.keys, header)
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: no key found for the token (kid %q, alg %s)", ErrUnauthorized, header.Kid, header.Alg)
+	}
+
+	return keys, nil
+}
+
+// load gets the keys from the source
+func (j *JWT) load() error {
+	data, err := j.source()
+	if err != nil {
+		return err
+	}
+
+	keys, err := parseKeySet(data)
+	if err != nil {
+		return err
+	}
+
+	j.keys = keys
+	j.loaded = time.Now()
+	return nil
+}
+
+// parseKeySet returns the RSA and EC public keys that can be used for signatures in
+// a JSON Web Key Set. Other keys, and RSA keys shorter than 2048 bits, are ignored.
+func parseKeySet(data []byte) ([]publicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("unable to parse key set: %s", err.Error())
+	}
+
+	keys := make([]publicKey, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if len(k.Use) > 0 && k.Use != "sig" {
+			continue
+		}
+
+		var key crypto.PublicKey
+		var err error
+		switch k.Kty {
+		case "RSA":
+			key, err = rsaKey(k)
+		case "EC":
+			key, err = ecKey(k)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse key %q: %s", k.Kid, err.Error())
+		}
+
+		if rk, ok := key.(*rsa.PublicKey); ok && rk.N.BitLen() < minRSABits {
+			continue
+		}
+
+		keys = append(keys, publicKey{id: k.Kid, alg: k.Alg, key: key})
+	}
+
+	return keys, nil
+}
+
+// rsaKey returns the RSA public key of a JSON Web Key
+func rsaKey(k jwk) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil || len(n) == 0 {
+		return nil, fmt.Errorf("invalid modulus")
+	}
+
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, fmt.Errorf("invalid exponent")
+	}
+
+	exponent := 0
+	for _, b := range e {
+		exponent = exponent<<8 | int(b)
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}, nil
+}
+
+// ecKey returns the elliptic curve public key of a JSON Web Key
+func ecKey(k jwk) (*ecdsa.PublicKey, error) {
+	c, ok := curves[k.Crv]
+	if !ok {
+		return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+	}
+
+	x, errX := base64.RawURLEncoding.DecodeString(k.X)
+	y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+	if errX != nil || errY != nil {
+		return nil, fmt.Errorf("invalid coordinates")
+	}
+
+	key := &ecdsa.PublicKey{Curve: c.curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	if !key.Curve.IsOnCurve(key.X, key.Y) {
+		return nil, fmt.Errorf("the point is not on curve %s", k.Crv)
+	}
+
+	return key, nil
+}
+
+// matchingKeys returns the keys that can be used with the algorithm of the header. When
+// the header has a key ID, only the key with that ID is returned.
+func matchingKeys(keys []publicKey, header jwtHeader) []publicKey {
+	res := make([]publicKey, 0)
+	for _, k := range keys {
+		if len(header.Kid) > 0 && k.id != header.Kid {
+			continue
+		}
+		if len(k.alg) > 0 && k.alg != header.Alg {
+			continue
+		}
+
+		switch key := k.key.(type) {
+		case *rsa.PublicKey:
+			if !strings.HasPrefix(header.Alg, "RS") && !strings.HasPrefix(header.Alg, "PS") {
+				continue
+			}
+		case *ecdsa.PublicKey:
+			if curves[key.Curve.Params().Name].alg != header.Alg {
+				continue
+			}
+		}
+
+		res = append(res, k)
+	}
+	return res
+}
+
+// verifySignature returns true when the signature of the digest is valid for the key
+func verifySignature(alg string, hash crypto.Hash, key crypto.PublicKey, digest []byte, signature []byte) bool {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if strings.HasPrefix(alg, "PS") {
+			return rsa.VerifyPSS(key, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+		}
+		return rsa.VerifyPKCS1v15(key, hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(key, digest, r, s)
+	}
+	return false
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a token
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+// numericDate returns the time of a NumericDate claim, like exp and nbf
+func numericDate(v interface{}) (time.Time, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(int64(f), 0), true
+}
+
+// contains returns true when a claim with a list of values, which is either a space
+// separated string or an array of strings, contains the value
+func contains(claim interface{}, value string) bool {
+	switch v := claim.(type) {
+	case string:
+		for _, s := range strings.Fields(v) {
+			if s == value {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasAudience returns true when the aud claim, which is a string or an array of strings,
+// contains the audience
+func hasAudience(aud interface{}, audience string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == audience
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
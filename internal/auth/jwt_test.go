@@ -0,0 +1,284 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signer signs tokens with the private key of a key in a key set
+type signer struct {
+	kid string
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+}
+
+func newRSASigner(t *testing.T, kid string, bits int) signer {
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatalf("error generating RSA key: %s", err.Error())
+	}
+	return signer{kid: kid, rsa: key}
+}
+
+func newECSigner(t *testing.T, kid string) signer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error generating EC key: %s", err.Error())
+	}
+	return signer{kid: kid, ec: key}
+}
+
+// jwk returns the public key as a JSON Web Key
+func (s signer) jwk() map[string]string {
+	enc := base64.RawURLEncoding.EncodeToString
+	if s.rsa != nil {
+		return map[string]string{
+			"kty": "RSA",
+			"kid": s.kid,
+			"alg": "RS256",
+			"n":   enc(s.rsa.N.Bytes()),
+			"e":   enc(big.NewInt(int64(s.rsa.E)).Bytes()),
+		}
+	}
+	size := (s.ec.Curve.Params().BitSize + 7) / 8
+	return map[string]string{
+		"kty": "EC",
+		"kid": s.kid,
+		"crv": "P-256",
+		"x":   enc(pad(s.ec.X, size)),
+		"y":   enc(pad(s.ec.Y, size)),
+	}
+}
+
+// pad returns the big-endian bytes of the number, padded with zeros to the size
+func pad(n *big.Int, size int) []byte {
+	b := n.Bytes()
+	return append(make([]byte, size-len(b)), b...)
+}
+
+// keySet returns a JSON Web Key Set with the public keys of the signers
+func keySet(t *testing.T, signers ...signer) []byte {
+	keys := make([]map[string]string, 0, len(signers))
+	for _, s := range signers {
+		keys = append(keys, s.jwk())
+	}
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	if err != nil {
+		t.Fatalf("error marshalling key set: %s", err.Error())
+	}
+	return data
+}
+
+// token returns a token with the header and claims, signed with the algorithm in the header
+func (s signer) token(t *testing.T, header map[string]interface{}, claims map[string]interface{}) string {
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	var err error
+	switch header["alg"] {
+	case "none":
+	case "HS256":
+		// The public key is used as the secret, like in a key confusion attack
+		mac := hmac.New(sha256.New, s.rsa.N.Bytes())
+		mac.Write([]byte(input))
+		signature = mac.Sum(nil)
+	case "RS256":
+		signature, err = rsa.SignPKCS1v15(rand.Reader, s.rsa, crypto.SHA256, digest[:])
+	case "PS256":
+		signature, err = rsa.SignPSS(rand.Reader, s.rsa, crypto.SHA256, digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	case "ES256":
+		var r, sig *big.Int
+		r, sig, err = ecdsa.Sign(rand.Reader, s.ec, digest[:])
+		if err == nil {
+			signature = append(pad(r, 32), pad(sig, 32)...)
+		}
+	}
+	if err != nil {
+		t.Fatalf("error signing token: %s", err.Error())
+	}
+
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// claims returns valid claims for the user dan, with the changes applied
+func claims(changes map[string]interface{}) map[string]interface{} {
+	c := map[string]interface{}{
+		"sub": "dan",
+		"iss": "https://issuer.example.com/",
+		"aud": []string{"cart", "catalog"},
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"iat": time.Now().Unix(),
+	}
+	for k, v := range changes {
+		if v == nil {
+			delete(c, k)
+			continue
+		}
+		c[k] = v
+	}
+	return c
+}
+
+// staticKeySource returns a KeySource with the keys of the signers, which counts how
+// often the keys are loaded
+func staticKeySource(t *testing.T, loads *int, signers ...signer) KeySource {
+	data := keySet(t, signers...)
+	return func() ([]byte, error) {
+		*loads++
+		return data, nil
+	}
+}
+
+func newTestJWT(t *testing.T, source KeySource) *JWT {
+	j, err := NewJWT(source, JWTOptions{
+		UserClaim: "sub",
+		Issuer:    "https://issuer.example.com/",
+		Audience:  "cart",
+	})
+	if err != nil {
+		t.Fatalf("error creating authenticator: %s", err.Error())
+	}
+	return j
+}
+
+func TestJWTAuthenticate(t *testing.T) {
+	rsaSigner := newRSASigner(t, "rsa", 2048)
+	ecSigner := newECSigner(t, "ec")
+	otherSigner := newRSASigner(t, "rsa", 2048)
+
+	loads := 0
+	j := newTestJWT(t, staticKeySource(t, &loads, rsaSigner, ecSigner))
+
+	rs256 := map[string]interface{}{"alg": "RS256", "kid": "rsa", "typ": "JWT"}
+	es256 := map[string]interface{}{"alg": "ES256", "kid": "ec", "typ": "JWT"}
+	expired := time.Now().Add(-2 * defaultLeeway).Unix()
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"RS256", rsaSigner.token(t, rs256, claims(nil)), true},
+		{"ES256", ecSigner.token(t, es256, claims(nil)), true},
+		{"without kid", rsaSigner.token(t, map[string]interface{}{"alg": "RS256"}, claims(nil)), true},
+		{"expired within leeway", rsaSigner.token(t, rs256, claims(map[string]interface{}{"exp": time.Now().Add(-defaultLeeway / 2).Unix()})), true},
+		{"alg none", rsaSigner.token(t, map[string]interface{}{"alg": "none", "kid": "rsa"}, claims(nil)), false},
+		{"HS256", rsaSigner.token(t, map[string]interface{}{"alg": "HS256", "kid": "rsa"}, claims(nil)), false},
+		{"alg of other key type", ecSigner.token(t, map[string]interface{}{"alg": "ES256", "kid": "rsa"}, claims(nil)), false},
+		{"alg other than alg of key", rsaSigner.token(t, map[string]interface{}{"alg": "PS256", "kid": "rsa"}, claims(nil)), false},
+		{"kid of other key", rsaSigner.token(t, map[string]interface{}{"alg": "RS256", "kid": "ec"}, claims(nil)), false},
+		{"critical header", rsaSigner.token(t, map[string]interface{}{"alg": "RS256", "kid": "rsa", "crit": []string{"b64"}}, claims(nil)), false},
+		{"expired", rsaSigner.token(t, rs256, claims(map[string]interface{}{"exp": expired})), false},
+		{"without exp", rsaSigner.token(t, rs256, claims(map[string]interface{}{"exp": nil})), false},
+		{"not valid yet", rsaSigner.token(t, rs256, claims(map[string]interface{}{"nbf": time.Now().Add(2 * defaultLeeway).Unix()})), false},
+		{"wrong iss", rsaSigner.token(t, rs256, claims(map[string]interface{}{"iss": "https://other.example.com/"})), false},
+		{"wrong aud", rsaSigner.token(t, rs256, claims(map[string]interface{}{"aud": "catalog"})), false},
+		{"without user", rsaSigner.token(t, rs256, claims(map[string]interface{}{"sub": nil})), false},
+		{"signed with other key", otherSigner.token(t, rs256, claims(nil)), false},
+		{"not a JWT", "dan", false},
+	}
+
+	// A token with claims that were changed after signing
+	signed := strings.Split(rsaSigner.token(t, rs256, claims(nil)), ".")
+	changed := strings.Split(rsaSigner.token(t, rs256, claims(map[string]interface{}{"sub": "erin"})), ".")
+	tests = append(tests, struct {
+		name  string
+		token string
+		valid bool
+	}{"bad signature", signed[0] + "." + changed[1] + "." + signed[2], false})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := j.Authenticate("Bearer " + tt.token)
+			if tt.valid {
+				if err != nil {
+					t.Fatalf("expected a valid token, got %s", err.Error())
+				}
+				if id.UserID != "dan" {
+					t.Errorf("expected user dan, got %q", id.UserID)
+				}
+				return
+			}
+			if !errors.Is(err, ErrUnauthorized) {
+				t.Errorf("expected ErrUnauthorized, got %v", err)
+			}
+		})
+	}
+}
+
+func TestJWTReloadsKeysForUnknownKid(t *testing.T) {
+	oldSigner := newRSASigner(t, "old", 2048)
+	newSigner := newRSASigner(t, "new", 2048)
+
+	loads := 0
+	rotated := false
+	oldSet := keySet(t, oldSigner)
+	newSet := keySet(t, oldSigner, newSigner)
+	j := newTestJWT(t, func() ([]byte, error) {
+		loads++
+		if rotated {
+			return newSet, nil
+		}
+		return oldSet, nil
+	})
+
+	if _, err := j.Authenticate("Bearer " + oldSigner.token(t, map[string]interface{}{"alg": "RS256", "kid": "old"}, claims(nil))); err != nil {
+		t.Fatalf("error authenticating with the old key: %s", err.Error())
+	}
+
+	// Tokens with an unknown kid don't load the keys again, while they were just loaded
+	rotated = true
+	token := newSigner.token(t, map[string]interface{}{"alg": "RS256", "kid": "new"}, claims(nil))
+	for i := 0; i < 3; i++ {
+		if _, err := j.Authenticate("Bearer " + token); !errors.Is(err, ErrUnauthorized) {
+			t.Fatalf("expected ErrUnauthorized before the keys are loaded again, got %v", err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("expected the keys to be loaded once, got %d", loads)
+	}
+
+	// Once the keys are older than keysMinAge, an unknown kid loads them again
+	j.loaded = time.Now().Add(-2 * keysMinAge)
+	if _, err := j.Authenticate("Bearer " + token); err != nil {
+		t.Fatalf("error authenticating with the new key: %s", err.Error())
+	}
+	if loads != 2 {
+		t.Errorf("expected the keys to be loaded twice, got %d", loads)
+	}
+}
+
+func TestJWTIgnoresSmallRSAKeys(t *testing.T) {
+	small := newRSASigner(t, "small", 1024)
+	ec := newECSigner(t, "ec")
+
+	keys, err := parseKeySet(keySet(t, small, ec))
+	if err != nil {
+		t.Fatalf("error parsing key set: %s", err.Error())
+	}
+	if len(keys) != 1 || keys[0].id != "ec" {
+		t.Errorf("expected only the EC key, got %d keys", len(keys))
+	}
+
+	loads := 0
+	j := newTestJWT(t, staticKeySource(t, &loads, small))
+	_, err = j.Authenticate("Bearer " + small.token(t, map[string]interface{}{"alg": "RS256", "kid": "small"}, claims(nil)))
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized for a token signed with a 1024-bit key, got %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/retgits/acme-serverless-cart/internal/audit"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+)
+
+// Handler is the handler of a Lambda function behind API Gateway
+type Handler = func(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// ErrorHandler returns the API Gateway Proxy Response for an error that occured in the area
+type ErrorHandler = func(area string, headers map[string]string, err error) (events.APIGatewayProxyResponse, error)
+
+// Lambda wraps the handler of a Lambda function, so requests are authenticated with the
+// Authenticator from FromEnv and checked with Check before they are handled. The ID of
+// the authenticated user replaces the X-User-ID header of the request. When
+// authentication isn't configured, requests are only checked with CheckUnauthenticated.
+// Audit records are kept with the Recorder from audit.FromEnv. Requests that aren't
+// allowed are returned with handleError.
+func Lambda(db datastore.Manager, h Handler, handleError ErrorHandler) Handler {
+	authenticator, err := FromEnv()
+
+	var recorder audit.Recorder
+	if err == nil {
+		recorder, err = audit.FromEnv()
+	}
+
+	return func(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		headers := map[string]string{
+			"Access-Control-Allow-Origin": "*",
+			cart.RequestIDHeader:          request.RequestContext.RequestID,
+		}
+
+		if err != nil {
+			return handleError("configuring authentication", headers, err)
+		}
+
+		caller, _ := cart.LookupHeader(request.Headers, cart.CallerHeader)
+		r := Request{
+			RequestID: request.RequestContext.RequestID,
+			Method:    request.HTTPMethod,
+			Route:     request.Resource,
+			Path:      request.Path,
+			UserID:    request.PathParameters["userid"],
+			Cart:      request.QueryStringParameters["cart"],
+			Caller:    caller,
+		}
+
+		// Only Lambda can tell the handler that the request uses the rights of an admin
+		request.Headers = without(request.Headers, AdminAccessHeader)
+
+		if authenticator == nil {
+			if cerr := CheckUnauthenticated(recorder, r); cerr != nil {
+				return handleError("authorizing request", headers, cerr)
+			}
+			return h(request)
+		}
+
+		// Only the authenticated user can be the caller
+		request.Headers = without(request.Headers, cart.CallerHeader)
+
+		if Anonymous(r) {
+			return h(request)
+		}
+
+		authorization, _ := cart.LookupHeader(request.Headers, Header)
+		id, aerr := authenticator.Authenticate(authorization)
+		if aerr != nil {
+			if errors.Is(aerr, ErrUnauthorized) {
+				headers[ChallengeHeader] = Challenge
+			}
+			return handleError("authenticating request", headers, aerr)
+		}
+
+		admin, aerr := Check(db, recorder, id, r)
+		if aerr != nil {
+			return handleError("authorizing request", headers, aerr)
+		}
+
+		request.Headers[cart.CallerHeader] = id.UserID
+		if admin {
+			request.Headers[AdminAccessHeader] = "true"
+		}
+		return h(request)
+	}
+}
+
+// AdminAccess returns true when Lambda allowed a request because the user is an admin, so
+// the handler doesn't need to check the role of the user on the cart
+func AdminAccess(headers map[string]string) bool {
+	v, _ := cart.LookupHeader(headers, AdminAccessHeader)
+	return v == "true"
+}
+
+// without returns a copy of the headers without the header with the name
+func without(headers map[string]string, name string) map[string]string {
+	res := make(map[string]string, len(headers))
+	for k, v := range headers {
+		if !strings.EqualFold(k, name) {
+			res[k] = v
+		}
+	}
+	return res
+}
@@ -0,0 +1,136 @@
+package cart
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/retgits/acme-serverless-cart/internal/events"
+)
+
+// AbandonmentOptions configure how abandoned carts are detected
+type AbandonmentOptions struct {
+	// Publisher sends the CartAbandoned events
+	Publisher events.Publisher
+
+	// Source is the function that detects the abandoned carts
+	Source string
+
+	// IdleFor is how long a cart needs to be unchanged before it is abandoned
+	IdleFor time.Duration
+}
+
+// ReportAbandoned sends a CartAbandoned event for every cart with a value that hasn't
+// changed for IdleFor, and records that a reminder was sent. A cart is only reported
+// again after it has changed. Carts of anonymous users, and carts that were last changed
+// before changes were recorded, are skipped. Carts that can't be reported are logged
+// and retried on the next run. ReportAbandoned returns the carts that were reported.
+func ReportAbandoned(db datastore.Manager, opts AbandonmentOptions) ([]events.AbandonedCart, error) {
+	carts, err := db.AllCarts()
+	if err != nil {
+		return nil, err
+	}
+
+	reported := make([]events.AbandonedCart, 0)
+	failed := 0
+	now := time.Now()
+
+	for _, c := range carts {
+		if strings.HasPrefix(c.UserID, GuestPrefix) {
+			continue
+		}
+
+		abandoned, err := abandonedCart(db, c, now.Add(-opts.IdleFor))
+		if err == nil && abandoned != nil {
+			err = reportAbandoned(db, *abandoned, opts.Source, opts.Publisher, now)
+		}
+		if err != nil {
+			log.Printf("error reporting abandoned cart %s: %s", c.UserID, err.Error())
+			failed++
+			continue
+		}
+
+		if abandoned != nil {
+			reported = append(reported, *abandoned)
+		}
+	}
+
+	if failed > 0 {
+		return reported, fmt.Errorf("%d of %d carts couldn't be checked", failed, len(carts))
+	}
+
+	return reported, nil
+}
+
+// abandonedCart returns the details of the cart when it has a value, wasn't changed
+// since idleSince, and no reminder was sent since the last change. It returns nil otherwise.
+func abandonedCart(db datastore.Manager, c datastore.Cart, idleSince time.Time) (*events.AbandonedCart, error) {
+	userID, cartID := datastore.SplitCartKey(c.UserID)
+	abandoned := events.AbandonedCart{
+		UserID: userID,
+		Cart:   cartID,
+		Items:  c.Items,
+	}
+
+	for _, item := range c.Items {
+		abandoned.ItemTotal = abandoned.ItemTotal + item.Quantity
+		abandoned.Total = abandoned.Total + (float64(item.Quantity) * item.Price)
+	}
+
+	if abandoned.Total <= 0 {
+		return nil, nil
+	}
+
+	updated, err := db.UpdatedAt(c.UserID)
+	if err != nil {
+		// The cart was removed after all carts were retrieved
+		if errors.Is(err, datastore.ErrCartNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if updated.IsZero() || updated.After(idleSince) {
+		return nil, nil
+	}
+
+	reminded, err := db.RemindedAt(c.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !reminded.IsZero() && !reminded.Before(updated) {
+		return nil, nil
+	}
+
+	abandoned.UpdatedAt = updated
+	return &abandoned, nil
+}
+
+// reportAbandoned sends the CartAbandoned event for the cart and records when it was sent
+func reportAbandoned(db datastore.Manager, abandoned events.AbandonedCart, source string, p events.Publisher, now time.Time) error {
+	evt := events.CartAbandonedEvent{
+		Metadata: acmeserverless.Metadata{
+			Domain: acmeserverless.CartDomain,
+			Source: source,
+			Type:   events.CartAbandonedEventName,
+			Status: acmeserverless.DefaultSuccessStatus,
+		},
+		Data: abandoned,
+	}
+
+	payload, err := evt.Marshal()
+	if err != nil {
+		return err
+	}
+
+	if err := p.Publish(events.CartAbandonedEventName, payload); err != nil {
+		return err
+	}
+
+	return db.SetRemindedAt(datastore.CartKey(abandoned.UserID, abandoned.Cart), now)
+}
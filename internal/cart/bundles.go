@@ -0,0 +1,149 @@
+package cart
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-cart/internal/catalog"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"gopkg.in/yaml.v2"
+)
+
+// Bundle describes a set of items that is sold together at a price of its own
+type Bundle struct {
+	// Name is the name of the bundle
+	Name string `json:"name" yaml:"name"`
+
+	// Description is the description of the bundle
+	Description string `json:"description" yaml:"description"`
+
+	// Price is the price of the bundle, which replaces the sum of the prices of the items
+	Price float64 `json:"price" yaml:"price"`
+
+	// Items are the components of the bundle
+	Items []BundleItem `json:"items" yaml:"items"`
+}
+
+// BundleItem is a component of a bundle
+type BundleItem struct {
+	// ItemID is the unique identifier of the item
+	ItemID string `json:"itemid" yaml:"itemid"`
+
+	// Name is the name of the item
+	Name string `json:"name" yaml:"name"`
+
+	// Quantity is the number of the item in a single bundle
+	Quantity int64 `json:"quantity" yaml:"quantity"`
+
+	// Price is the price of the item when it is bought on its own, which is used to
+	// show the discount of the bundle
+	Price float64 `json:"price" yaml:"price"`
+}
+
+// Bundles are the bundles that can be added to a cart, by their itemid
+type Bundles map[string]Bundle
+
+// ParseBundles reads the bundles from YAML or JSON
+func ParseBundles(data []byte) (Bundles, error) {
+	b := make(Bundles)
+	if err := yaml.UnmarshalStrict(data, &b); err != nil {
+		return nil, err
+	}
+
+	for id, bundle := range b {
+		if len(bundle.Items) == 0 {
+			return nil, fmt.Errorf("bundle %s has no items", id)
+		}
+		for _, item := range bundle.Items {
+			if len(item.ItemID) == 0 || item.Quantity <= 0 {
+				return nil, fmt.Errorf("bundle %s has an item without an itemid or quantity", id)
+			}
+		}
+	}
+
+	return b, nil
+}
+
+// BundlesFromEnv reads the bundles from CART_BUNDLES, which contains the bundles themselves,
+// or from the file at CART_BUNDLES_FILE. It returns nil when neither is set.
+func BundlesFromEnv() (Bundles, error) {
+	switch {
+	case len(os.Getenv("CART_BUNDLES")) > 0:
+		return ParseBundles([]byte(os.Getenv("CART_BUNDLES")))
+	case len(os.Getenv("CART_BUNDLES_FILE")) > 0:
+		data, err := ioutil.ReadFile(filepath.Clean(os.Getenv("CART_BUNDLES_FILE")))
+		if err != nil {
+			return nil, err
+		}
+		b, err := ParseBundles(data)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s: %s", os.Getenv("CART_BUNDLES_FILE"), err.Error())
+		}
+		return b, nil
+	default:
+		return nil, nil
+	}
+}
+
+// Expand returns the item with the name, description, price, and components of the
+// bundle it is for. Items that aren't bundles are returned without components, so
+// bundles can only be created from their definition.
+func (b Bundles) Expand(item datastore.CartItem) datastore.CartItem {
+	item.Children = nil
+	if item.ItemID == nil {
+		return item
+	}
+
+	bundle, ok := b[*item.ItemID]
+	if !ok {
+		return item
+	}
+
+	item.Name = bundle.Name
+	item.Description = bundle.Description
+	item.Price = bundle.Price
+	item.Children = make(datastore.CartItems, len(bundle.Items))
+	for idx, bi := range bundle.Items {
+		itemID := bi.ItemID
+		item.Children[idx] = datastore.CartItem{
+			CartItem: acmeserverless.CartItem{
+				ItemID:   &itemID,
+				Name:     bi.Name,
+				Price:    bi.Price,
+				Quantity: bi.Quantity,
+			},
+		}
+	}
+
+	return item
+}
+
+// ValidateItem expands the item when it is a bundle. Other items are validated against
+// the catalog, when c isn't nil.
+func ValidateItem(c catalog.CatalogClient, b Bundles, item datastore.CartItem) (datastore.CartItem, error) {
+	item = b.Expand(item)
+	if item.IsBundle() || c == nil {
+		return item, nil
+	}
+
+	return catalog.Validate(c, item)
+}
+
+// ValidateItems validates all items using ValidateItem and returns the updated items.
+// It stops at the first item that fails validation.
+func ValidateItems(c catalog.CatalogClient, b Bundles, items datastore.CartItems) (datastore.CartItems, error) {
+	validated := make(datastore.CartItems, len(items))
+
+	for idx, item := range items {
+		vi, err := ValidateItem(c, b, item)
+		if err != nil {
+			return nil, err
+		}
+		validated[idx] = vi
+	}
+
+	return validated, nil
+}
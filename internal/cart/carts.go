@@ -0,0 +1,92 @@
+package cart
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+)
+
+// cartIDPattern is the pattern that the ID of a named cart must match
+var cartIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+// Key returns the key of a cart of a user as it is used by the datastore. An empty
+// cartID selects the default cart of the user.
+func Key(userID string, cartID string) (string, error) {
+	if len(userID) == 0 || strings.Contains(userID, "#") {
+		return "", fmt.Errorf("%w: userid %s is not a valid user", datastore.ErrInvalidRequest, userID)
+	}
+
+	if len(cartID) > 0 && !cartIDPattern.MatchString(cartID) {
+		return "", fmt.Errorf("%w: cart %s is not a valid cart id", datastore.ErrInvalidRequest, cartID)
+	}
+
+	return datastore.CartKey(userID, cartID), nil
+}
+
+// NewCartID creates the ID of a named cart from its name. The name
+// "gym A restock" results in the ID gym-a-restock.
+func NewCartID(name string) (string, error) {
+	var sb strings.Builder
+	dash := false
+
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			sb.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && sb.Len() > 0 {
+			sb.WriteRune('-')
+			dash = true
+		}
+	}
+
+	id := strings.TrimSuffix(sb.String(), "-")
+	if len(id) > 64 {
+		id = strings.TrimSuffix(id[:64], "-")
+	}
+
+	if !cartIDPattern.MatchString(id) || id == datastore.DefaultCart {
+		return "", fmt.Errorf("%w: unable to create a cart id from name %s", datastore.ErrInvalidRequest, name)
+	}
+
+	return id, nil
+}
+
+// CartRequest is the request to create or rename a named cart
+type CartRequest struct {
+	// Name is the name of the cart, like "gym A restock"
+	Name string `json:"name"`
+}
+
+// UnmarshalCartRequest parses the JSON-encoded data and stores the result in a CartRequest
+func UnmarshalCartRequest(data []byte) (CartRequest, error) {
+	var r CartRequest
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, err
+	}
+
+	r.Name = strings.TrimSpace(r.Name)
+	if len(r.Name) == 0 || len(r.Name) > 100 {
+		return r, fmt.Errorf("%w: the name of a cart must be between 1 and 100 characters", datastore.ErrInvalidRequest)
+	}
+
+	return r, nil
+}
+
+// CartsResponse lists the carts of a user
+type CartsResponse struct {
+	// Carts are the carts of the user
+	Carts []datastore.CartInfo `json:"carts"`
+
+	// UserID is the unique identifier of the user in the ACME Serverless Fitness Shop
+	UserID string `json:"userid"`
+}
+
+// Marshal returns the JSON encoding of CartsResponse
+func (r *CartsResponse) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
@@ -0,0 +1,187 @@
+package cart
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-cart/internal/catalog"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/retgits/acme-serverless-cart/internal/events"
+)
+
+// ErrEmptyCart is returned when a cart without items is checked out.
+var ErrEmptyCart = errors.New("cart is empty")
+
+// PricesChangedError is returned by Checkout when the prices of the items in the cart no
+// longer match the catalog. The cart is updated with the current prices, so the user can
+// review the changes before checking out again.
+type PricesChangedError struct {
+	// Result lists the items that changed
+	Result catalog.RepriceResult
+}
+
+// Error returns the number of changed and unavailable items
+func (e *PricesChangedError) Error() string {
+	return fmt.Sprintf("the price of %d items has changed and %d items are unavailable", len(e.Result.Changed), len(e.Result.Unavailable))
+}
+
+// ParseCheckoutMode returns true if carts should be archived on checkout. The mode
+// is either clear, which is the default, or archive.
+func ParseCheckoutMode(name string) (bool, error) {
+	switch strings.ToLower(name) {
+	case "", "clear":
+		return false, nil
+	case "archive":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown checkout mode %s", name)
+	}
+}
+
+// CheckoutOptions configure how a cart is checked out
+type CheckoutOptions struct {
+	// Catalog is used to check the prices of the items, when it is set
+	Catalog catalog.CatalogClient
+
+	// Publisher sends the CartCheckedOut event
+	Publisher events.Publisher
+
+	// Source is the function that checks out the cart
+	Source string
+
+	// Archive keeps the items as an archived cart, instead of only clearing the cart
+	Archive bool
+
+	// LockTTL is how long the cart stays locked when the checkout doesn't finish
+	LockTTL time.Duration
+
+	// Version is the version the cart has to be at, as returned by CheckIfMatch, or
+	// AnyVersion to check out the cart regardless of its version
+	Version int64
+}
+
+// Checkout locks the cart with the key, validates the items, and sends a CartCheckedOut
+// event with the items in the cart. Only when the event is sent, the cart is cleared or
+// archived. When anything goes wrong before that, the cart is unlocked again.
+func Checkout(db datastore.Manager, key string, opts CheckoutOptions) (events.CheckoutDetails, error) {
+	userID, cartID := datastore.SplitCartKey(key)
+	details := events.CheckoutDetails{
+		UserID: userID,
+		Cart:   cartID,
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return details, err
+	}
+	details.CheckoutID = hex.EncodeToString(b)
+
+	if err := db.LockCart(key, details.CheckoutID, opts.LockTTL); err != nil {
+		return details, err
+	}
+
+	// When the lock can't be removed, it expires after the LockTTL
+	unlock := func() {
+		db.UnlockCart(key, details.CheckoutID)
+	}
+
+	// The cart can't change while it is locked, so it is still at the version the caller read
+	// when it is at that version now
+	version, err := CurrentVersion(db, key)
+	if err == nil && opts.Version != AnyVersion && version != opts.Version {
+		err = fmt.Errorf("%w: cart %s has changed", ErrPreconditionFailed, key)
+	}
+	if err != nil {
+		unlock()
+		return details, err
+	}
+
+	items, err := db.GetItems(key)
+	if err != nil {
+		unlock()
+		return details, err
+	}
+
+	if err := validateCheckout(items); err != nil {
+		unlock()
+		return details, err
+	}
+
+	if opts.Catalog != nil {
+		repriced, res, err := catalog.Reprice(opts.Catalog, items)
+		if err != nil {
+			unlock()
+			return details, err
+		}
+
+		if len(res.Changed) > 0 || len(res.Unavailable) > 0 {
+			// Once the cart is unlocked, the items can change again. The repriced items
+			// are only stored when the cart is still at the version that was repriced.
+			unlock()
+			if err := db.StoreItemsIfVersion(key, repriced, version); err != nil {
+				return details, err
+			}
+			res.UserID = userID
+			return details, &PricesChangedError{Result: res}
+		}
+	}
+
+	details.Items = items
+	for _, item := range items {
+		details.ItemTotal = details.ItemTotal + item.Quantity
+		details.Total = details.Total + (float64(item.Quantity) * item.Price)
+	}
+
+	evt := events.CartCheckedOutEvent{
+		Metadata: acmeserverless.Metadata{
+			Domain: acmeserverless.CartDomain,
+			Source: opts.Source,
+			Type:   events.CartCheckedOutEventName,
+			Status: acmeserverless.DefaultSuccessStatus,
+		},
+		Data: details,
+	}
+
+	payload, err := evt.Marshal()
+	if err != nil {
+		unlock()
+		return details, err
+	}
+
+	if err := opts.Publisher.Publish(events.CartCheckedOutEventName, payload); err != nil {
+		unlock()
+		if !errors.Is(err, events.ErrPublishFailed) {
+			err = fmt.Errorf("%w: %s", events.ErrPublishFailed, err.Error())
+		}
+		return details, err
+	}
+
+	if err := db.CheckoutCart(key, details.CheckoutID, opts.Archive); err != nil {
+		return details, fmt.Errorf("checkout %s was sent, but cart %s couldn't be cleared: %w", details.CheckoutID, key, err)
+	}
+
+	return details, nil
+}
+
+// validateCheckout checks that the items in a cart can be ordered
+func validateCheckout(items datastore.CartItems) error {
+	if len(items) == 0 {
+		return ErrEmptyCart
+	}
+
+	for _, item := range items {
+		if item.ItemID == nil {
+			return fmt.Errorf("%w: item %s has no itemid", datastore.ErrInvalidRequest, item.Name)
+		}
+		if item.Quantity <= 0 {
+			return fmt.Errorf("%w: item %s has a quantity of %d", datastore.ErrInvalidRequest, *item.ItemID, item.Quantity)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+package cart
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+)
+
+const (
+	// ETagHeader is the header with the entity tag of a cart
+	ETagHeader = "ETag"
+
+	// IfMatchHeader is the header with the entity tags a cart must match to be changed
+	IfMatchHeader = "If-Match"
+
+	// IfNoneMatchHeader is the header with the entity tags of carts the caller already has
+	IfNoneMatchHeader = "If-None-Match"
+)
+
+// ErrPreconditionFailed is returned when a cart doesn't match the If-Match header of a request
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// AnyVersion is returned by CheckIfMatch for requests without an If-Match header, so the
+// changes are stored regardless of the version of the cart.
+const AnyVersion = datastore.AnyVersion
+
+// ETag returns the entity tag of the items in a cart at a version. The tag contains the
+// version and a hash of the items, so it changes whenever the cart changes, and a change
+// can be stored only when the cart is still at the version of the tag.
+func ETag(version int64, items datastore.CartItems) (string, error) {
+	if len(items) == 0 {
+		items = make(datastore.CartItems, 0)
+	}
+
+	payload, err := items.Marshal()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(payload)
+	return fmt.Sprintf(`"%d-%s"`, version, hex.EncodeToString(sum[:16])), nil
+}
+
+// CurrentVersion returns the version of the cart with the key, which is 0 for carts that
+// weren't changed since versions are stored.
+func CurrentVersion(db datastore.Manager, key string) (int64, error) {
+	versions, err := db.Versions(key)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(versions) == 0 {
+		return 0, nil
+	}
+
+	return versions[0].Version, nil
+}
+
+// MatchesETag returns true when the entity tag is in the list of entity tags of an
+// If-Match or If-None-Match header, or when the header is *. Weak tags are compared by
+// their value, so they only match when weak is true.
+func MatchesETag(header string, etag string, weak bool) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" {
+			return true
+		}
+		if strings.HasPrefix(t, "W/") {
+			if !weak {
+				continue
+			}
+			t = strings.TrimPrefix(t, "W/")
+		}
+		if t == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckIfMatch returns ErrPreconditionFailed when the cart with the key doesn't match
+// the If-Match header of a request. Carts that don't exist match no tag at all, and
+// requests without an If-Match header always pass. The version of the cart that matched
+// is returned, so the change can be stored with StoreItems (or the other functions that
+// take a version) only when no one else changed the cart in the meantime. For requests
+// without an If-Match header, AnyVersion is returned.
+func CheckIfMatch(db datastore.Manager, key string, ifMatch string) (int64, error) {
+	if len(strings.TrimSpace(ifMatch)) == 0 {
+		return AnyVersion, nil
+	}
+
+	// The version is read before the items, so a change in between makes the tag not match
+	version, err := CurrentVersion(db, key)
+	if errors.Is(err, datastore.ErrCartNotFound) {
+		return 0, fmt.Errorf("%w: cart %s doesn't exist", ErrPreconditionFailed, key)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	items, err := db.GetItems(key)
+	if errors.Is(err, datastore.ErrCartNotFound) {
+		return 0, fmt.Errorf("%w: cart %s doesn't exist", ErrPreconditionFailed, key)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	etag, err := ETag(version, items)
+	if err != nil {
+		return 0, err
+	}
+
+	if !MatchesETag(ifMatch, etag, false) {
+		return 0, fmt.Errorf("%w: cart %s has changed", ErrPreconditionFailed, key)
+	}
+
+	return version, nil
+}
+
+// StoreItems stores the items of the cart with the key, when the cart is still at the
+// version returned by CheckIfMatch. ErrPreconditionFailed is returned when the cart has
+// changed since. With AnyVersion the items are always stored.
+func StoreItems(db datastore.Manager, key string, items datastore.CartItems, version int64) error {
+	if version == AnyVersion {
+		return db.StoreItems(key, items)
+	}
+	return preconditionFailed(db.StoreItemsIfVersion(key, items, version))
+}
+
+// AddItem adds the item to the cart with the key, like StoreItems
+func AddItem(db datastore.Manager, key string, item datastore.CartItem, version int64) error {
+	if version == AnyVersion {
+		return db.AddItem(key, item)
+	}
+
+	items, err := db.GetItems(key)
+	if err != nil {
+		return err
+	}
+
+	return StoreItems(db, key, append(items, item), version)
+}
+
+// ClearCart removes all items from the cart with the key, like StoreItems
+func ClearCart(db datastore.Manager, key string, version int64) error {
+	if version == AnyVersion {
+		return db.ClearCart(key)
+	}
+	return StoreItems(db, key, make(datastore.CartItems, 0), version)
+}
+
+// SaveForLater moves the line from the cart with the key to the saved-for-later list, like StoreItems
+func SaveForLater(db datastore.Manager, key string, line datastore.CartItem, version int64) error {
+	return preconditionFailed(db.SaveForLater(key, line, version))
+}
+
+// MoveToCart moves the line from the saved-for-later list to the cart with the key, like StoreItems
+func MoveToCart(db datastore.Manager, key string, line datastore.CartItem, version int64) error {
+	return preconditionFailed(db.MoveToCart(key, line, version))
+}
+
+// preconditionFailed returns ErrPreconditionFailed when a cart wasn't at the expected version
+func preconditionFailed(err error) error {
+	if errors.Is(err, datastore.ErrVersionMismatch) {
+		return fmt.Errorf("%w: %s", ErrPreconditionFailed, err.Error())
+	}
+	return err
+}
+
+// IfMatchFromHeaders returns the If-Match header from the headers of a request, or an
+// empty string when it isn't set.
+func IfMatchFromHeaders(headers map[string]string) string {
+	v, _ := LookupHeader(headers, IfMatchHeader)
+	return v
+}
+
+// IfNoneMatchFromHeaders returns the If-None-Match header from the headers of a request,
+// or an empty string when it isn't set.
+func IfNoneMatchFromHeaders(headers map[string]string) string {
+	v, _ := LookupHeader(headers, IfNoneMatchHeader)
+	return v
+}
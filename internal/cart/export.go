@@ -0,0 +1,82 @@
+package cart
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+)
+
+// ExportedCart is a cart of a user with its items and collaborators
+type ExportedCart struct {
+	datastore.CartInfo
+
+	// Items are the items in the cart
+	Items datastore.CartItems `json:"cart"`
+
+	// Members are the collaborators of the cart
+	Members []datastore.Member `json:"members"`
+}
+
+// ExportResponse is everything the Cart service keeps about a user
+type ExportResponse struct {
+	// Carts are the default cart and the named carts of the user
+	Carts []ExportedCart `json:"carts"`
+
+	// Saved are the items on the saved-for-later list of the user
+	Saved datastore.CartItems `json:"saved"`
+
+	// UserID is the unique identifier of the user in the ACME Serverless Fitness Shop
+	UserID string `json:"userid"`
+}
+
+// Marshal returns the JSON encoding of ExportResponse
+func (r *ExportResponse) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// Export returns the carts, with their items and collaborators, and the saved-for-later
+// list of a user. A default cart that was never created is exported as an empty cart.
+func Export(db datastore.Manager, userID string) (ExportResponse, error) {
+	res := ExportResponse{
+		UserID: userID,
+	}
+
+	carts, err := db.ListCarts(userID)
+	if err != nil {
+		return res, err
+	}
+
+	res.Carts = make([]ExportedCart, 0, len(carts))
+	for _, c := range carts {
+		key := datastore.CartKey(userID, c.ID)
+
+		items, err := db.GetItems(key)
+		if err != nil && !errors.Is(err, datastore.ErrCartNotFound) {
+			return res, err
+		}
+		if items == nil {
+			items = make(datastore.CartItems, 0)
+		}
+
+		members, err := db.Members(key)
+		if err != nil && !errors.Is(err, datastore.ErrCartNotFound) {
+			return res, err
+		}
+		if members == nil {
+			members = make([]datastore.Member, 0)
+		}
+
+		res.Carts = append(res.Carts, ExportedCart{CartInfo: c, Items: items, Members: members})
+	}
+
+	res.Saved, err = db.GetSavedItems(userID)
+	if err != nil && !errors.Is(err, datastore.ErrCartNotFound) {
+		return res, err
+	}
+	if res.Saved == nil {
+		res.Saved = make(datastore.CartItems, 0)
+	}
+
+	return res, nil
+}
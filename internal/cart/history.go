@@ -0,0 +1,217 @@
+package cart
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+)
+
+const (
+	// LineAdded is the change of a line that is only in the newer version
+	LineAdded = "added"
+
+	// LineRemoved is the change of a line that is only in the older version
+	LineRemoved = "removed"
+
+	// LineChanged is the change of a line of which the quantity or price changed
+	LineChanged = "changed"
+)
+
+// LineChange describes how a line in a cart changed between two versions
+type LineChange struct {
+	// ItemID is the unique identifier of the item
+	ItemID string `json:"itemid"`
+
+	// Name is the name of the item
+	Name string `json:"name"`
+
+	// Options are the options of the variant of the item
+	Options map[string]string `json:"options,omitempty"`
+
+	// Change is either added, removed, or changed
+	Change string `json:"change"`
+
+	// OldQuantity is the quantity in the older version
+	OldQuantity int64 `json:"oldquantity"`
+
+	// NewQuantity is the quantity in the newer version
+	NewQuantity int64 `json:"newquantity"`
+
+	// OldPrice is the price in the older version
+	OldPrice float64 `json:"oldprice"`
+
+	// NewPrice is the price in the newer version
+	NewPrice float64 `json:"newprice"`
+}
+
+// Diff returns the changes to the lines between the items of two versions of a cart.
+// Lines are matched on their identity, so every variant of an item is a line of its own.
+// Lines without an itemid are ignored.
+func Diff(from datastore.CartItems, to datastore.CartItems) []LineChange {
+	changes := make([]LineChange, 0)
+
+	old := make(map[string]datastore.CartItem)
+	for _, item := range from {
+		if item.ItemID != nil {
+			old[item.LineID()] = item
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, item := range to {
+		if item.ItemID == nil {
+			continue
+		}
+		seen[item.LineID()] = true
+
+		prev, ok := old[item.LineID()]
+		switch {
+		case !ok:
+			changes = append(changes, LineChange{
+				ItemID:      *item.ItemID,
+				Name:        item.Name,
+				Options:     item.Options,
+				Change:      LineAdded,
+				NewQuantity: item.Quantity,
+				NewPrice:    item.Price,
+			})
+		case prev.Quantity != item.Quantity || prev.Price != item.Price:
+			changes = append(changes, LineChange{
+				ItemID:      *item.ItemID,
+				Name:        item.Name,
+				Options:     item.Options,
+				Change:      LineChanged,
+				OldQuantity: prev.Quantity,
+				NewQuantity: item.Quantity,
+				OldPrice:    prev.Price,
+				NewPrice:    item.Price,
+			})
+		}
+	}
+
+	for _, item := range from {
+		if item.ItemID == nil || seen[item.LineID()] {
+			continue
+		}
+		changes = append(changes, LineChange{
+			ItemID:      *item.ItemID,
+			Name:        item.Name,
+			Options:     item.Options,
+			Change:      LineRemoved,
+			OldQuantity: item.Quantity,
+			OldPrice:    item.Price,
+		})
+	}
+
+	return changes
+}
+
+// VersionsResponse lists the versions of a cart
+type VersionsResponse struct {
+	// UserID is the unique identifier of the user in the ACME Serverless Fitness Shop
+	UserID string `json:"userid"`
+
+	// Cart is the ID of the cart of the user
+	Cart string `json:"cart"`
+
+	// Versions are the versions of the cart that are kept, newest first
+	Versions []datastore.Version `json:"versions"`
+}
+
+// Marshal returns the JSON encoding of VersionsResponse
+func (r *VersionsResponse) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// DiffResponse contains the changes between two versions of a cart
+type DiffResponse struct {
+	// UserID is the unique identifier of the user in the ACME Serverless Fitness Shop
+	UserID string `json:"userid"`
+
+	// Cart is the ID of the cart of the user
+	Cart string `json:"cart"`
+
+	// From is the older version
+	From int64 `json:"from"`
+
+	// To is the newer version
+	To int64 `json:"to"`
+
+	// Changes are the changes to the lines of the cart
+	Changes []LineChange `json:"changes"`
+}
+
+// Marshal returns the JSON encoding of DiffResponse
+func (r *DiffResponse) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// DiffVersions compares two versions of the cart with the key. When to is empty, the
+// latest version is used. When from is empty, the version before to is used.
+func DiffVersions(db datastore.Manager, key string, from string, to string) (DiffResponse, error) {
+	userID, cartID := datastore.SplitCartKey(key)
+	res := DiffResponse{
+		UserID: userID,
+		Cart:   cartID,
+	}
+
+	var newer datastore.Version
+	if len(to) == 0 {
+		versions, err := db.Versions(key)
+		if err != nil {
+			return res, err
+		}
+		if len(versions) == 0 {
+			return res, fmt.Errorf("%w: cart %s has no versions", datastore.ErrVersionNotFound, key)
+		}
+		newer = versions[0]
+	} else {
+		v, err := strconv.ParseInt(to, 10, 64)
+		if err != nil {
+			return res, fmt.Errorf("%w: invalid version %s", datastore.ErrInvalidRequest, to)
+		}
+		newer, err = db.GetVersion(key, v)
+		if err != nil {
+			return res, err
+		}
+	}
+
+	fromVersion := newer.Version - 1
+	if len(from) > 0 {
+		v, err := strconv.ParseInt(from, 10, 64)
+		if err != nil {
+			return res, fmt.Errorf("%w: invalid version %s", datastore.ErrInvalidRequest, from)
+		}
+		fromVersion = v
+	}
+
+	older, err := db.GetVersion(key, fromVersion)
+	if err != nil {
+		return res, err
+	}
+
+	res.From = older.Version
+	res.To = newer.Version
+	res.Changes = Diff(older.Items, newer.Items)
+
+	return res, nil
+}
+
+// PreviousVersion returns the current version of the cart with the key, and the version
+// before it. To undo the last change, the items of the previous version are stored with
+// StoreItemsIfVersion at the current version, so the undo is a new version that can be
+// undone as well. ErrVersionNotFound is returned when there is no previous version.
+func PreviousVersion(db datastore.Manager, key string) (datastore.Version, datastore.Version, error) {
+	versions, err := db.Versions(key)
+	if err != nil {
+		return datastore.Version{}, datastore.Version{}, err
+	}
+
+	if len(versions) < 2 {
+		return datastore.Version{}, datastore.Version{}, fmt.Errorf("%w: cart %s has no previous version", datastore.ErrVersionNotFound, key)
+	}
+
+	return versions[0], versions[1], nil
+}
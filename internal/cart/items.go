@@ -0,0 +1,36 @@
+package cart
+
+import (
+	"fmt"
+
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+)
+
+// RequestItem returns the item in the body of a request. On routes that have the itemid
+// in the path, like /v2/carts/{userid}/items/{itemid}, the itemid in the body can be left
+// out and the body can be empty when only the itemid is needed. An itemid in the body
+// that doesn't match the path is an error.
+func RequestItem(body []byte, pathItemID string) (datastore.CartItem, error) {
+	var item datastore.CartItem
+
+	if len(body) > 0 || len(pathItemID) == 0 {
+		var err error
+		item, err = datastore.UnmarshalItem(body)
+		if err != nil {
+			return item, err
+		}
+	}
+
+	if len(pathItemID) > 0 {
+		if item.ItemID != nil && *item.ItemID != pathItemID {
+			return item, fmt.Errorf("%w: itemid %s in the body doesn't match itemid %s in the path", datastore.ErrInvalidRequest, *item.ItemID, pathItemID)
+		}
+		item.ItemID = &pathItemID
+	}
+
+	if item.ItemID == nil {
+		return item, fmt.Errorf("%w: item has no itemid", datastore.ErrInvalidRequest)
+	}
+
+	return item, nil
+}
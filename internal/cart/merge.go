@@ -0,0 +1,201 @@
+// Package cart contains the operations on carts that are shared between the
+// Cloud Run and AWS Lambda versions of the Cart service in the ACME Serverless
+// Fitness Shop.
+package cart
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+)
+
+// GuestPrefix is the prefix of the IDs of carts that belong to anonymous users
+const GuestPrefix = "guest-"
+
+// MergePolicy decides what happens when an item is in both the guest
+// cart and the user cart when the two are merged.
+type MergePolicy string
+
+const (
+	// MergeSum adds the quantities of both carts
+	MergeSum MergePolicy = "sum"
+
+	// MergeMax keeps the highest quantity of both carts
+	MergeMax MergePolicy = "max"
+
+	// MergeNewest keeps the item from the cart that was changed last
+	MergeNewest MergePolicy = "newest"
+)
+
+// ParseMergePolicy returns the MergePolicy with the given name. An empty name
+// returns MergeSum.
+func ParseMergePolicy(name string) (MergePolicy, error) {
+	switch p := MergePolicy(strings.ToLower(name)); p {
+	case "":
+		return MergeSum, nil
+	case MergeSum, MergeMax, MergeNewest:
+		return p, nil
+	default:
+		return "", fmt.Errorf("%w: unknown merge policy %s", datastore.ErrInvalidRequest, name)
+	}
+}
+
+// MergeRequest is the request to merge the cart of an anonymous user into
+// the cart of a user
+type MergeRequest struct {
+	// GuestID is the ID of the cart of the anonymous user
+	GuestID string `json:"guestid"`
+
+	// UserID is the unique identifier of the user in the ACME Serverless Fitness Shop
+	UserID string `json:"userid"`
+
+	// Policy is the name of the MergePolicy to use (optional)
+	Policy string `json:"policy,omitempty"`
+}
+
+// UnmarshalMergeRequest parses the JSON-encoded data and stores the result in a MergeRequest
+func UnmarshalMergeRequest(data []byte) (MergeRequest, error) {
+	var r MergeRequest
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, err
+	}
+
+	if !IsGuestID(r.GuestID) {
+		return r, fmt.Errorf("%w: guestid %s is not the ID of a guest cart", datastore.ErrInvalidRequest, r.GuestID)
+	}
+
+	if len(r.UserID) == 0 || IsGuestID(r.UserID) {
+		return r, fmt.Errorf("%w: userid %s is not a valid user", datastore.ErrInvalidRequest, r.UserID)
+	}
+
+	return r, nil
+}
+
+// NewGuestID creates a random ID for the cart of an anonymous user
+func NewGuestID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return GuestPrefix + hex.EncodeToString(b), nil
+}
+
+// IsGuestID returns true if the ID belongs to the cart of an anonymous user
+func IsGuestID(id string) bool {
+	return strings.HasPrefix(id, GuestPrefix) && len(id) > len(GuestPrefix)
+}
+
+// Merge combines the items of the guest cart with the items of the user cart. Items
+// are matched by itemid and conflicts are resolved using the policy. For MergeNewest,
+// guestIsNewer indicates whether the guest cart was changed after the user cart.
+// Items that are only in the guest cart are added after the items of the user cart.
+func Merge(userItems datastore.CartItems, guestItems datastore.CartItems, policy MergePolicy, guestIsNewer bool) datastore.CartItems {
+	merged := make(datastore.CartItems, len(userItems))
+	copy(merged, userItems)
+
+	for _, gi := range guestItems {
+		idx := indexOf(merged, gi)
+		if idx < 0 {
+			merged = append(merged, gi)
+			continue
+		}
+
+		switch policy {
+		case MergeMax:
+			if gi.Quantity > merged[idx].Quantity {
+				merged[idx] = gi
+			}
+		case MergeNewest:
+			if guestIsNewer {
+				merged[idx] = gi
+			}
+		default:
+			merged[idx].Quantity = merged[idx].Quantity + gi.Quantity
+		}
+	}
+
+	return merged
+}
+
+// MergeResult contains the items of the guest cart and the user cart and
+// the result of merging them.
+type MergeResult struct {
+	// GuestItems are the items in the guest cart
+	GuestItems datastore.CartItems
+
+	// UserItems are the items in the user cart
+	UserItems datastore.CartItems
+
+	// Items are the merged items
+	Items datastore.CartItems
+}
+
+// MergeCarts reads the guest cart and the user cart from the datastore and merges them
+// using the policy from the request, or defaultPolicy if the request has no policy. A user
+// that doesn't have a cart yet gets the items of the guest cart. The merged items are not
+// stored, that's done using the MoveCart method of the datastore.
+func MergeCarts(db datastore.Manager, r MergeRequest, defaultPolicy string) (MergeResult, error) {
+	var res MergeResult
+
+	name := r.Policy
+	if len(name) == 0 {
+		name = defaultPolicy
+	}
+
+	policy, err := ParseMergePolicy(name)
+	if err != nil {
+		return res, err
+	}
+
+	res.GuestItems, err = db.GetItems(r.GuestID)
+	if err != nil {
+		return res, err
+	}
+
+	res.UserItems, err = db.GetItems(r.UserID)
+	if errors.Is(err, datastore.ErrCartNotFound) {
+		res.UserItems = make(datastore.CartItems, 0)
+	} else if err != nil {
+		return res, err
+	}
+
+	guestIsNewer := true
+	if policy == MergeNewest && len(res.UserItems) > 0 {
+		guestUpdated, err := db.UpdatedAt(r.GuestID)
+		if err != nil {
+			return res, err
+		}
+
+		userUpdated, err := db.UpdatedAt(r.UserID)
+		if err != nil {
+			return res, err
+		}
+
+		guestIsNewer = !guestUpdated.Before(userUpdated)
+	}
+
+	res.Items = Merge(res.UserItems, res.GuestItems, policy, guestIsNewer)
+
+	return res, nil
+}
+
+// indexOf returns the index of the first line in items with the same identity
+// as item, or -1 if there is no such line.
+func indexOf(items datastore.CartItems, item datastore.CartItem) int {
+	if item.ItemID == nil {
+		return -1
+	}
+
+	for idx, ci := range items {
+		if ci.SameLine(item) {
+			return idx
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,77 @@
+package cart
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/retgits/acme-serverless-cart/internal/patch"
+)
+
+// PatchItems applies a JSON Merge Patch or JSON Patch, depending on the media type in
+// contentType, to the items of a cart. The patch is applied to the lines of the cart as
+// a JSON object keyed by their LineID, so {"sfsdsda3343": {"quantity": 2}} changes the
+// quantity of a line and {"sfsdsda3343": null} removes it. Lines that are added without
+// an itemid get the itemid in their key. Lines with the same identity are combined into
+// a single line before the patch is applied. Lines are removed with null, so a patch that
+// sets the quantity of a line to 0 or less is rejected, regardless of the business rules.
+func PatchItems(items datastore.CartItems, contentType string, p []byte) (datastore.CartItems, error) {
+	lines := make(map[string]datastore.CartItem, len(items))
+	order := make([]string, 0, len(items))
+
+	for _, item := range items {
+		id := item.LineID()
+		if line, ok := lines[id]; ok {
+			line.Quantity = line.Quantity + item.Quantity
+			lines[id] = line
+			continue
+		}
+		lines[id] = item
+		order = append(order, id)
+	}
+
+	doc, err := json.Marshal(lines)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err = patch.Apply(contentType, doc, p)
+	if err != nil {
+		return nil, err
+	}
+
+	var patched map[string]datastore.CartItem
+	err = json.Unmarshal(doc, &patched)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lines keep their place in the cart, new lines are added at the end
+	added := make([]string, 0)
+	for id := range patched {
+		if _, ok := lines[id]; !ok {
+			added = append(added, id)
+		}
+	}
+	sort.Strings(added)
+
+	res := make(datastore.CartItems, 0, len(patched))
+	for _, id := range append(order, added...) {
+		item, ok := patched[id]
+		if !ok {
+			continue
+		}
+		if item.ItemID == nil {
+			itemID := strings.SplitN(id, "?", 2)[0]
+			item.ItemID = &itemID
+		}
+		if line, ok := lines[id]; item.Quantity <= 0 && (!ok || line.Quantity != item.Quantity) {
+			return nil, fmt.Errorf("%w: quantity of line %s must be at least 1, use null to remove it", datastore.ErrInvalidRequest, id)
+		}
+		res = append(res, item)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,21 @@
+package cart
+
+import (
+	"encoding/json"
+
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+)
+
+// SavedResponse lists the items a user saved for later
+type SavedResponse struct {
+	// Saved are the items on the saved-for-later list of the user
+	Saved datastore.CartItems `json:"saved"`
+
+	// UserID is the unique identifier of the user in the ACME Serverless Fitness Shop
+	UserID string `json:"userid"`
+}
+
+// Marshal returns the JSON encoding of SavedResponse
+func (r *SavedResponse) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
@@ -0,0 +1,264 @@
+package cart
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+)
+
+// CallerHeader is the header that contains the ID of the user making the request. When
+// the header isn't set, the request is made by the user in the path.
+const CallerHeader = "X-User-ID"
+
+// RequestIDHeader is the header with the ID of a request, which is returned with errors
+// so a request can be found in the logs.
+const RequestIDHeader = "X-Request-ID"
+
+// ErrForbidden is returned when a user isn't allowed to access a cart.
+var ErrForbidden = errors.New("forbidden")
+
+// ErrInvalidInvite is returned when an invite token isn't valid or has expired.
+var ErrInvalidInvite = errors.New("invalid invite")
+
+// roleLevels orders the roles, so that each role allows everything the roles
+// with a lower level allow
+var roleLevels = map[datastore.Role]int{
+	datastore.RoleViewer: 1,
+	datastore.RoleEditor: 2,
+	datastore.RoleOwner:  3,
+}
+
+// ParseRole returns the role with the given name that can be given to a collaborator.
+// An empty name results in the viewer role.
+func ParseRole(name string) (datastore.Role, error) {
+	switch r := datastore.Role(strings.ToLower(name)); r {
+	case "":
+		return datastore.RoleViewer, nil
+	case datastore.RoleViewer, datastore.RoleEditor:
+		return r, nil
+	default:
+		return "", fmt.Errorf("%w: unknown role %s", datastore.ErrInvalidRequest, name)
+	}
+}
+
+// LookupHeader returns the value of the header with the name from the headers of a
+// request, ignoring the case of the name. The boolean is false when the header isn't set.
+func LookupHeader(headers map[string]string, name string) (string, bool) {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// Caller returns the ID of the user making the request from the headers of the
+// request, or the userID when the CallerHeader isn't set.
+func Caller(headers map[string]string, userID string) string {
+	if v, _ := LookupHeader(headers, CallerHeader); len(v) > 0 {
+		return v
+	}
+	return userID
+}
+
+// Authorize checks that the caller has at least the role on the cart with the key. The
+// owner of a cart has every role, other users need to be a member of the cart.
+func Authorize(db datastore.Manager, key string, caller string, role datastore.Role) error {
+	owner, _ := datastore.SplitCartKey(key)
+	if caller == owner {
+		return nil
+	}
+
+	if role != datastore.RoleOwner {
+		members, err := db.Members(key)
+		if err != nil && !errors.Is(err, datastore.ErrCartNotFound) {
+			return err
+		}
+
+		for _, m := range members {
+			if m.UserID == caller && roleLevels[m.Role] >= roleLevels[role] {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("%w: user %s needs the %s role on cart %s", ErrForbidden, caller, role, key)
+}
+
+// Attribute records who added each line in after. Lines that were already in
+// before keep their attribution, new lines are attributed to the caller.
+func Attribute(before datastore.CartItems, after datastore.CartItems, caller string) {
+	for idx := range after {
+		after[idx].AddedBy = caller
+		for _, item := range before {
+			if item.SameLine(after[idx]) && len(item.AddedBy) > 0 {
+				after[idx].AddedBy = item.AddedBy
+				break
+			}
+		}
+	}
+}
+
+// Invite is the content of an invite token that gives a user a role on a cart
+type Invite struct {
+	// Key is the key of the cart the invite is for
+	Key string `json:"key"`
+
+	// Role is the role the user gets on the cart
+	Role datastore.Role `json:"role"`
+
+	// Expires is the time, in seconds since the epoch, after which the invite can't be used
+	Expires int64 `json:"expires"`
+}
+
+// NewInviteToken creates a token for the invite, signed with the secret
+func NewInviteToken(secret []byte, inv Invite) (string, error) {
+	if len(secret) == 0 {
+		return "", fmt.Errorf("no secret to sign invites is configured")
+	}
+
+	payload, err := json.Marshal(inv)
+	if err != nil {
+		return "", err
+	}
+
+	data := base64.RawURLEncoding.EncodeToString(payload)
+	return data + "." + sign(secret, data), nil
+}
+
+// ParseInviteToken checks the signature and the expiry of a token created with NewInviteToken
+// and returns the invite
+func ParseInviteToken(secret []byte, token string) (Invite, error) {
+	var inv Invite
+
+	if len(secret) == 0 {
+		return inv, fmt.Errorf("no secret to sign invites is configured")
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 || !hmac.Equal([]byte(parts[1]), []byte(sign(secret, parts[0]))) {
+		return inv, fmt.Errorf("%w: the signature doesn't match", ErrInvalidInvite)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return inv, fmt.Errorf("%w: %s", ErrInvalidInvite, err.Error())
+	}
+
+	if err := json.Unmarshal(payload, &inv); err != nil {
+		return inv, fmt.Errorf("%w: %s", ErrInvalidInvite, err.Error())
+	}
+
+	if time.Now().Unix() > inv.Expires {
+		return inv, fmt.Errorf("%w: the invite has expired", ErrInvalidInvite)
+	}
+
+	return inv, nil
+}
+
+// sign returns the HMAC-SHA256 signature of the data
+func sign(secret []byte, data string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ShareRequest is the request to invite a collaborator to a cart
+type ShareRequest struct {
+	// Role is the role the collaborator gets, either viewer or editor
+	Role string `json:"role"`
+}
+
+// UnmarshalShareRequest parses the JSON-encoded data and stores the result in a ShareRequest
+func UnmarshalShareRequest(data []byte) (ShareRequest, error) {
+	var r ShareRequest
+	if len(data) == 0 {
+		return r, nil
+	}
+	err := json.Unmarshal(data, &r)
+	return r, err
+}
+
+// ShareResponse contains the invite token for a cart
+type ShareResponse struct {
+	// Token is the signed invite token
+	Token string `json:"token"`
+
+	// Role is the role the collaborator gets
+	Role datastore.Role `json:"role"`
+
+	// Expires is when the invite expires
+	Expires time.Time `json:"expires"`
+}
+
+// Marshal returns the JSON encoding of ShareResponse
+func (r *ShareResponse) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// JoinRequest is the request to accept an invite to a cart
+type JoinRequest struct {
+	// Token is the signed invite token
+	Token string `json:"token"`
+}
+
+// UnmarshalJoinRequest parses the JSON-encoded data and stores the result in a JoinRequest
+func UnmarshalJoinRequest(data []byte) (JoinRequest, error) {
+	var r JoinRequest
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, err
+	}
+
+	if len(r.Token) == 0 {
+		return r, fmt.Errorf("%w: token is required", datastore.ErrInvalidRequest)
+	}
+
+	return r, nil
+}
+
+// MemberRequest is the request to remove a collaborator from a cart
+type MemberRequest struct {
+	// UserID is the unique identifier of the collaborator
+	UserID string `json:"userid"`
+}
+
+// UnmarshalMemberRequest parses the JSON-encoded data and stores the result in a MemberRequest
+func UnmarshalMemberRequest(data []byte) (MemberRequest, error) {
+	var r MemberRequest
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, err
+	}
+
+	if len(r.UserID) == 0 {
+		return r, fmt.Errorf("%w: userid is required", datastore.ErrInvalidRequest)
+	}
+
+	return r, nil
+}
+
+// MembersResponse describes who has access to a cart
+type MembersResponse struct {
+	// Owner is the user the cart belongs to
+	Owner string `json:"owner"`
+
+	// Cart is the ID of the cart
+	Cart string `json:"cart"`
+
+	// Role is the role of the user making the request
+	Role datastore.Role `json:"role,omitempty"`
+
+	// Members are the collaborators of the cart
+	Members []datastore.Member `json:"members,omitempty"`
+}
+
+// Marshal returns the JSON encoding of MembersResponse
+func (r *MembersResponse) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
@@ -0,0 +1,113 @@
+package cart
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+)
+
+// SummaryLine is a line in a cart together with its total
+type SummaryLine struct {
+	datastore.CartItem
+
+	// LineID is the identity of the line
+	LineID string `json:"lineid"`
+
+	// LineTotal is the price of the line times its quantity
+	LineTotal float64 `json:"linetotal"`
+}
+
+// Discount is a reduction of the price of a line
+type Discount struct {
+	// LineID is the identity of the line the discount is for
+	LineID string `json:"lineid"`
+
+	// Description describes the discount
+	Description string `json:"description"`
+
+	// Amount is the amount that is taken off the subtotal
+	Amount float64 `json:"amount"`
+}
+
+// Summary contains everything that is needed to show a cart
+type Summary struct {
+	// UserID is the unique identifier of the user in the ACME Serverless Fitness Shop
+	UserID string `json:"userid"`
+
+	// Cart is the ID of the cart of the user
+	Cart string `json:"cart"`
+
+	// Lines are the lines in the cart
+	Lines []SummaryLine `json:"lines"`
+
+	// ItemCount is the number of items in the cart, counted like ItemsInCart
+	ItemCount int64 `json:"itemcount"`
+
+	// Subtotal is the value of the cart before discounts
+	Subtotal float64 `json:"subtotal"`
+
+	// Discounts are the discounts on the lines in the cart
+	Discounts []Discount `json:"discounts"`
+
+	// Total is the value of the cart after discounts
+	Total float64 `json:"total"`
+}
+
+// Marshal returns the JSON encoding of Summary
+func (s *Summary) Marshal() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// Summarize returns the summary of the items in the cart with the key. Bundles are
+// part of the subtotal at the prices of their components, when those are known, and
+// the difference with the price of the bundle is a discount. The total is the value
+// of the cart, like ValueInCart.
+func Summarize(key string, items datastore.CartItems, policy datastore.ItemCountPolicy) Summary {
+	userID, cartID := datastore.SplitCartKey(key)
+	s := Summary{
+		UserID:    userID,
+		Cart:      cartID,
+		Lines:     make([]SummaryLine, 0, len(items)),
+		ItemCount: datastore.CountItems(items, policy),
+		Discounts: make([]Discount, 0),
+	}
+
+	for _, item := range items {
+		line := SummaryLine{
+			CartItem:  item,
+			LineID:    item.LineID(),
+			LineTotal: cents(float64(item.Quantity) * item.Price),
+		}
+		s.Lines = append(s.Lines, line)
+		s.Total = s.Total + line.LineTotal
+
+		listPrice := 0.0
+		for _, child := range item.Children {
+			listPrice = listPrice + (float64(child.Quantity) * child.Price)
+		}
+
+		if saving := cents(float64(item.Quantity) * (listPrice - item.Price)); item.IsBundle() && saving > 0 {
+			s.Discounts = append(s.Discounts, Discount{
+				LineID:      line.LineID,
+				Description: fmt.Sprintf("%s bundle", item.Name),
+				Amount:      saving,
+			})
+			s.Subtotal = s.Subtotal + line.LineTotal + saving
+			continue
+		}
+
+		s.Subtotal = s.Subtotal + line.LineTotal
+	}
+
+	s.Subtotal = cents(s.Subtotal)
+	s.Total = cents(s.Total)
+
+	return s
+}
+
+// cents rounds an amount to cents
+func cents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
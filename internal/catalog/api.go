@@ -0,0 +1,156 @@
+// Package catalog contains the interfaces that the Cart service
+// in the ACME Serverless Fitness Shop needs to look up products
+// in the catalog. Items that are put in a cart are validated
+// against the catalog so the price, name, and description of an
+// item can't be set by the client. In order to add a new catalog
+// source, the CatalogClient interface needs to be implemented.
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+)
+
+// ErrItemNotFound is returned by a CatalogClient when the catalog
+// doesn't contain an item with the requested itemid.
+var ErrItemNotFound = errors.New("item not found in catalog")
+
+// CatalogClient is the interface that describes the methods the
+// catalog needs to implement to be able to work with the Cart
+// service of the ACME Serverless Fitness Shop.
+type CatalogClient interface {
+	GetItem(itemID string) (acmeserverless.CatalogItem, error)
+}
+
+// Validate checks that the item exists in the catalog and replaces the
+// price, name, and description with the authoritative values from the
+// catalog. The quantity is kept as sent by the client.
+func Validate(c CatalogClient, item datastore.CartItem) (datastore.CartItem, error) {
+	if item.ItemID == nil || len(*item.ItemID) == 0 {
+		return item, fmt.Errorf("%w: item has no itemid", datastore.ErrInvalidRequest)
+	}
+
+	product, err := c.GetItem(*item.ItemID)
+	if err != nil {
+		return item, fmt.Errorf("unable to validate item %s: %w", *item.ItemID, err)
+	}
+
+	item.Name = product.Name
+	item.Description = product.ShortDescription
+	if len(item.Description) == 0 {
+		item.Description = product.Description
+	}
+	item.Price = Price(product)
+
+	return item, nil
+}
+
+// ValidateItems validates all items using Validate and returns the updated items.
+// It stops at the first item that fails validation.
+func ValidateItems(c CatalogClient, items datastore.CartItems) (datastore.CartItems, error) {
+	validated := make(datastore.CartItems, len(items))
+
+	for idx, item := range items {
+		vi, err := Validate(c, item)
+		if err != nil {
+			return nil, err
+		}
+		validated[idx] = vi
+	}
+
+	return validated, nil
+}
+
+// Price returns the price of a catalog item as it is used in the cart. The catalog
+// stores prices as float32, so the value is rounded to cents to prevent values like
+// 19.989999771118164 from showing up in a cart.
+func Price(product acmeserverless.CatalogItem) float64 {
+	return math.Round(float64(product.Price)*100) / 100
+}
+
+// PriceChange describes an item in the cart of which the price in the
+// catalog is different from the price that was stored in the cart.
+type PriceChange struct {
+	// ItemID is the unique identifier of the item
+	ItemID string `json:"itemid"`
+
+	// Name is the name of the item
+	Name string `json:"name"`
+
+	// OldPrice is the price of the item that was stored in the cart
+	OldPrice float64 `json:"oldprice"`
+
+	// NewPrice is the current price of the item in the catalog
+	NewPrice float64 `json:"newprice"`
+}
+
+// RepriceResult describes the differences between the items in
+// a cart and the current state of the catalog.
+type RepriceResult struct {
+	// Changed are the items of which the price has changed
+	Changed []PriceChange `json:"changed"`
+
+	// Unavailable are the items that no longer exist in the catalog
+	Unavailable datastore.CartItems `json:"unavailable"`
+
+	// UserID is the unique identifier of the user in the ACME Serverless Fitness Shop
+	UserID string `json:"userid"`
+}
+
+// Marshal returns the JSON encoding of RepriceResult
+func (r *RepriceResult) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// Reprice looks up the current price of each item in the catalog and returns the
+// updated items together with the changes. Items that no longer exist in the catalog
+// are kept unchanged in the cart and are reported as unavailable, so the user can
+// decide what to do with them. Bundles have a price of their own, so they are kept as
+// they are. Any other error from the catalog stops the repricing.
+func Reprice(c CatalogClient, items datastore.CartItems) (datastore.CartItems, RepriceResult, error) {
+	repriced := make(datastore.CartItems, len(items))
+	res := RepriceResult{
+		Changed:     make([]PriceChange, 0),
+		Unavailable: make(datastore.CartItems, 0),
+	}
+
+	for idx, item := range items {
+		repriced[idx] = item
+
+		if item.IsBundle() {
+			continue
+		}
+
+		if item.ItemID == nil {
+			res.Unavailable = append(res.Unavailable, item)
+			continue
+		}
+
+		product, err := c.GetItem(*item.ItemID)
+		if errors.Is(err, ErrItemNotFound) {
+			res.Unavailable = append(res.Unavailable, item)
+			continue
+		}
+		if err != nil {
+			return nil, res, fmt.Errorf("unable to reprice item %s: %w", *item.ItemID, err)
+		}
+
+		price := Price(product)
+		if price != item.Price {
+			res.Changed = append(res.Changed, PriceChange{
+				ItemID:   *item.ItemID,
+				Name:     item.Name,
+				OldPrice: item.Price,
+				NewPrice: price,
+			})
+			repriced[idx].Price = price
+		}
+	}
+
+	return repriced, res, nil
+}
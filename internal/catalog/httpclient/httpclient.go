@@ -0,0 +1,69 @@
+// Package httpclient retrieves products from the Catalog service of the ACME Serverless
+// Fitness Shop using its HTTP API.
+package httpclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-cart/internal/catalog"
+)
+
+// client implements the methods of the CatalogClient interface
+// using the HTTP API of the Catalog service.
+type client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// response is the payload the Catalog service sends back when
+// a single product is requested.
+type response struct {
+	Data acmeserverless.CatalogItem `json:"data"`
+}
+
+// New creates a new CatalogClient that connects to the Catalog service
+// available at baseURL (like https://<id>.execute-api.us-west-2.amazonaws.com/Prod)
+func New(baseURL string) catalog.CatalogClient {
+	return client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{
+			Timeout: time.Second * 3,
+		},
+	}
+}
+
+// GetItem retrieves a single product from the Catalog service
+func (c client) GetItem(itemID string) (acmeserverless.CatalogItem, error) {
+	res, err := c.httpClient.Get(fmt.Sprintf("%s/products/%s", c.baseURL, url.PathEscape(itemID)))
+	if err != nil {
+		return acmeserverless.CatalogItem{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return acmeserverless.CatalogItem{}, catalog.ErrItemNotFound
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return acmeserverless.CatalogItem{}, fmt.Errorf("catalog responded with status %d", res.StatusCode)
+	}
+
+	var r response
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return acmeserverless.CatalogItem{}, fmt.Errorf("unable to decode catalog response: %s", err.Error())
+	}
+
+	// The Catalog service returns an empty product rather than a 404
+	// when the item doesn't exist
+	if len(r.Data.ID) == 0 {
+		return acmeserverless.CatalogItem{}, catalog.ErrItemNotFound
+	}
+
+	return r.Data, nil
+}
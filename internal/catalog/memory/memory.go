@@ -0,0 +1,59 @@
+// Package memory keeps catalog items in memory. It is meant to be used as an in-process
+// fake of the Catalog service, for example when running the Cart service locally.
+package memory
+
+import (
+	"sync"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-cart/internal/catalog"
+)
+
+// Client implements the methods of the CatalogClient interface
+// using a map of catalog items.
+type Client struct {
+	mu    sync.RWMutex
+	items map[string]acmeserverless.CatalogItem
+}
+
+// New creates a new in-memory CatalogClient that contains the given items
+func New(items ...acmeserverless.CatalogItem) *Client {
+	c := &Client{
+		items: make(map[string]acmeserverless.CatalogItem),
+	}
+
+	for _, item := range items {
+		c.items[item.ID] = item
+	}
+
+	return c
+}
+
+// GetItem retrieves a single item from the in-memory catalog
+func (c *Client) GetItem(itemID string) (acmeserverless.CatalogItem, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	item, ok := c.items[itemID]
+	if !ok {
+		return acmeserverless.CatalogItem{}, catalog.ErrItemNotFound
+	}
+
+	return item, nil
+}
+
+// SetItem adds or replaces an item in the in-memory catalog
+func (c *Client) SetItem(item acmeserverless.CatalogItem) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items[item.ID] = item
+}
+
+// RemoveItem removes an item from the in-memory catalog
+func (c *Client) RemoveItem(itemID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.items, itemID)
+}
@@ -4,17 +4,277 @@
 // needs to be implemented.
 package datastore
 
-import acmeserverless "github.com/retgits/acme-serverless"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrCartNotFound is returned by a Manager when there is no cart for the user.
+var ErrCartNotFound = errors.New("cart not found")
+
+// ErrItemNotFound is returned by a Manager when an item isn't in the cart
+// or the saved-for-later list.
+var ErrItemNotFound = errors.New("item not found")
+
+// ErrCartExists is returned by a Manager when a cart is created that already exists.
+var ErrCartExists = errors.New("cart already exists")
+
+// ErrCartLocked is returned by a Manager when a cart is changed while it is locked.
+var ErrCartLocked = errors.New("cart is locked")
+
+// ErrVersionNotFound is returned by a Manager when a version of a cart isn't kept.
+var ErrVersionNotFound = errors.New("version not found")
+
+// ErrVersionMismatch is returned by a Manager when a cart isn't at the expected version,
+// because it was changed in the meantime.
+var ErrVersionMismatch = errors.New("cart has changed")
+
+// ErrInvalidRequest is wrapped by errors about requests that are malformed or have
+// values that aren't valid, so they can be told apart from errors of the service.
+var ErrInvalidRequest = errors.New("invalid request")
+
+// ErrIdempotencyKeyUsed is returned by a Manager when a request is claimed with an
+// idempotency key that is already used by a request that hasn't expired.
+var ErrIdempotencyKeyUsed = errors.New("idempotency key already used")
+
+// DefaultCart is the ID of the cart that every user has.
+const DefaultCart = "default"
+
+// HistorySize is the number of versions that is kept for every cart.
+const HistorySize = 20
+
+// AnyVersion is passed to the methods of a Manager that take a version, to change a cart
+// regardless of its version.
+const AnyVersion = int64(-1)
+
+// Version is a stored state of the items in a cart. Every change to the items
+// in a cart creates a new version, numbered from 1.
+type Version struct {
+	// Version is the number of the version
+	Version int64 `json:"version"`
+
+	// Items are the items in the cart at this version
+	Items CartItems `json:"items"`
+
+	// Created is when the version was stored
+	Created time.Time `json:"created"`
+}
+
+// CartInfo describes one of the carts of a user.
+type CartInfo struct {
+	// ID is the unique identifier of the cart for the user
+	ID string `json:"id"`
+
+	// Name is the name of the cart, like "gym A restock"
+	Name string `json:"name"`
+}
+
+// Role is the role a user has on a cart.
+type Role string
+
+const (
+	// RoleOwner is the role of the user the cart belongs to. Owners can do
+	// everything with a cart, including sharing it with others.
+	RoleOwner Role = "owner"
+
+	// RoleEditor is the role of collaborators that can change the items in a cart.
+	RoleEditor Role = "editor"
+
+	// RoleViewer is the role of collaborators that can only see the items in a cart.
+	RoleViewer Role = "viewer"
+)
+
+// Member is a collaborator on a cart of another user.
+type Member struct {
+	// UserID is the unique identifier of the collaborator
+	UserID string `json:"userid"`
+
+	// Role is the role of the collaborator on the cart
+	Role Role `json:"role"`
+}
+
+// IdempotentRequest is a request that was made with an idempotency key. Once the request
+// is done, the response is stored with it so it can be returned when the request is retried.
+type IdempotentRequest struct {
+	// Key is the idempotency key of the request
+	Key string `json:"key"`
+
+	// Fingerprint is a hash of the request, to detect keys that are used for another request
+	Fingerprint string `json:"fingerprint"`
+
+	// Expires is when the key can be used again
+	Expires time.Time `json:"expires"`
+
+	// Done is true when the response is stored
+	Done bool `json:"done"`
+
+	// StatusCode is the HTTP status code of the response
+	StatusCode int `json:"statuscode"`
+
+	// ContentType is the content type of the response
+	ContentType string `json:"contenttype"`
+
+	// Body is the body of the response
+	Body string `json:"body"`
+}
+
+// CartKey returns the key under which a cart of a user is stored. The
+// methods of the Manager that take a userID expect this key, so they work
+// on named carts as well. The default cart is stored under the userID so
+// carts created before users could have multiple carts keep working.
+func CartKey(userID string, cartID string) string {
+	if len(cartID) == 0 || cartID == DefaultCart {
+		return userID
+	}
+	return userID + "#" + cartID
+}
+
+// SplitCartKey returns the userID and the cartID of a key created with CartKey.
+func SplitCartKey(key string) (string, string) {
+	if idx := strings.Index(key, "#"); idx >= 0 {
+		return key[:idx], key[idx+1:]
+	}
+	return key, DefaultCart
+}
 
 // Manager is the interface that describes the methods the
 // data store needs to implement to be able to work with
 // the ACME Serverless Fitness Shop.
 type Manager interface {
-	GetItems(userID string) (acmeserverless.CartItems, error)
-	AddItem(userID string, i acmeserverless.CartItem) error
-	AllCarts() (acmeserverless.Carts, error)
+	GetItems(userID string) (CartItems, error)
+	AddItem(userID string, i CartItem) error
+	AllCarts() (Carts, error)
 	ClearCart(userID string) error
-	StoreItems(userID string, i acmeserverless.CartItems) error
+	StoreItems(userID string, i CartItems) error
 	ItemsInCart(userID string) (int64, error)
 	ValueInCart(userID string) (float64, error)
+
+	// MoveCart stores the items as the cart of toID and removes the cart of fromID.
+	// Backends that support transactions do this atomically.
+	MoveCart(fromID string, toID string, i CartItems) error
+
+	// UpdatedAt returns when the cart of the user was last changed. The zero time
+	// is returned for carts that were last changed before this was recorded.
+	UpdatedAt(userID string) (time.Time, error)
+
+	// RemindedAt returns when a reminder about the cart was last sent. The zero time
+	// is returned when no reminder was sent.
+	RemindedAt(key string) (time.Time, error)
+
+	// SetRemindedAt records that a reminder about the cart was sent, without changing
+	// when the cart was last changed.
+	SetRemindedAt(key string, t time.Time) error
+
+	// Versions returns the versions of the cart with the key that are kept, newest first.
+	// Carts that weren't changed since versions are stored have no versions.
+	Versions(key string) ([]Version, error)
+
+	// GetVersion returns a version of the cart with the key, or ErrVersionNotFound
+	// when that version isn't kept.
+	GetVersion(key string, version int64) (Version, error)
+
+	// StoreItemsIfVersion saves the items like StoreItems, but only when the cart with the
+	// key is at the version. ErrVersionMismatch is returned otherwise. A cart without
+	// versions is at version 0, and with AnyVersion the items are always stored.
+	StoreItemsIfVersion(key string, i CartItems, version int64) error
+
+	// ListCarts returns the default cart and the named carts of a user.
+	ListCarts(userID string) ([]CartInfo, error)
+
+	// CreateCart creates an empty named cart for a user, or returns
+	// ErrCartExists if the user already has a cart with that ID.
+	CreateCart(userID string, c CartInfo) error
+
+	// RenameCart changes the name of a cart of a user.
+	RenameCart(userID string, cartID string, name string) error
+
+	// DeleteCart removes a named cart of a user.
+	DeleteCart(userID string, cartID string) error
+
+	// GetSavedItems returns the saved-for-later list of a user. The saved items
+	// are not part of any cart, so they don't count towards ItemsInCart and ValueInCart.
+	GetSavedItems(userID string) (CartItems, error)
+
+	// SaveForLater moves the line with the same identity as line from the cart with the key
+	// to the saved-for-later list of the user of that cart. Both lists are updated atomically.
+	// Unless version is AnyVersion, ErrVersionMismatch is returned when the cart isn't at
+	// the version.
+	SaveForLater(key string, line CartItem, version int64) error
+
+	// MoveToCart moves the line with the same identity as line from the saved-for-later list
+	// of the user to the cart with the key, like SaveForLater.
+	MoveToCart(key string, line CartItem, version int64) error
+
+	// Members returns the collaborators of the cart with the key. The owner
+	// of the cart is not one of the members.
+	Members(key string) ([]Member, error)
+
+	// SetMember adds a collaborator to the cart with the key, or changes the
+	// role of an existing collaborator. ErrCartNotFound is returned when the cart doesn't exist.
+	SetMember(key string, m Member) error
+
+	// RemoveMember removes a collaborator from the cart with the key.
+	RemoveMember(key string, userID string) error
+
+	// LockCart locks the cart with the key for the duration, so the items in the cart can't be
+	// changed until it is unlocked or the lock expires. ErrCartLocked is returned when the cart
+	// is already locked.
+	LockCart(key string, lockID string, d time.Duration) error
+
+	// UnlockCart removes the lock with the lockID from the cart with the key.
+	UnlockCart(key string, lockID string) error
+
+	// CheckoutCart empties the cart with the key that was locked with the lockID and removes
+	// the lock. When archive is true, the items are kept as an archived cart with the lockID as ID.
+	CheckoutCart(key string, lockID string, archive bool) error
+
+	// ClaimIdempotencyKey stores the request, unless a request with the same key is stored
+	// that hasn't expired yet. In that case the stored request is returned together with
+	// ErrIdempotencyKeyUsed.
+	ClaimIdempotencyKey(r IdempotentRequest) (IdempotentRequest, error)
+
+	// CompleteIdempotencyKey stores the response of the request with the key of r.
+	CompleteIdempotencyKey(r IdempotentRequest) error
+
+	// ReleaseIdempotencyKey removes the request with the key, so it can be made again.
+	ReleaseIdempotencyKey(key string) error
+}
+
+// MoveItem moves the line with the same identity as line (the itemid and options) from one
+// list of items to another and returns the updated lists. When the line is already in the
+// destination, the quantities are added. ErrItemNotFound is returned when from doesn't
+// contain the line.
+func MoveItem(from CartItems, to CartItems, line CartItem) (CartItems, CartItems, error) {
+	remaining := make(CartItems, 0, len(from))
+	moved := make(CartItems, len(to))
+	copy(moved, to)
+	found := false
+
+	for _, item := range from {
+		if !item.SameLine(line) {
+			remaining = append(remaining, item)
+			continue
+		}
+
+		found = true
+		merged := false
+		for idx, mi := range moved {
+			if mi.SameLine(item) {
+				moved[idx].Quantity = mi.Quantity + item.Quantity
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			moved = append(moved, item)
+		}
+	}
+
+	if !found {
+		return nil, nil, fmt.Errorf("%w: no line %s", ErrItemNotFound, line.LineID())
+	}
+
+	return remaining, moved, nil
 }
@@ -0,0 +1,69 @@
+package datastore
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ItemCountPolicy decides how bundles are counted in the number of items in a cart
+type ItemCountPolicy string
+
+const (
+	// CountLines counts a bundle as a single item
+	CountLines ItemCountPolicy = "lines"
+
+	// CountComponents counts the components of a bundle instead of the bundle
+	CountComponents ItemCountPolicy = "components"
+)
+
+// ParseItemCountPolicy returns the ItemCountPolicy with the name. An empty
+// name returns CountLines.
+func ParseItemCountPolicy(name string) (ItemCountPolicy, error) {
+	switch p := ItemCountPolicy(strings.ToLower(name)); p {
+	case "":
+		return CountLines, nil
+	case CountLines, CountComponents:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown item count policy %s", name)
+	}
+}
+
+// IsBundle returns true when the line is a bundle with components
+func (r CartItem) IsBundle() bool {
+	return len(r.Children) > 0
+}
+
+// Components returns the items with every bundle replaced by its components. The
+// quantity of a component is multiplied by the quantity of the bundle.
+func Components(items CartItems) CartItems {
+	res := make(CartItems, 0, len(items))
+
+	for _, item := range items {
+		if !item.IsBundle() {
+			res = append(res, item)
+			continue
+		}
+
+		for _, child := range Components(item.Children) {
+			child.Quantity = child.Quantity * item.Quantity
+			res = append(res, child)
+		}
+	}
+
+	return res
+}
+
+// CountItems returns the number of items in a cart, counting bundles according to the policy
+func CountItems(items CartItems, policy ItemCountPolicy) int64 {
+	if policy == CountComponents {
+		items = Components(items)
+	}
+
+	numItems := int64(0)
+	for _, ci := range items {
+		numItems = numItems + ci.Quantity
+	}
+
+	return numItems
+}
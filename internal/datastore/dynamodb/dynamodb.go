@@ -3,21 +3,27 @@
 package dynamodb
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
-	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	"github.com/retgits/acme-serverless-cart/internal/datastore"
 )
 
 // The pointer to DynamoDB provides the API operation methods for making requests to Amazon DynamoDB.
 // This specifically creates a single instance of the dynamoDB service which can be reused if the
 // container stays warm.
-var dbs *dynamodb.DynamoDB
+var dbs dynamodbiface.DynamoDBAPI
 
 // manager is an empty struct that implements the methods of the
 // Manager interface.
@@ -44,7 +50,7 @@ func New() datastore.Manager {
 }
 
 // GetItems retrieves all items for a single user from DynamoDB based on the userID
-func (m manager) GetItems(userID string) (acmeserverless.CartItems, error) {
+func (m manager) GetItems(userID string) (datastore.CartItems, error) {
 	// Create a map of DynamoDB Attribute Values containing the table keys
 	// for the access pattern PK = CART SK = ID
 	km := make(map[string]*dynamodb.AttributeValue)
@@ -65,20 +71,20 @@ func (m manager) GetItems(userID string) (acmeserverless.CartItems, error) {
 	// Execute the DynamoDB query
 	qo, err := dbs.Query(qi)
 	if err != nil {
-		return acmeserverless.CartItems{}, err
+		return datastore.CartItems{}, err
 	}
 
 	// Return an error if no data was found
-	if qo.Items[0]["Payload"].S == nil {
-		return nil, fmt.Errorf("no items found with for user with id %s", userID)
+	if len(qo.Items) == 0 || qo.Items[0]["Payload"] == nil || qo.Items[0]["Payload"].S == nil {
+		return nil, fmt.Errorf("%w: no items found with for user with id %s", datastore.ErrCartNotFound, userID)
 	}
 
-	str := *qo.Items[0]["Payload"].S
-	return acmeserverless.UnmarshalItems(str)
+	// Carts that have been cleared contain an empty payload
+	return datastore.UnmarshalPayload(*qo.Items[0]["Payload"].S)
 }
 
 // AddItem adds a new item for the user to the cart
-func (m manager) AddItem(userID string, i acmeserverless.CartItem) error {
+func (m manager) AddItem(userID string, i datastore.CartItem) error {
 	items, err := m.GetItems(userID)
 	if err != nil {
 		return err
@@ -90,32 +96,11 @@ func (m manager) AddItem(userID string, i acmeserverless.CartItem) error {
 		return err
 	}
 
-	km := make(map[string]*dynamodb.AttributeValue)
-	km[":type"] = &dynamodb.AttributeValue{
-		S: aws.String("CART"),
-	}
-	km[":id"] = &dynamodb.AttributeValue{
-		S: aws.String(userID),
-	}
-
-	em := make(map[string]*dynamodb.AttributeValue)
-	em[":payload"] = &dynamodb.AttributeValue{
-		S: aws.String(string(cc)),
-	}
-
-	uii := &dynamodb.UpdateItemInput{
-		TableName:                 aws.String(os.Getenv("TABLE")),
-		Key:                       km,
-		ExpressionAttributeValues: em,
-		UpdateExpression:          aws.String("SET Payload = :payload"),
-	}
-
-	_, err = dbs.UpdateItem(uii)
-	return err
+	return storePayload(userID, string(cc), anyVersion)
 }
 
 // AllCarts retrieves all carts from DynamoDB
-func (m manager) AllCarts() (acmeserverless.Carts, error) {
+func (m manager) AllCarts() (datastore.Carts, error) {
 	// Create a map of DynamoDB Attribute Values containing the table keys
 	// for the access pattern PK = CART
 	km := make(map[string]*dynamodb.AttributeValue)
@@ -130,28 +115,42 @@ func (m manager) AllCarts() (acmeserverless.Carts, error) {
 		ExpressionAttributeValues: km,
 	}
 
-	qo, err := dbs.Query(qi)
-	if err != nil {
-		return nil, err
+	// A query returns at most 1 MB of data, so the next pages are read until there is no
+	// LastEvaluatedKey anymore
+	items := make([]map[string]*dynamodb.AttributeValue, 0)
+	for {
+		qo, err := dbs.Query(qi)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, qo.Items...)
+
+		if len(qo.LastEvaluatedKey) == 0 {
+			break
+		}
+		qi.ExclusiveStartKey = qo.LastEvaluatedKey
 	}
 
 	// Return an error if no data was found
-	if len(qo.Items) == 0 {
+	if len(items) == 0 {
 		return nil, fmt.Errorf("no item data found")
 	}
 
-	carts := make(acmeserverless.Carts, 0)
+	carts := make(datastore.Carts, 0)
 
-	for _, ct := range qo.Items {
-		str := *ct["Payload"].S
-
-		cartContent, err := acmeserverless.UnmarshalItems(str)
+	for _, ct := range items {
+		// Skip records without a payload, like the saved-for-later list of users without a default cart
+		if ct["Payload"] == nil || ct["Payload"].S == nil {
+			continue
+		}
+		cartContent, err := datastore.UnmarshalPayload(*ct["Payload"].S)
 		if err != nil {
 			log.Println(fmt.Sprintf("error unmarshalling cart data: %s", err.Error()))
 			continue
 		}
 
-		carts = append(carts, acmeserverless.Cart{
+		carts = append(carts, datastore.Cart{
 			Items:  cartContent,
 			UserID: *ct["SK"].S,
 		})
@@ -162,93 +161,1076 @@ func (m manager) AllCarts() (acmeserverless.Carts, error) {
 
 // ClearCart sets the cart for a user to an empty JSON string
 func (m manager) ClearCart(userID string) error {
-	// Create a map of DynamoDB Attribute Values containing the table keys
-	// for the access pattern PK = CART SK = ID
-	km := make(map[string]*dynamodb.AttributeValue)
-	km[":type"] = &dynamodb.AttributeValue{
-		S: aws.String("CART"),
+	return storePayload(userID, "[]", anyVersion)
+}
+
+// StoreItems saves the cart items from a single user into Amazon DynamoDB
+func (m manager) StoreItems(userID string, i datastore.CartItems) error {
+	payload, err := i.Marshal()
+	if err != nil {
+		return err
 	}
-	km[":id"] = &dynamodb.AttributeValue{
-		S: aws.String(userID),
+
+	return storePayload(userID, string(payload), anyVersion)
+}
+
+// ItemsInCart gets the number of items in a cart for the user, counting bundles
+// according to ITEM_COUNT_POLICY
+func (m manager) ItemsInCart(userID string) (int64, error) {
+	items, err := m.GetItems(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	policy, err := datastore.ParseItemCountPolicy(os.Getenv("ITEM_COUNT_POLICY"))
+	if err != nil {
+		return 0, err
+	}
+
+	return datastore.CountItems(items, policy), nil
+}
+
+// ValueInCart gets the value of the items in a cart for the user
+func (m manager) ValueInCart(userID string) (float64, error) {
+	items, err := m.GetItems(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	value := float64(0)
+
+	for _, ci := range items {
+		value = value + (float64(ci.Quantity) * ci.Price)
+	}
+
+	return value, nil
+}
+
+// MoveCart stores the items as the cart of toID and removes the cart of fromID in a single transaction
+func (m manager) MoveCart(fromID string, toID string, i datastore.CartItems) error {
+	payload, err := i.Marshal()
+	if err != nil {
+		return err
+	}
+
+	version, err := currentVersion(toID)
+	if err != nil {
+		return err
+	}
+
+	u := &dynamodb.Update{
+		TableName:                 aws.String(os.Getenv("TABLE")),
+		Key:                       key(toID),
+		ExpressionAttributeValues: payloadValues(string(payload)),
+		UpdateExpression:          aws.String("SET Payload = :payload, Updated = :updated"),
+		ConditionExpression:       aws.String(unlocked),
+	}
+
+	twi := &dynamodb.TransactWriteItemsInput{
+		TransactItems: append(versioned(toID, u, version, string(payload)), &dynamodb.TransactWriteItem{
+			Delete: &dynamodb.Delete{
+				TableName: aws.String(os.Getenv("TABLE")),
+				Key:       key(fromID),
+			},
+		}),
+	}
+
+	_, err = dbs.TransactWriteItems(twi)
+	if isTransactionCanceled(err) {
+		return changeError(toID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return deleteVersions(fromID)
+}
+
+// UpdatedAt returns when the cart of the user was last changed
+func (m manager) UpdatedAt(userID string) (time.Time, error) {
+	gi := &dynamodb.GetItemInput{
+		TableName:            aws.String(os.Getenv("TABLE")),
+		Key:                  key(userID),
+		ProjectionExpression: aws.String("Updated"),
+	}
+
+	gio, err := dbs.GetItem(gi)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if gio.Item == nil {
+		return time.Time{}, fmt.Errorf("%w: no cart found for user with id %s", datastore.ErrCartNotFound, userID)
+	}
+
+	if gio.Item["Updated"] == nil || gio.Item["Updated"].S == nil {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339Nano, *gio.Item["Updated"].S)
+}
+
+// RemindedAt returns when a reminder about the cart was last sent
+func (m manager) RemindedAt(cartKey string) (time.Time, error) {
+	gi := &dynamodb.GetItemInput{
+		TableName:            aws.String(os.Getenv("TABLE")),
+		Key:                  key(cartKey),
+		ProjectionExpression: aws.String("SK, Reminded"),
+	}
+
+	gio, err := dbs.GetItem(gi)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if gio.Item == nil {
+		return time.Time{}, fmt.Errorf("%w: no cart found with key %s", datastore.ErrCartNotFound, cartKey)
+	}
+
+	if gio.Item["Reminded"] == nil || gio.Item["Reminded"].S == nil {
+		return time.Time{}, nil
 	}
 
+	return time.Parse(time.RFC3339Nano, *gio.Item["Reminded"].S)
+}
+
+// SetRemindedAt records that a reminder about the cart was sent
+func (m manager) SetRemindedAt(cartKey string, t time.Time) error {
 	em := make(map[string]*dynamodb.AttributeValue)
-	em[":payload"] = &dynamodb.AttributeValue{
-		S: aws.String("{}"),
+	em[":reminded"] = &dynamodb.AttributeValue{
+		S: aws.String(t.UTC().Format(time.RFC3339Nano)),
 	}
 
 	uii := &dynamodb.UpdateItemInput{
 		TableName:                 aws.String(os.Getenv("TABLE")),
-		Key:                       km,
+		Key:                       key(cartKey),
 		ExpressionAttributeValues: em,
-		UpdateExpression:          aws.String("SET Payload = :payload"),
+		UpdateExpression:          aws.String("SET Reminded = :reminded"),
+		ConditionExpression:       aws.String("attribute_exists(SK)"),
 	}
 
 	_, err := dbs.UpdateItem(uii)
+	if isConditionalCheckFailed(err) {
+		return fmt.Errorf("%w: no cart found with key %s", datastore.ErrCartNotFound, cartKey)
+	}
+
 	return err
 }
 
-// StoreItems saves the cart items from a single user into Amazon DynamoDB
-func (m manager) StoreItems(userID string, i acmeserverless.CartItems) error {
-	payload, err := i.Marshal()
-	if err != nil {
-		return err
-	}
-
-	// Create a map of DynamoDB Attribute Values containing the table keys
-	// for the access pattern PK = CART SK = ID
+// key returns the table keys of the cart of a user
+// for the access pattern PK = CART SK = ID
+func key(userID string) map[string]*dynamodb.AttributeValue {
 	km := make(map[string]*dynamodb.AttributeValue)
-	km[":type"] = &dynamodb.AttributeValue{
+	km["PK"] = &dynamodb.AttributeValue{
 		S: aws.String("CART"),
 	}
-	km[":id"] = &dynamodb.AttributeValue{
+	km["SK"] = &dynamodb.AttributeValue{
 		S: aws.String(userID),
 	}
+	return km
+}
 
+// payloadValues returns the expression attribute values to store a payload
+// together with the time it was stored, when the cart isn't locked
+func payloadValues(payload string) map[string]*dynamodb.AttributeValue {
 	em := make(map[string]*dynamodb.AttributeValue)
 	em[":payload"] = &dynamodb.AttributeValue{
-		S: aws.String(string(payload)),
+		S: aws.String(payload),
 	}
+	em[":updated"] = &dynamodb.AttributeValue{
+		S: aws.String(time.Now().UTC().Format(time.RFC3339Nano)),
+	}
+	em[":now"] = &dynamodb.AttributeValue{
+		N: aws.String(millis(time.Now())),
+	}
+	return em
+}
 
-	uii := &dynamodb.UpdateItemInput{
+// unlocked is the condition expression for changes to carts that aren't locked. The
+// lock of a cart expires at LockedUntil, in milliseconds since the epoch.
+const unlocked = "attribute_not_exists(LockedUntil) OR LockedUntil < :now"
+
+// millis returns the time in milliseconds since the epoch
+func millis(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+}
+
+// anyVersion is passed to storePayload to store the payload regardless of the version of the cart
+const anyVersion = datastore.AnyVersion
+
+// storePayload saves the payload as a new version of the cart of a user, creating the cart
+// if it doesn't exist yet. Unless expected is anyVersion, the cart has to be at that version.
+func storePayload(userID string, payload string, expected int64) error {
+	version, err := currentVersion(userID)
+	if err != nil {
+		return err
+	}
+
+	if expected != anyVersion && expected != version {
+		return fmt.Errorf("%w: cart %s is at version %d", datastore.ErrVersionMismatch, userID, version)
+	}
+
+	u := &dynamodb.Update{
 		TableName:                 aws.String(os.Getenv("TABLE")),
-		Key:                       km,
-		ExpressionAttributeValues: em,
-		UpdateExpression:          aws.String("SET Payload = :payload"),
+		Key:                       key(userID),
+		ExpressionAttributeValues: payloadValues(payload),
+		UpdateExpression:          aws.String("SET Payload = :payload, Updated = :updated"),
+		ConditionExpression:       aws.String(unlocked),
+	}
+
+	_, err = dbs.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: versioned(userID, u, version, payload),
+	})
+	if isTransactionCanceled(err) {
+		return changeError(userID)
 	}
 
-	_, err = dbs.UpdateItem(uii)
 	return err
 }
 
-// ItemsInCart gets the number of items in a cart for the user
-func (m manager) ItemsInCart(userID string) (int64, error) {
-	items, err := m.GetItems(userID)
+// versionPrefix is the prefix of the PK of the versions of a cart, which are
+// stored for the access pattern PK = VERSION#KEY SK = VERSION
+const versionPrefix = "VERSION#"
+
+// versionKey returns the table keys of a version of a cart
+func versionKey(cartKey string, version int64) map[string]*dynamodb.AttributeValue {
+	km := make(map[string]*dynamodb.AttributeValue)
+	km["PK"] = &dynamodb.AttributeValue{
+		S: aws.String(versionPrefix + cartKey),
+	}
+	// The version is padded, so versions are sorted by number
+	km["SK"] = &dynamodb.AttributeValue{
+		S: aws.String(fmt.Sprintf("%010d", version)),
+	}
+	return km
+}
+
+// currentVersion returns the version of the cart with the key, which is 0 when
+// the cart doesn't exist or has no versions
+func currentVersion(cartKey string) (int64, error) {
+	gi := &dynamodb.GetItemInput{
+		TableName:            aws.String(os.Getenv("TABLE")),
+		Key:                  key(cartKey),
+		ProjectionExpression: aws.String("SK, Version"),
+	}
+
+	gio, err := dbs.GetItem(gi)
 	if err != nil {
 		return 0, err
 	}
 
-	numItems := int64(0)
+	if gio.Item == nil || gio.Item["Version"] == nil || gio.Item["Version"].N == nil {
+		return 0, nil
+	}
 
-	for _, ci := range items {
-		numItems = numItems + ci.Quantity
+	return strconv.ParseInt(*gio.Item["Version"].N, 10, 64)
+}
+
+// versioned changes the update of the payload of a cart, so it also sets the next version
+// of the cart, with the condition that the cart is still at the current version. It returns
+// the update together with the writes that store the next version and remove the version
+// that no longer fits in the history.
+func versioned(cartKey string, u *dynamodb.Update, version int64, payload string) []*dynamodb.TransactWriteItem {
+	next := version + 1
+
+	u.ExpressionAttributeValues[":version"] = &dynamodb.AttributeValue{
+		N: aws.String(strconv.FormatInt(next, 10)),
+	}
+	u.UpdateExpression = aws.String(strings.Replace(*u.UpdateExpression, "SET ", "SET Version = :version, ", 1))
+
+	condition := "attribute_not_exists(Version)"
+	if version > 0 {
+		u.ExpressionAttributeValues[":prev"] = &dynamodb.AttributeValue{
+			N: aws.String(strconv.FormatInt(version, 10)),
+		}
+		condition = "Version = :prev"
 	}
+	u.ConditionExpression = aws.String("(" + *u.ConditionExpression + ") AND " + condition)
 
-	return numItems, nil
+	item := versionKey(cartKey, next)
+	item["Version"] = u.ExpressionAttributeValues[":version"]
+	item["Payload"] = &dynamodb.AttributeValue{
+		S: aws.String(payload),
+	}
+	item["Created"] = u.ExpressionAttributeValues[":updated"]
+
+	writes := []*dynamodb.TransactWriteItem{
+		{
+			Update: u,
+		},
+		{
+			Put: &dynamodb.Put{
+				TableName: aws.String(os.Getenv("TABLE")),
+				Item:      item,
+			},
+		},
+	}
+
+	if oldest := next - datastore.HistorySize; oldest > 0 {
+		writes = append(writes, &dynamodb.TransactWriteItem{
+			Delete: &dynamodb.Delete{
+				TableName: aws.String(os.Getenv("TABLE")),
+				Key:       versionKey(cartKey, oldest),
+			},
+		})
+	}
+
+	return writes
 }
 
-// ValueInCart gets the value of the items in a cart for the user
-func (m manager) ValueInCart(userID string) (float64, error) {
-	items, err := m.GetItems(userID)
+// changeError returns the error for a change to a cart that was canceled, either because
+// the cart is locked or because it was changed at the same time
+func changeError(cartKey string) error {
+	gi := &dynamodb.GetItemInput{
+		TableName:            aws.String(os.Getenv("TABLE")),
+		Key:                  key(cartKey),
+		ProjectionExpression: aws.String("SK, LockedUntil"),
+	}
+
+	gio, err := dbs.GetItem(gi)
+	if err == nil && gio.Item != nil && gio.Item["LockedUntil"] != nil && gio.Item["LockedUntil"].N != nil {
+		until, err := strconv.ParseInt(*gio.Item["LockedUntil"].N, 10, 64)
+		if err == nil && until > time.Now().UnixNano()/int64(time.Millisecond) {
+			return fmt.Errorf("%w: cart %s can't be changed", datastore.ErrCartLocked, cartKey)
+		}
+	}
+
+	return fmt.Errorf("%w: cart %s was changed at the same time", datastore.ErrVersionMismatch, cartKey)
+}
+
+// Versions returns the versions of a cart that are kept, newest first
+func (m manager) Versions(cartKey string) ([]datastore.Version, error) {
+	km := make(map[string]*dynamodb.AttributeValue)
+	km[":pk"] = &dynamodb.AttributeValue{
+		S: aws.String(versionPrefix + cartKey),
+	}
+
+	qi := &dynamodb.QueryInput{
+		TableName:                 aws.String(os.Getenv("TABLE")),
+		KeyConditionExpression:    aws.String("PK = :pk"),
+		ExpressionAttributeValues: km,
+		ScanIndexForward:          aws.Bool(false),
+	}
+
+	qo, err := dbs.Query(qi)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	value := float64(0)
+	// Make sure the cart exists when it has no versions
+	if len(qo.Items) == 0 {
+		if _, err := m.GetItems(cartKey); err != nil {
+			return nil, err
+		}
+	}
 
-	for _, ci := range items {
-		value = value + (float64(ci.Quantity) * ci.Price)
+	versions := make([]datastore.Version, 0, len(qo.Items))
+	for _, item := range qo.Items {
+		v, err := unmarshalVersion(item)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
 	}
 
-	return value, nil
+	return versions, nil
+}
+
+// GetVersion returns a version of a cart
+func (m manager) GetVersion(cartKey string, version int64) (datastore.Version, error) {
+	gio, err := dbs.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(os.Getenv("TABLE")),
+		Key:       versionKey(cartKey, version),
+	})
+	if err != nil {
+		return datastore.Version{}, err
+	}
+
+	if gio.Item == nil {
+		return datastore.Version{}, fmt.Errorf("%w: cart %s has no version %d", datastore.ErrVersionNotFound, cartKey, version)
+	}
+
+	return unmarshalVersion(gio.Item)
+}
+
+// StoreItemsIfVersion saves the cart items when the cart is at the version
+func (m manager) StoreItemsIfVersion(cartKey string, i datastore.CartItems, version int64) error {
+	payload, err := i.Marshal()
+	if err != nil {
+		return err
+	}
+
+	return storePayload(cartKey, string(payload), version)
+}
+
+// unmarshalVersion creates a Version from the attributes of a stored version
+func unmarshalVersion(item map[string]*dynamodb.AttributeValue) (datastore.Version, error) {
+	var v datastore.Version
+
+	version, err := strconv.ParseInt(*item["Version"].N, 10, 64)
+	if err != nil {
+		return v, err
+	}
+	v.Version = version
+
+	created, err := time.Parse(time.RFC3339Nano, *item["Created"].S)
+	if err != nil {
+		return v, err
+	}
+	v.Created = created
+
+	// Versions of carts that have been cleared contain an empty payload
+	v.Items, err = datastore.UnmarshalPayload(*item["Payload"].S)
+	return v, err
+}
+
+// deleteVersions removes all versions of a cart
+func deleteVersions(cartKey string) error {
+	km := make(map[string]*dynamodb.AttributeValue)
+	km[":pk"] = &dynamodb.AttributeValue{
+		S: aws.String(versionPrefix + cartKey),
+	}
+
+	qo, err := dbs.Query(&dynamodb.QueryInput{
+		TableName:                 aws.String(os.Getenv("TABLE")),
+		KeyConditionExpression:    aws.String("PK = :pk"),
+		ExpressionAttributeValues: km,
+		ProjectionExpression:      aws.String("PK, SK"),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, item := range qo.Items {
+		_, err := dbs.DeleteItem(&dynamodb.DeleteItemInput{
+			TableName: aws.String(os.Getenv("TABLE")),
+			Key:       item,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ListCarts returns the default cart and the named carts of a user
+func (m manager) ListCarts(userID string) ([]datastore.CartInfo, error) {
+	carts := make([]datastore.CartInfo, 0)
+
+	// The default cart is stored under the userID
+	gio, err := dbs.GetItem(&dynamodb.GetItemInput{
+		TableName:            aws.String(os.Getenv("TABLE")),
+		Key:                  key(userID),
+		ProjectionExpression: aws.String("CartName"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	defaultCart := datastore.CartInfo{
+		ID:   datastore.DefaultCart,
+		Name: datastore.DefaultCart,
+	}
+	if gio.Item != nil && gio.Item["CartName"] != nil && gio.Item["CartName"].S != nil {
+		defaultCart.Name = *gio.Item["CartName"].S
+	}
+	carts = append(carts, defaultCart)
+
+	// Create a map of DynamoDB Attribute Values containing the table keys
+	// for the access pattern PK = CART SK begins with ID#
+	km := make(map[string]*dynamodb.AttributeValue)
+	km[":type"] = &dynamodb.AttributeValue{
+		S: aws.String("CART"),
+	}
+	km[":prefix"] = &dynamodb.AttributeValue{
+		S: aws.String(userID + "#"),
+	}
+
+	qi := &dynamodb.QueryInput{
+		TableName:                 aws.String(os.Getenv("TABLE")),
+		KeyConditionExpression:    aws.String("PK = :type AND begins_with(SK, :prefix)"),
+		ExpressionAttributeValues: km,
+		ProjectionExpression:      aws.String("SK, CartName"),
+	}
+
+	qo, err := dbs.Query(qi)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ct := range qo.Items {
+		_, cartID := datastore.SplitCartKey(*ct["SK"].S)
+		c := datastore.CartInfo{
+			ID:   cartID,
+			Name: cartID,
+		}
+		if ct["CartName"] != nil && ct["CartName"].S != nil {
+			c.Name = *ct["CartName"].S
+		}
+		carts = append(carts, c)
+	}
+
+	return carts, nil
+}
+
+// CreateCart creates an empty named cart for a user
+func (m manager) CreateCart(userID string, c datastore.CartInfo) error {
+	item := key(datastore.CartKey(userID, c.ID))
+	item["Payload"] = &dynamodb.AttributeValue{
+		S: aws.String("[]"),
+	}
+	item["CartName"] = &dynamodb.AttributeValue{
+		S: aws.String(c.Name),
+	}
+	item["Updated"] = &dynamodb.AttributeValue{
+		S: aws.String(time.Now().UTC().Format(time.RFC3339Nano)),
+	}
+
+	_, err := dbs.PutItem(&dynamodb.PutItemInput{
+		TableName:           aws.String(os.Getenv("TABLE")),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(SK)"),
+	})
+	if isConditionalCheckFailed(err) {
+		return fmt.Errorf("%w: user %s already has a cart with id %s", datastore.ErrCartExists, userID, c.ID)
+	}
+
+	return err
+}
+
+// RenameCart changes the name of a cart of a user
+func (m manager) RenameCart(userID string, cartID string, name string) error {
+	em := make(map[string]*dynamodb.AttributeValue)
+	em[":name"] = &dynamodb.AttributeValue{
+		S: aws.String(name),
+	}
+
+	uii := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(os.Getenv("TABLE")),
+		Key:                       key(datastore.CartKey(userID, cartID)),
+		ExpressionAttributeValues: em,
+		UpdateExpression:          aws.String("SET CartName = :name"),
+		ConditionExpression:       aws.String("attribute_exists(SK)"),
+	}
+
+	_, err := dbs.UpdateItem(uii)
+	if isConditionalCheckFailed(err) {
+		return fmt.Errorf("%w: user %s has no cart with id %s", datastore.ErrCartNotFound, userID, cartID)
+	}
+
+	return err
+}
+
+// DeleteCart removes a named cart of a user
+func (m manager) DeleteCart(userID string, cartID string) error {
+	if len(cartID) == 0 || cartID == datastore.DefaultCart {
+		return fmt.Errorf("the default cart can't be deleted")
+	}
+
+	_, err := dbs.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName:           aws.String(os.Getenv("TABLE")),
+		Key:                 key(datastore.CartKey(userID, cartID)),
+		ConditionExpression: aws.String("attribute_exists(SK)"),
+	})
+	if isConditionalCheckFailed(err) {
+		return fmt.Errorf("%w: user %s has no cart with id %s", datastore.ErrCartNotFound, userID, cartID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return deleteVersions(datastore.CartKey(userID, cartID))
+}
+
+// isConditionalCheckFailed returns true if the error is caused by a condition expression
+// that wasn't met
+func isConditionalCheckFailed(err error) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+}
+
+// isTransactionCanceled returns true if a transaction is canceled, which happens
+// when one of the condition expressions in the transaction didn't match
+func isTransactionCanceled(err error) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeTransactionCanceledException
+}
+
+// GetSavedItems returns the saved-for-later list of a user
+func (m manager) GetSavedItems(userID string) (datastore.CartItems, error) {
+	gi := &dynamodb.GetItemInput{
+		TableName:            aws.String(os.Getenv("TABLE")),
+		Key:                  key(userID),
+		ProjectionExpression: aws.String("Saved"),
+	}
+
+	gio, err := dbs.GetItem(gi)
+	if err != nil {
+		return nil, err
+	}
+
+	// Users that never saved an item have no saved-for-later list
+	if gio.Item == nil || gio.Item["Saved"] == nil || gio.Item["Saved"].S == nil {
+		return make(datastore.CartItems, 0), nil
+	}
+
+	return datastore.UnmarshalPayload(*gio.Item["Saved"].S)
+}
+
+// SaveForLater moves an item from a cart to the saved-for-later list of the user
+func (m manager) SaveForLater(key string, line datastore.CartItem, version int64) error {
+	return m.moveSaved(key, line, version, true)
+}
+
+// MoveToCart moves an item from the saved-for-later list of the user to a cart
+func (m manager) MoveToCart(key string, line datastore.CartItem, version int64) error {
+	return m.moveSaved(key, line, version, false)
+}
+
+// moveSaved moves a line between the cart with the cartKey and the saved-for-later list,
+// which is stored with the default cart of the user. When the cart is a named cart both
+// items are updated in a single transaction. Unless expected is anyVersion, the cart has to be
+// at that version.
+func (m manager) moveSaved(cartKey string, line datastore.CartItem, expected int64, save bool) error {
+	userID, _ := datastore.SplitCartKey(cartKey)
+
+	items, err := m.GetItems(cartKey)
+	if err != nil {
+		return err
+	}
+
+	saved, err := m.GetSavedItems(userID)
+	if err != nil {
+		return err
+	}
+
+	if save {
+		items, saved, err = datastore.MoveItem(items, saved, line)
+	} else {
+		saved, items, err = datastore.MoveItem(saved, items, line)
+	}
+	if err != nil {
+		return err
+	}
+
+	payload, err := items.Marshal()
+	if err != nil {
+		return err
+	}
+
+	savedPayload, err := saved.Marshal()
+	if err != nil {
+		return err
+	}
+
+	version, err := currentVersion(cartKey)
+	if err != nil {
+		return err
+	}
+
+	if expected != anyVersion && expected != version {
+		return fmt.Errorf("%w: cart %s is at version %d", datastore.ErrVersionMismatch, cartKey, version)
+	}
+
+	savedValue := &dynamodb.AttributeValue{
+		S: aws.String(string(savedPayload)),
+	}
+
+	u := &dynamodb.Update{
+		TableName:                 aws.String(os.Getenv("TABLE")),
+		Key:                       key(cartKey),
+		ExpressionAttributeValues: payloadValues(string(payload)),
+		UpdateExpression:          aws.String("SET Payload = :payload, Updated = :updated"),
+		ConditionExpression:       aws.String(unlocked),
+	}
+
+	// The saved-for-later list of the default cart is stored in the same item
+	if cartKey == userID {
+		u.ExpressionAttributeValues[":saved"] = savedValue
+		u.UpdateExpression = aws.String("SET Payload = :payload, Saved = :saved, Updated = :updated")
+	}
+
+	twi := &dynamodb.TransactWriteItemsInput{
+		TransactItems: versioned(cartKey, u, version, string(payload)),
+	}
+
+	if cartKey != userID {
+		twi.TransactItems = append(twi.TransactItems, &dynamodb.TransactWriteItem{
+			Update: &dynamodb.Update{
+				TableName: aws.String(os.Getenv("TABLE")),
+				Key:       key(userID),
+				ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+					":saved": savedValue,
+					":empty": {S: aws.String("[]")},
+				},
+				// The default cart is created with an empty payload when the user doesn't have one yet
+				UpdateExpression: aws.String("SET Saved = :saved, Payload = if_not_exists(Payload, :empty)"),
+			},
+		})
+	}
+
+	_, err = dbs.TransactWriteItems(twi)
+	if isTransactionCanceled(err) {
+		return changeError(cartKey)
+	}
+
+	return err
+}
+
+// Members returns the collaborators of a cart
+func (m manager) Members(cartKey string) ([]datastore.Member, error) {
+	gi := &dynamodb.GetItemInput{
+		TableName:            aws.String(os.Getenv("TABLE")),
+		Key:                  key(cartKey),
+		ProjectionExpression: aws.String("SK, Members"),
+	}
+
+	gio, err := dbs.GetItem(gi)
+	if err != nil {
+		return nil, err
+	}
+
+	if gio.Item == nil {
+		return nil, fmt.Errorf("%w: no cart found with key %s", datastore.ErrCartNotFound, cartKey)
+	}
+
+	members := make([]datastore.Member, 0)
+
+	// Carts that were never shared don't have any members
+	if gio.Item["Members"] == nil || gio.Item["Members"].S == nil {
+		return members, nil
+	}
+
+	if err := json.Unmarshal([]byte(*gio.Item["Members"].S), &members); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// SetMember adds a collaborator to a cart or changes their role
+func (m manager) SetMember(cartKey string, member datastore.Member) error {
+	members, err := m.Members(cartKey)
+	if err != nil {
+		return err
+	}
+
+	updated := false
+	for idx := range members {
+		if members[idx].UserID == member.UserID {
+			members[idx].Role = member.Role
+			updated = true
+		}
+	}
+	if !updated {
+		members = append(members, member)
+	}
+
+	return storeMembers(cartKey, members)
+}
+
+// RemoveMember removes a collaborator from a cart
+func (m manager) RemoveMember(cartKey string, userID string) error {
+	members, err := m.Members(cartKey)
+	if err != nil {
+		return err
+	}
+
+	remaining := make([]datastore.Member, 0, len(members))
+	for _, member := range members {
+		if member.UserID != userID {
+			remaining = append(remaining, member)
+		}
+	}
+
+	return storeMembers(cartKey, remaining)
+}
+
+// storeMembers saves the collaborators of an existing cart
+func storeMembers(cartKey string, members []datastore.Member) error {
+	payload, err := json.Marshal(members)
+	if err != nil {
+		return err
+	}
+
+	em := make(map[string]*dynamodb.AttributeValue)
+	em[":members"] = &dynamodb.AttributeValue{
+		S: aws.String(string(payload)),
+	}
+
+	uii := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(os.Getenv("TABLE")),
+		Key:                       key(cartKey),
+		ExpressionAttributeValues: em,
+		UpdateExpression:          aws.String("SET Members = :members"),
+		ConditionExpression:       aws.String("attribute_exists(SK)"),
+	}
+
+	_, err = dbs.UpdateItem(uii)
+	if isConditionalCheckFailed(err) {
+		return fmt.Errorf("%w: no cart found with key %s", datastore.ErrCartNotFound, cartKey)
+	}
+
+	return err
+}
+
+// LockCart locks a cart so the items can't be changed
+func (m manager) LockCart(cartKey string, lockID string, d time.Duration) error {
+	em := make(map[string]*dynamodb.AttributeValue)
+	em[":lock"] = &dynamodb.AttributeValue{
+		S: aws.String(lockID),
+	}
+	em[":until"] = &dynamodb.AttributeValue{
+		N: aws.String(millis(time.Now().Add(d))),
+	}
+	em[":now"] = &dynamodb.AttributeValue{
+		N: aws.String(millis(time.Now())),
+	}
+
+	uii := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(os.Getenv("TABLE")),
+		Key:                       key(cartKey),
+		ExpressionAttributeValues: em,
+		UpdateExpression:          aws.String("SET LockID = :lock, LockedUntil = :until"),
+		ConditionExpression:       aws.String("attribute_exists(SK) AND (" + unlocked + ")"),
+	}
+
+	_, err := dbs.UpdateItem(uii)
+	if isConditionalCheckFailed(err) {
+		if _, err := m.GetItems(cartKey); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: cart %s is already locked", datastore.ErrCartLocked, cartKey)
+	}
+
+	return err
+}
+
+// UnlockCart removes the lock from a cart
+func (m manager) UnlockCart(cartKey string, lockID string) error {
+	em := make(map[string]*dynamodb.AttributeValue)
+	em[":lock"] = &dynamodb.AttributeValue{
+		S: aws.String(lockID),
+	}
+
+	uii := &dynamodb.UpdateItemInput{
+		TableName:                 aws.String(os.Getenv("TABLE")),
+		Key:                       key(cartKey),
+		ExpressionAttributeValues: em,
+		UpdateExpression:          aws.String("REMOVE LockID, LockedUntil"),
+		ConditionExpression:       aws.String("LockID = :lock"),
+	}
+
+	// When the condition fails, the lock has expired and the cart may have been locked again
+	_, err := dbs.UpdateItem(uii)
+	if isConditionalCheckFailed(err) {
+		return nil
+	}
+
+	return err
+}
+
+// CheckoutCart empties a locked cart and optionally archives the items in the cart
+func (m manager) CheckoutCart(cartKey string, lockID string, archive bool) error {
+	items, err := m.GetItems(cartKey)
+	if err != nil {
+		return err
+	}
+
+	payload, err := items.Marshal()
+	if err != nil {
+		return err
+	}
+
+	version, err := currentVersion(cartKey)
+	if err != nil {
+		return err
+	}
+
+	em := payloadValues("[]")
+	em[":lock"] = &dynamodb.AttributeValue{
+		S: aws.String(lockID),
+	}
+
+	// The cart is only checked out while the lock is held. Once the lock has expired, the
+	// items may have changed since they were sent with the CartCheckedOut event.
+	u := &dynamodb.Update{
+		TableName:                 aws.String(os.Getenv("TABLE")),
+		Key:                       key(cartKey),
+		ExpressionAttributeValues: em,
+		UpdateExpression:          aws.String("SET Payload = :payload, Updated = :updated REMOVE LockID, LockedUntil"),
+		ConditionExpression:       aws.String("LockID = :lock AND LockedUntil >= :now"),
+	}
+
+	twi := &dynamodb.TransactWriteItemsInput{
+		TransactItems: versioned(cartKey, u, version, "[]"),
+	}
+
+	// Archived carts are stored for the access pattern PK = CART_ARCHIVE SK = KEY#LOCKID
+	if archive {
+		twi.TransactItems = append(twi.TransactItems, &dynamodb.TransactWriteItem{
+			Put: &dynamodb.Put{
+				TableName: aws.String(os.Getenv("TABLE")),
+				Item: map[string]*dynamodb.AttributeValue{
+					"PK":      {S: aws.String("CART_ARCHIVE")},
+					"SK":      {S: aws.String(cartKey + "#" + lockID)},
+					"Payload": {S: aws.String(string(payload))},
+					"Updated": em[":updated"],
+				},
+			},
+		})
+	}
+
+	_, err = dbs.TransactWriteItems(twi)
+	if isTransactionCanceled(err) {
+		return fmt.Errorf("%w: the lock on cart %s has expired", datastore.ErrCartLocked, cartKey)
+	}
+
+	return err
+}
+
+// idempotencyPrefix is the prefix of the PK of requests made with an idempotency key, which
+// are stored for the access pattern PK = IDEMPOTENCY#KEY SK = REQUEST
+const idempotencyPrefix = "IDEMPOTENCY#"
+
+// idempotencyKey returns the table keys of a request made with an idempotency key
+func idempotencyKey(key string) map[string]*dynamodb.AttributeValue {
+	km := make(map[string]*dynamodb.AttributeValue)
+	km["PK"] = &dynamodb.AttributeValue{
+		S: aws.String(idempotencyPrefix + key),
+	}
+	km["SK"] = &dynamodb.AttributeValue{
+		S: aws.String("REQUEST"),
+	}
+	return km
+}
+
+// ClaimIdempotencyKey stores a request made with an idempotency key, unless the key is in use
+func (m manager) ClaimIdempotencyKey(r datastore.IdempotentRequest) (datastore.IdempotentRequest, error) {
+	item := idempotencyKey(r.Key)
+	item["Fingerprint"] = &dynamodb.AttributeValue{
+		S: aws.String(r.Fingerprint),
+	}
+	item["Expires"] = &dynamodb.AttributeValue{
+		N: aws.String(millis(r.Expires)),
+	}
+	item["Done"] = &dynamodb.AttributeValue{
+		BOOL: aws.Bool(false),
+	}
+
+	// The TTL attribute is in seconds, so expired requests can be removed using the Time to Live
+	// of the table. Removing them can take a while, so the claim still checks when they expire.
+	item["TTL"] = &dynamodb.AttributeValue{
+		N: aws.String(strconv.FormatInt(r.Expires.Unix()+1, 10)),
+	}
+
+	em := make(map[string]*dynamodb.AttributeValue)
+	em[":now"] = &dynamodb.AttributeValue{
+		N: aws.String(millis(time.Now())),
+	}
+
+	// Requests that have expired are overwritten
+	_, err := dbs.PutItem(&dynamodb.PutItemInput{
+		TableName:                 aws.String(os.Getenv("TABLE")),
+		Item:                      item,
+		ExpressionAttributeValues: em,
+		ConditionExpression:       aws.String("attribute_not_exists(PK) OR Expires < :now"),
+	})
+	if !isConditionalCheckFailed(err) {
+		return r, err
+	}
+
+	gio, err := dbs.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(os.Getenv("TABLE")),
+		Key:            idempotencyKey(r.Key),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return r, err
+	}
+
+	// The request was released in the meantime, so it is still being made
+	if gio.Item == nil {
+		return r, fmt.Errorf("%w: %s", datastore.ErrIdempotencyKeyUsed, r.Key)
+	}
+
+	stored, err := unmarshalIdempotentRequest(r.Key, gio.Item)
+	if err != nil {
+		return r, err
+	}
+
+	return stored, fmt.Errorf("%w: %s", datastore.ErrIdempotencyKeyUsed, r.Key)
+}
+
+// CompleteIdempotencyKey stores the response of a request made with an idempotency key
+func (m manager) CompleteIdempotencyKey(r datastore.IdempotentRequest) error {
+	em := make(map[string]*dynamodb.AttributeValue)
+	em[":done"] = &dynamodb.AttributeValue{
+		BOOL: aws.Bool(true),
+	}
+	em[":status"] = &dynamodb.AttributeValue{
+		N: aws.String(strconv.Itoa(r.StatusCode)),
+	}
+	em[":type"] = &dynamodb.AttributeValue{
+		S: aws.String(r.ContentType),
+	}
+	em[":body"] = &dynamodb.AttributeValue{
+		S: aws.String(r.Body),
+	}
+
+	_, err := dbs.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 aws.String(os.Getenv("TABLE")),
+		Key:                       idempotencyKey(r.Key),
+		ExpressionAttributeValues: em,
+		UpdateExpression:          aws.String("SET Done = :done, StatusCode = :status, ContentType = :type, ResponseBody = :body"),
+	})
+
+	return err
+}
+
+// ReleaseIdempotencyKey removes a request made with an idempotency key
+func (m manager) ReleaseIdempotencyKey(key string) error {
+	_, err := dbs.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(os.Getenv("TABLE")),
+		Key:       idempotencyKey(key),
+	})
+
+	return err
+}
+
+// unmarshalIdempotentRequest creates an IdempotentRequest from a stored request
+func unmarshalIdempotentRequest(key string, item map[string]*dynamodb.AttributeValue) (datastore.IdempotentRequest, error) {
+	r := datastore.IdempotentRequest{
+		Key: key,
+	}
+
+	if v := item["Fingerprint"]; v != nil && v.S != nil {
+		r.Fingerprint = *v.S
+	}
+	if v := item["Expires"]; v != nil && v.N != nil {
+		ms, err := strconv.ParseInt(*v.N, 10, 64)
+		if err != nil {
+			return r, err
+		}
+		r.Expires = time.Unix(0, ms*int64(time.Millisecond)).UTC()
+	}
+	if v := item["Done"]; v != nil && v.BOOL != nil {
+		r.Done = *v.BOOL
+	}
+	if v := item["StatusCode"]; v != nil && v.N != nil {
+		status, err := strconv.Atoi(*v.N)
+		if err != nil {
+			return r, err
+		}
+		r.StatusCode = status
+	}
+	if v := item["ContentType"]; v != nil && v.S != nil {
+		r.ContentType = *v.S
+	}
+	if v := item["ResponseBody"]; v != nil && v.S != nil {
+		r.Body = *v.S
+	}
+
+	return r, nil
 }
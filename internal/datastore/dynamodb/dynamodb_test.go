@@ -0,0 +1,327 @@
+package dynamodb
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+)
+
+// table is an in-memory DynamoDB table with the operations the manager uses to store carts.
+// Condition expressions are not evaluated, since the tests don't run writes concurrently.
+type table struct {
+	dynamodbiface.DynamoDBAPI
+	items map[string]map[string]*dynamodb.AttributeValue
+
+	// pageSize is the number of items a query returns at most, or 0 to return all of them
+	pageSize int
+}
+
+// useTable replaces the connection to DynamoDB with an empty in-memory table
+func useTable(t *testing.T) *table {
+	tbl := &table{
+		items: make(map[string]map[string]*dynamodb.AttributeValue),
+	}
+
+	prev := dbs
+	dbs = tbl
+	t.Cleanup(func() {
+		dbs = prev
+	})
+
+	return tbl
+}
+
+func tableKey(km map[string]*dynamodb.AttributeValue) string {
+	return *km["PK"].S + "|" + *km["SK"].S
+}
+
+func (tbl *table) GetItem(in *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: tbl.items[tableKey(in.Key)]}, nil
+}
+
+func (tbl *table) Query(in *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	// Only the key conditions PK = :pk and PK = :pk AND SK = :sk are supported
+	conditions := strings.Split(*in.KeyConditionExpression, " AND ")
+	pk := *in.ExpressionAttributeValues[strings.TrimPrefix(conditions[0], "PK = ")].S
+	sk := ""
+	if len(conditions) > 1 {
+		sk = *in.ExpressionAttributeValues[strings.TrimPrefix(conditions[1], "SK = ")].S
+	}
+
+	keys := make([]string, 0)
+	for k, item := range tbl.items {
+		if *item["PK"].S != pk || (len(sk) > 0 && *item["SK"].S != sk) {
+			continue
+		}
+		if in.ExclusiveStartKey != nil && k <= tableKey(in.ExclusiveStartKey) {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := &dynamodb.QueryOutput{}
+	for _, k := range keys {
+		if tbl.pageSize > 0 && len(out.Items) == tbl.pageSize {
+			last := out.Items[len(out.Items)-1]
+			out.LastEvaluatedKey = map[string]*dynamodb.AttributeValue{"PK": last["PK"], "SK": last["SK"]}
+			break
+		}
+		out.Items = append(out.Items, tbl.items[k])
+	}
+	return out, nil
+}
+
+func (tbl *table) TransactWriteItems(in *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+	for _, w := range in.TransactItems {
+		switch {
+		case w.Put != nil:
+			tbl.items[tableKey(w.Put.Item)] = w.Put.Item
+		case w.Delete != nil:
+			delete(tbl.items, tableKey(w.Delete.Key))
+		case w.Update != nil:
+			if err := tbl.update(w.Update.Key, *w.Update.UpdateExpression, w.Update.ExpressionAttributeValues); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+// update applies an update expression of the form SET A = :a, B = if_not_exists(B, :b)
+func (tbl *table) update(km map[string]*dynamodb.AttributeValue, expr string, values map[string]*dynamodb.AttributeValue) error {
+	item, ok := tbl.items[tableKey(km)]
+	if !ok {
+		item = map[string]*dynamodb.AttributeValue{"PK": km["PK"], "SK": km["SK"]}
+		tbl.items[tableKey(km)] = item
+	}
+
+	if !strings.HasPrefix(expr, "SET ") {
+		return errors.New("unsupported update expression " + expr)
+	}
+
+	for _, set := range splitSets(strings.TrimPrefix(expr, "SET ")) {
+		parts := strings.SplitN(set, " = ", 2)
+		name, value := parts[0], parts[1]
+
+		if strings.HasPrefix(value, "if_not_exists(") {
+			if _, ok := item[name]; ok {
+				continue
+			}
+			value = strings.TrimSuffix(strings.TrimPrefix(value, "if_not_exists("+name+", "), ")")
+		}
+
+		if values[value] == nil {
+			return errors.New("unsupported update expression " + expr)
+		}
+		item[name] = values[value]
+	}
+
+	return nil
+}
+
+// splitSets splits the actions of a SET expression, without splitting the arguments of functions
+func splitSets(expr string) []string {
+	sets := make([]string, 0)
+	depth, start := 0, 0
+	for i, c := range expr {
+		switch {
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			sets = append(sets, strings.TrimSpace(expr[start:i]))
+			start = i + 1
+		}
+	}
+	return append(sets, strings.TrimSpace(expr[start:]))
+}
+
+// shirt returns a line with the shirt in a color
+func shirt(quantity int64, color string) datastore.CartItem {
+	return datastore.CartItem{
+		CartItem: acmeserverless.CartItem{
+			ItemID:   aws.String("shirt"),
+			Quantity: quantity,
+		},
+		Options: map[string]string{"color": color},
+	}
+}
+
+func TestSaveForLaterFromNamedCart(t *testing.T) {
+	tbl := useTable(t)
+	m := New()
+
+	red := shirt(1, "red")
+	blue := shirt(2, "blue")
+
+	key := datastore.CartKey("dan", "gym")
+	if err := m.StoreItems(key, datastore.CartItems{red, blue}); err != nil {
+		t.Fatalf("error storing items: %s", err.Error())
+	}
+
+	// Only the line with the same options is saved
+	if err := m.SaveForLater(key, shirt(0, "blue"), datastore.AnyVersion); err != nil {
+		t.Fatalf("error saving item for later: %s", err.Error())
+	}
+
+	items, err := m.GetItems(key)
+	if err != nil {
+		t.Fatalf("error getting items: %s", err.Error())
+	}
+	if len(items) != 1 || !items[0].SameLine(red) {
+		t.Errorf("expected only the red shirt in the cart, got %+v", items)
+	}
+
+	saved, err := m.GetSavedItems("dan")
+	if err != nil {
+		t.Fatalf("error getting saved items: %s", err.Error())
+	}
+	if len(saved) != 1 || !saved[0].SameLine(blue) || saved[0].Quantity != 2 {
+		t.Errorf("expected the blue shirts on the saved-for-later list, got %+v", saved)
+	}
+
+	// The default cart that holds the saved-for-later list is an empty cart
+	items, err = m.GetItems("dan")
+	if err != nil {
+		t.Fatalf("error getting items of the default cart: %s", err.Error())
+	}
+	if len(items) != 0 {
+		t.Errorf("expected an empty default cart, got %+v", items)
+	}
+
+	// Records stored before the default cart got a payload are skipped
+	tbl.items["CART|erin"] = map[string]*dynamodb.AttributeValue{
+		"PK":    {S: aws.String("CART")},
+		"SK":    {S: aws.String("erin")},
+		"Saved": {S: aws.String(`[{"itemid":"shirt","quantity":1}]`)},
+	}
+
+	carts, err := m.AllCarts()
+	if err != nil {
+		t.Fatalf("error getting all carts: %s", err.Error())
+	}
+
+	users := make([]string, 0, len(carts))
+	for _, c := range carts {
+		users = append(users, c.UserID)
+	}
+	if strings.Join(users, ",") != "dan,dan#gym" {
+		t.Errorf("expected the carts dan and dan#gym, got %v", users)
+	}
+}
+
+func TestAllCartsReadsAllPages(t *testing.T) {
+	tbl := useTable(t)
+	tbl.pageSize = 2
+	m := New()
+
+	for _, userID := range []string{"dan", "erin", "frank", "grace", "heidi"} {
+		if err := m.StoreItems(userID, datastore.CartItems{shirt(1, "red")}); err != nil {
+			t.Fatalf("error storing items: %s", err.Error())
+		}
+	}
+
+	carts, err := m.AllCarts()
+	if err != nil {
+		t.Fatalf("error getting all carts: %s", err.Error())
+	}
+	if len(carts) != 5 {
+		t.Errorf("expected 5 carts, got %d", len(carts))
+	}
+}
+
+func TestMoveToCartUnknownLine(t *testing.T) {
+	useTable(t)
+	m := New()
+
+	red := shirt(1, "red")
+	if err := m.StoreItems("dan", datastore.CartItems{red}); err != nil {
+		t.Fatalf("error storing items: %s", err.Error())
+	}
+	if err := m.SaveForLater("dan", red, datastore.AnyVersion); err != nil {
+		t.Fatalf("error saving item for later: %s", err.Error())
+	}
+
+	err := m.MoveToCart("dan", shirt(0, "blue"), datastore.AnyVersion)
+	if !errors.Is(err, datastore.ErrItemNotFound) {
+		t.Errorf("expected ErrItemNotFound for a variant that isn't saved, got %v", err)
+	}
+}
+
+func TestChangesAtOldVersionFail(t *testing.T) {
+	useTable(t)
+	m := New()
+
+	// The caller reads the cart at version 1, after which someone else changes it
+	if err := m.StoreItems("dan", datastore.CartItems{shirt(1, "red")}); err != nil {
+		t.Fatalf("error storing items: %s", err.Error())
+	}
+	if err := m.StoreItems("dan", datastore.CartItems{shirt(1, "red"), shirt(1, "blue")}); err != nil {
+		t.Fatalf("error storing items: %s", err.Error())
+	}
+
+	err := m.StoreItemsIfVersion("dan", datastore.CartItems{shirt(2, "red")}, 1)
+	if !errors.Is(err, datastore.ErrVersionMismatch) {
+		t.Errorf("expected ErrVersionMismatch for storing items, got %v", err)
+	}
+
+	err = m.SaveForLater("dan", shirt(0, "red"), 1)
+	if !errors.Is(err, datastore.ErrVersionMismatch) {
+		t.Errorf("expected ErrVersionMismatch for saving an item, got %v", err)
+	}
+
+	if err := m.SaveForLater("dan", shirt(0, "red"), 2); err != nil {
+		t.Errorf("error saving an item at the current version: %s", err.Error())
+	}
+}
+
+func TestEmptyPayloads(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload *string
+		items   int
+		err     error
+	}{
+		{"missing payload", nil, 0, datastore.ErrCartNotFound},
+		{"empty payload", aws.String(""), 0, nil},
+		{"empty array", aws.String("[]"), 0, nil},
+		{"one item", aws.String(`[{"itemid":"sku-1","quantity":1}]`), 1, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tbl := useTable(t)
+			m := New()
+
+			item := key("dan")
+			item["Payload"] = &dynamodb.AttributeValue{S: tt.payload}
+			item["Saved"] = &dynamodb.AttributeValue{S: tt.payload}
+			tbl.items[tableKey(item)] = item
+
+			items, err := m.GetItems("dan")
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+			if err == nil && (items == nil || len(items) != tt.items) {
+				t.Errorf("expected %d items, got %v", tt.items, items)
+			}
+
+			saved, err := m.GetSavedItems("dan")
+			if err != nil {
+				t.Fatalf("error getting saved items: %s", err.Error())
+			}
+			if saved == nil || len(saved) != tt.items {
+				t.Errorf("expected %d saved items, got %v", tt.items, saved)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package datastore
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+)
+
+// Carts is a slice of Cart objects
+type Carts []Cart
+
+// Marshal returns the JSON encoding of Carts
+func (r *Carts) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// Cart represents a shoppingcart for a user of the ACME Serverless Fitness Shop
+type Cart struct {
+	// Items is a slice of Item objects, each being a single object in the cart of the user
+	Items CartItems `json:"cart"`
+
+	// UserID is the unique identifier of the user in the ACME Serverless Fitness Shop
+	UserID string `json:"userid"`
+}
+
+// Marshal returns the JSON encoding of Cart
+func (r *Cart) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// UnmarshalCart parses the JSON-encoded data and stores the result in a Cart
+func UnmarshalCart(data string) (Cart, error) {
+	var r Cart
+	err := json.Unmarshal([]byte(data), &r)
+	return r, err
+}
+
+// UnmarshalPayload parses the payload a list of items is stored with. Carts that have been
+// cleared, and lists that were never filled, are stored with an empty payload or an empty
+// JSON array, and have no items.
+func UnmarshalPayload(payload string) (CartItems, error) {
+	switch strings.TrimSpace(payload) {
+	case "", "[]", "null":
+		return make(CartItems, 0), nil
+	}
+	return UnmarshalItems(payload)
+}
+
+// CartItems is a slice of CartItem objects
+type CartItems []CartItem
+
+// Marshal returns the JSON encoding of CartItems
+func (r *CartItems) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// UnmarshalItems parses the JSON-encoded data and stores the result in a CartItems object
+func UnmarshalItems(data string) (CartItems, error) {
+	var r CartItems
+	err := json.Unmarshal([]byte(data), &r)
+	return r, err
+}
+
+// CartItem is a single line in a cart. It extends the CartItem of the ACME Serverless
+// Fitness Shop with the fields the cart service keeps for each line, and encodes to the
+// same JSON with the extra fields added.
+type CartItem struct {
+	acmeserverless.CartItem
+
+	// AddedBy is the user that added the item to the cart
+	AddedBy string `json:"addedby,omitempty"`
+
+	// Options are the attributes of the variant of the item, like its size or color
+	Options map[string]string `json:"options,omitempty"`
+
+	// Children are the components of a bundle. The quantity of a component is the
+	// quantity in a single bundle, so it follows the quantity of the bundle.
+	Children CartItems `json:"children,omitempty"`
+}
+
+// LineID returns the identity of the line, which is the itemid together with the
+// normalized options. Lines for different variants of the same item have a different
+// identity, so they can be changed separately.
+func (r CartItem) LineID() string {
+	id := ""
+	if r.ItemID != nil {
+		id = *r.ItemID
+	}
+
+	options := NormalizeOptions(r.Options)
+	if len(options) == 0 {
+		return id
+	}
+
+	values := make(url.Values, len(options))
+	for k, v := range options {
+		values.Set(k, v)
+	}
+
+	// Encode sorts the options by name
+	return id + "?" + values.Encode()
+}
+
+// SameLine returns true when both lines have an itemid and the same identity
+func (r CartItem) SameLine(o CartItem) bool {
+	return r.ItemID != nil && o.ItemID != nil && r.LineID() == o.LineID()
+}
+
+// NormalizeOptions returns the options with names and values trimmed and in lower
+// case, leaving out options without a value, so options that only differ in how they
+// are written are the same.
+func NormalizeOptions(options map[string]string) map[string]string {
+	res := make(map[string]string, len(options))
+	for k, v := range options {
+		k = strings.ToLower(strings.TrimSpace(k))
+		v = strings.ToLower(strings.TrimSpace(v))
+		if len(k) == 0 || len(v) == 0 {
+			continue
+		}
+		res[k] = v
+	}
+	return res
+}
+
+// Marshal returns the JSON encoding of CartItem
+func (r *CartItem) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// UnmarshalItem parses the JSON-encoded data and stores the result in a CartItem
+func UnmarshalItem(data []byte) (CartItem, error) {
+	var r CartItem
+	err := json.Unmarshal(data, &r)
+	return r, err
+}
@@ -4,13 +4,16 @@ package mongodb
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
-	acmeserverless "github.com/retgits/acme-serverless"
 	"github.com/retgits/acme-serverless-cart/internal/datastore"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -22,12 +25,15 @@ import (
 // container stays warm.
 var dbs *mongo.Collection
 
+// connected makes sure the connection to MongoDB is only created once
+var connected sync.Once
+
 // manager is an empty struct that implements the methods of the
 // Manager interface.
 type manager struct{}
 
-// init creates the connection to MongoDB.
-func init() {
+// connect creates the connection to MongoDB.
+func connect() {
 	username := os.Getenv("MONGO_USERNAME")
 	password := os.Getenv("MONGO_PASSWORD")
 	hostname := os.Getenv("MONGO_HOSTNAME")
@@ -37,41 +43,64 @@ func init() {
 	if strings.HasSuffix(connString, ":") {
 		connString = connString[:len(connString)-1]
 	}
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 	client, err := mongo.Connect(ctx, options.Client().ApplyURI(connString))
 	if err != nil {
 		log.Fatalf("error connecting to MongoDB: %s", err.Error())
 	}
 	dbs = client.Database("acmeserverless").Collection("cart")
+
+	// Requests made with an idempotency key are removed by MongoDB once they expire. Claims
+	// still check when they expire, since removing them can take a while.
+	_, err = idempotency().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "Expires", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		log.Printf("error creating index to expire idempotency keys: %s", err.Error())
+	}
 }
 
-// New creates a new datastore manager using Amazon DynamoDB as backend
+// New creates a new datastore manager using MongoDB as backend. The connection to
+// MongoDB is created when the first manager is created.
 func New() datastore.Manager {
+	connected.Do(connect)
 	return manager{}
 }
 
 // GetItems retrieves all items for a single user from DynamoDB based on the userID
-func (m manager) GetItems(userID string) (acmeserverless.CartItems, error) {
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
+func (m manager) GetItems(userID string) (datastore.CartItems, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return getItems(ctx, userID)
+}
 
-	res := dbs.FindOne(ctx, bson.D{{"SK", userID}})
+// getItems retrieves the items in the cart of a user
+func getItems(ctx context.Context, userID string) (datastore.CartItems, error) {
+	res := dbs.FindOne(ctx, filter(userID))
 
 	raw, err := res.DecodeBytes()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("%w: no items found for user with id %s", datastore.ErrCartNotFound, userID)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("unable to decode bytes: %s", err.Error())
 	}
 
-	payload := raw.Lookup("Payload").StringValue()
-
-	if len(payload) < 5 {
-		return make(acmeserverless.CartItems, 0), nil
+	// Carts that have been cleared contain an empty payload, and the saved-for-later list of
+	// users without a default cart is stored without one
+	payload, ok := raw.Lookup("Payload").StringValueOK()
+	if !ok {
+		return make(datastore.CartItems, 0), nil
 	}
 
-	return acmeserverless.UnmarshalItems(raw.Lookup("Payload").StringValue())
+	return datastore.UnmarshalPayload(payload)
 }
 
 // AddItem adds a new item for the user to the cart
-func (m manager) AddItem(userID string, i acmeserverless.CartItem) error {
+func (m manager) AddItem(userID string, i datastore.CartItem) error {
 	items, err := m.GetItems(userID)
 	if err != nil {
 		return err
@@ -83,36 +112,45 @@ func (m manager) AddItem(userID string, i acmeserverless.CartItem) error {
 		return err
 	}
 
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-	_, err = dbs.UpdateOne(ctx, bson.D{{"SK", userID}}, bson.D{{"$set", bson.D{{"Payload", string(cc)}}}})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	return err
+	return withTransaction(ctx, func(sc context.Context) error {
+		return storePayload(sc, userID, string(cc), anyVersion)
+	})
 }
 
 // AllCarts retrieves all carts from DynamoDB
-func (m manager) AllCarts() (acmeserverless.Carts, error) {
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
+func (m manager) AllCarts() (datastore.Carts, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 	cursor, err := dbs.Find(ctx, bson.D{})
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	var results []bson.M
 
 	if err = cursor.All(ctx, &results); err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	carts := make(acmeserverless.Carts, 0)
+	carts := make(datastore.Carts, 0)
 
 	for _, result := range results {
-		cartContent, err := acmeserverless.UnmarshalItems(result["Payload"].(string))
+		// Skip documents without a payload, like the saved-for-later list of users without a default cart
+		payload, ok := result["Payload"].(string)
+		if !ok {
+			continue
+		}
+
+		cartContent, err := datastore.UnmarshalPayload(payload)
 		if err != nil {
 			log.Println(fmt.Sprintf("error unmarshalling cart data: %s", err.Error()))
 			continue
 		}
 
-		carts = append(carts, acmeserverless.Cart{
+		carts = append(carts, datastore.Cart{
 			Items:  cartContent,
 			UserID: result["SK"].(string),
 		})
@@ -123,39 +161,43 @@ func (m manager) AllCarts() (acmeserverless.Carts, error) {
 
 // ClearCart sets the cart for a user to an empty JSON string
 func (m manager) ClearCart(userID string) error {
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-	_, err := dbs.UpdateOne(ctx, bson.D{{"SK", userID}}, bson.D{{"$set", bson.D{{"Payload", ""}}}})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	return err
+	return withTransaction(ctx, func(sc context.Context) error {
+		return storePayload(sc, userID, "", anyVersion)
+	})
 }
 
 // StoreItems saves the cart items from a single user into Amazon DynamoDB
-func (m manager) StoreItems(userID string, i acmeserverless.CartItems) error {
+func (m manager) StoreItems(userID string, i datastore.CartItems) error {
 	payload, err := i.Marshal()
 	if err != nil {
 		return err
 	}
 
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-	_, err = dbs.UpdateOne(ctx, bson.D{{"SK", userID}}, bson.D{{"$set", bson.D{{"Payload", string(payload)}}}})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	return err
+	return withTransaction(ctx, func(sc context.Context) error {
+		return storePayload(sc, userID, string(payload), anyVersion)
+	})
 }
 
-// ItemsInCart gets the number of items in a cart for the user
+// ItemsInCart gets the number of items in a cart for the user, counting bundles
+// according to ITEM_COUNT_POLICY
 func (m manager) ItemsInCart(userID string) (int64, error) {
 	items, err := m.GetItems(userID)
 	if err != nil {
 		return 0, err
 	}
 
-	numItems := int64(0)
-
-	for _, ci := range items {
-		numItems = numItems + ci.Quantity
+	policy, err := datastore.ParseItemCountPolicy(os.Getenv("ITEM_COUNT_POLICY"))
+	if err != nil {
+		return 0, err
 	}
 
-	return numItems, nil
+	return datastore.CountItems(items, policy), nil
 }
 
 // ValueInCart gets the value of the items in a cart for the user
@@ -173,3 +215,790 @@ func (m manager) ValueInCart(userID string) (float64, error) {
 
 	return value, nil
 }
+
+// MoveCart stores the items as the cart of toID and removes the cart of fromID
+func (m manager) MoveCart(fromID string, toID string, i datastore.CartItems) error {
+	payload, err := i.Marshal()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return withTransaction(ctx, func(sc context.Context) error {
+		if err := storePayload(sc, toID, string(payload), anyVersion); err != nil {
+			return err
+		}
+		if _, err := dbs.DeleteOne(sc, filter(fromID)); err != nil {
+			return err
+		}
+		_, err := versions().DeleteMany(sc, filter(fromID))
+		return err
+	})
+}
+
+// UpdatedAt returns when the cart of the user was last changed
+func (m manager) UpdatedAt(userID string) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	raw, err := dbs.FindOne(ctx, filter(userID)).DecodeBytes()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return time.Time{}, fmt.Errorf("%w: no cart found for user with id %s", datastore.ErrCartNotFound, userID)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to decode bytes: %s", err.Error())
+	}
+
+	updated, ok := raw.Lookup("Updated").TimeOK()
+	if !ok {
+		return time.Time{}, nil
+	}
+
+	return updated, nil
+}
+
+// RemindedAt returns when a reminder about the cart was last sent
+func (m manager) RemindedAt(key string) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	raw, err := dbs.FindOne(ctx, filter(key)).DecodeBytes()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return time.Time{}, fmt.Errorf("%w: no cart found with key %s", datastore.ErrCartNotFound, key)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to decode bytes: %s", err.Error())
+	}
+
+	reminded, ok := raw.Lookup("Reminded").TimeOK()
+	if !ok {
+		return time.Time{}, nil
+	}
+
+	return reminded, nil
+}
+
+// SetRemindedAt records that a reminder about the cart was sent
+func (m manager) SetRemindedAt(key string, t time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "Reminded", Value: t.UTC()}}}}
+
+	res, err := dbs.UpdateOne(ctx, filter(key), update)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%w: no cart found with key %s", datastore.ErrCartNotFound, key)
+	}
+
+	return nil
+}
+
+// filter returns the filter to select the cart of a user
+func filter(userID string) bson.D {
+	return bson.D{{Key: "SK", Value: userID}}
+}
+
+// withTransaction runs fn in a transaction. MongoDB only supports transactions on
+// replica sets, so on a standalone server fn is run without one.
+func withTransaction(ctx context.Context, fn func(context.Context) error) error {
+	session, err := dbs.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+
+	// The IllegalOperation error is returned when transactions aren't supported
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.Code == 20 {
+		err = fn(ctx)
+	}
+
+	return err
+}
+
+// unlockedFilter returns the filter to select the cart of a user when the cart isn't locked
+func unlockedFilter(userID string) bson.D {
+	return bson.D{
+		{Key: "SK", Value: userID},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "LockedUntil", Value: bson.D{{Key: "$exists", Value: false}}}},
+			bson.D{{Key: "LockedUntil", Value: bson.D{{Key: "$lt", Value: time.Now().UTC()}}}},
+		}},
+	}
+}
+
+// anyVersion is passed to storePayload to store the payload regardless of the version of the cart
+const anyVersion = datastore.AnyVersion
+
+// storePayload saves the payload as a new version of the cart of a user, creating the cart if it
+// doesn't exist yet. The fields are set together with the payload. Unless expected is anyVersion,
+// the cart has to be at that version. ErrCartLocked is returned when the cart is locked.
+func storePayload(ctx context.Context, userID string, payload string, expected int64, fields ...bson.E) error {
+	set := bson.D{
+		{Key: "Payload", Value: payload},
+		{Key: "Updated", Value: time.Now().UTC()},
+	}
+	update := bson.D{
+		{Key: "$set", Value: append(set, fields...)},
+		{Key: "$inc", Value: bson.D{{Key: "Version", Value: int64(1)}}},
+	}
+
+	f := unlockedFilter(userID)
+	if expected != anyVersion {
+		f = append(f, versionFilter(expected))
+	}
+
+	version, err := updateVersion(ctx, f, update, false)
+	if err == nil {
+		return recordVersion(ctx, userID, version, payload)
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+
+	// An upsert with the unlocked filter would create a second cart when the cart is locked
+	raw, err := dbs.FindOne(ctx, filter(userID)).DecodeBytes()
+	if err == nil {
+		if until, ok := raw.Lookup("LockedUntil").TimeOK(); ok && until.After(time.Now()) {
+			return fmt.Errorf("%w: cart %s can't be changed", datastore.ErrCartLocked, userID)
+		}
+		return fmt.Errorf("%w: cart %s isn't at version %d", datastore.ErrVersionMismatch, userID, expected)
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+
+	if expected > 0 {
+		return fmt.Errorf("%w: cart %s doesn't exist", datastore.ErrVersionMismatch, userID)
+	}
+
+	version, err = updateVersion(ctx, filter(userID), update, true)
+	if err != nil {
+		return err
+	}
+
+	return recordVersion(ctx, userID, version, payload)
+}
+
+// updateVersion updates the cart that matches the filter and returns the new version of the cart.
+// mongo.ErrNoDocuments is returned when no cart matches the filter.
+func updateVersion(ctx context.Context, f bson.D, update bson.D, upsert bool) (int64, error) {
+	var doc struct {
+		Version int64 `bson:"Version"`
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetUpsert(upsert)
+	err := dbs.FindOneAndUpdate(ctx, f, update, opts).Decode(&doc)
+	return doc.Version, err
+}
+
+// versions returns the collection in which the versions of carts are stored
+func versions() *mongo.Collection {
+	return dbs.Database().Collection("cartversion")
+}
+
+// versionFilter returns the filter to select a cart at the version. Carts
+// that don't have any versions yet are at version 0.
+func versionFilter(version int64) bson.E {
+	if version == 0 {
+		return bson.E{Key: "Version", Value: bson.D{{Key: "$exists", Value: false}}}
+	}
+	return bson.E{Key: "Version", Value: version}
+}
+
+// recordVersion stores a version of a cart and removes the version that no longer fits in the history
+func recordVersion(ctx context.Context, key string, version int64, payload string) error {
+	doc := bson.D{
+		{Key: "SK", Value: key},
+		{Key: "Version", Value: version},
+		{Key: "Payload", Value: payload},
+		{Key: "Created", Value: time.Now().UTC()},
+	}
+
+	if _, err := versions().InsertOne(ctx, doc); err != nil {
+		return err
+	}
+
+	_, err := versions().DeleteMany(ctx, bson.D{
+		{Key: "SK", Value: key},
+		{Key: "Version", Value: bson.D{{Key: "$lte", Value: version - datastore.HistorySize}}},
+	})
+	return err
+}
+
+// Versions returns the versions of a cart that are kept, newest first
+func (m manager) Versions(key string) ([]datastore.Version, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := versions().Find(ctx, filter(key), options.Find().SetSort(bson.D{{Key: "Version", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	var results []bson.Raw
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	// Make sure the cart exists when it has no versions
+	if len(results) == 0 {
+		if _, err := getItems(ctx, key); err != nil {
+			return nil, err
+		}
+	}
+
+	res := make([]datastore.Version, 0, len(results))
+	for _, raw := range results {
+		v, err := unmarshalVersion(raw)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+	}
+
+	return res, nil
+}
+
+// GetVersion returns a version of a cart
+func (m manager) GetVersion(key string, version int64) (datastore.Version, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	raw, err := versions().FindOne(ctx, bson.D{{Key: "SK", Value: key}, {Key: "Version", Value: version}}).DecodeBytes()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return datastore.Version{}, fmt.Errorf("%w: cart %s has no version %d", datastore.ErrVersionNotFound, key, version)
+	}
+	if err != nil {
+		return datastore.Version{}, fmt.Errorf("unable to decode bytes: %s", err.Error())
+	}
+
+	return unmarshalVersion(raw)
+}
+
+// StoreItemsIfVersion saves the cart items when the cart is at the version
+func (m manager) StoreItemsIfVersion(key string, i datastore.CartItems, version int64) error {
+	payload, err := i.Marshal()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return withTransaction(ctx, func(sc context.Context) error {
+		return storePayload(sc, key, string(payload), version)
+	})
+}
+
+// unmarshalVersion creates a Version from a stored version
+func unmarshalVersion(raw bson.Raw) (datastore.Version, error) {
+	v := datastore.Version{
+		Version: raw.Lookup("Version").Int64(),
+		Created: raw.Lookup("Created").Time(),
+		Items:   make(datastore.CartItems, 0),
+	}
+
+	// Versions of carts that have been cleared contain an empty payload
+	items, err := datastore.UnmarshalPayload(raw.Lookup("Payload").StringValue())
+	v.Items = items
+	return v, err
+}
+
+// ListCarts returns the default cart and the named carts of a user
+func (m manager) ListCarts(userID string) ([]datastore.CartInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	carts := make([]datastore.CartInfo, 0)
+
+	// The default cart is stored under the userID
+	defaultCart := datastore.CartInfo{
+		ID:   datastore.DefaultCart,
+		Name: datastore.DefaultCart,
+	}
+
+	raw, err := dbs.FindOne(ctx, filter(userID)).DecodeBytes()
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("unable to decode bytes: %s", err.Error())
+	}
+	if err == nil {
+		if name, ok := raw.Lookup("CartName").StringValueOK(); ok {
+			defaultCart.Name = name
+		}
+	}
+	carts = append(carts, defaultCart)
+
+	prefix := bson.D{{Key: "SK", Value: bson.D{{Key: "$regex", Value: "^" + regexp.QuoteMeta(userID+"#")}}}}
+	cursor, err := dbs.Find(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []bson.M
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	for _, result := range results {
+		_, cartID := datastore.SplitCartKey(result["SK"].(string))
+		c := datastore.CartInfo{
+			ID:   cartID,
+			Name: cartID,
+		}
+		if name, ok := result["CartName"].(string); ok {
+			c.Name = name
+		}
+		carts = append(carts, c)
+	}
+
+	return carts, nil
+}
+
+// CreateCart creates an empty named cart for a user
+func (m manager) CreateCart(userID string, c datastore.CartInfo) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	insert := bson.D{{Key: "$setOnInsert", Value: bson.D{
+		{Key: "Payload", Value: ""},
+		{Key: "CartName", Value: c.Name},
+		{Key: "Updated", Value: time.Now().UTC()},
+	}}}
+
+	res, err := dbs.UpdateOne(ctx, filter(datastore.CartKey(userID, c.ID)), insert, options.Update().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+
+	if res.UpsertedCount == 0 {
+		return fmt.Errorf("%w: user %s already has a cart with id %s", datastore.ErrCartExists, userID, c.ID)
+	}
+
+	return nil
+}
+
+// RenameCart changes the name of a cart of a user
+func (m manager) RenameCart(userID string, cartID string, name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "CartName", Value: name}}}}
+
+	res, err := dbs.UpdateOne(ctx, filter(datastore.CartKey(userID, cartID)), update)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%w: user %s has no cart with id %s", datastore.ErrCartNotFound, userID, cartID)
+	}
+
+	return nil
+}
+
+// DeleteCart removes a named cart of a user
+func (m manager) DeleteCart(userID string, cartID string) error {
+	if len(cartID) == 0 || cartID == datastore.DefaultCart {
+		return fmt.Errorf("the default cart can't be deleted")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := dbs.DeleteOne(ctx, filter(datastore.CartKey(userID, cartID)))
+	if err != nil {
+		return err
+	}
+
+	if res.DeletedCount == 0 {
+		return fmt.Errorf("%w: user %s has no cart with id %s", datastore.ErrCartNotFound, userID, cartID)
+	}
+
+	_, err = versions().DeleteMany(ctx, filter(datastore.CartKey(userID, cartID)))
+	return err
+}
+
+// GetSavedItems returns the saved-for-later list of a user
+func (m manager) GetSavedItems(userID string) (datastore.CartItems, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return getSaved(ctx, userID)
+}
+
+// SaveForLater moves an item from a cart to the saved-for-later list of the user
+func (m manager) SaveForLater(key string, line datastore.CartItem, version int64) error {
+	return m.moveSaved(key, line, version, true)
+}
+
+// MoveToCart moves an item from the saved-for-later list of the user to a cart
+func (m manager) MoveToCart(key string, line datastore.CartItem, version int64) error {
+	return m.moveSaved(key, line, version, false)
+}
+
+// moveSaved moves a line between the cart with the key and the saved-for-later list,
+// which is stored with the default cart of the user. When the cart is a named cart both
+// documents are updated in a single transaction. Unless expected is anyVersion, the cart has
+// to be at that version.
+func (m manager) moveSaved(key string, line datastore.CartItem, expected int64, save bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, _ := datastore.SplitCartKey(key)
+
+	return withTransaction(ctx, func(sc context.Context) error {
+		items, err := getItems(sc, key)
+		if err != nil {
+			return err
+		}
+
+		saved, err := getSaved(sc, userID)
+		if err != nil {
+			return err
+		}
+
+		if save {
+			items, saved, err = datastore.MoveItem(items, saved, line)
+		} else {
+			saved, items, err = datastore.MoveItem(saved, items, line)
+		}
+		if err != nil {
+			return err
+		}
+
+		payload, err := items.Marshal()
+		if err != nil {
+			return err
+		}
+
+		savedPayload, err := saved.Marshal()
+		if err != nil {
+			return err
+		}
+
+		// The saved-for-later list of the default cart is stored in the same document
+		if key == userID {
+			return storePayload(sc, key, string(payload), expected, bson.E{Key: "Saved", Value: string(savedPayload)})
+		}
+
+		if err := storePayload(sc, key, string(payload), expected); err != nil {
+			return err
+		}
+
+		// The default cart is created with an empty payload when the user doesn't have one yet
+		update := bson.D{
+			{Key: "$set", Value: bson.D{{Key: "Saved", Value: string(savedPayload)}}},
+			{Key: "$setOnInsert", Value: bson.D{{Key: "Payload", Value: "[]"}}},
+		}
+		_, err = dbs.UpdateOne(sc, filter(userID), update, options.Update().SetUpsert(true))
+		return err
+	})
+}
+
+// getSaved returns the saved-for-later list stored with the default cart of the user
+func getSaved(ctx context.Context, userID string) (datastore.CartItems, error) {
+	raw, err := dbs.FindOne(ctx, filter(userID)).DecodeBytes()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return make(datastore.CartItems, 0), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode bytes: %s", err.Error())
+	}
+
+	saved, ok := raw.Lookup("Saved").StringValueOK()
+	if !ok {
+		return make(datastore.CartItems, 0), nil
+	}
+
+	return datastore.UnmarshalPayload(saved)
+}
+
+// Members returns the collaborators of a cart
+func (m manager) Members(key string) ([]datastore.Member, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	raw, err := dbs.FindOne(ctx, filter(key)).DecodeBytes()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("%w: no cart found with key %s", datastore.ErrCartNotFound, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode bytes: %s", err.Error())
+	}
+
+	members := make([]datastore.Member, 0)
+
+	// Carts that were never shared don't have any members
+	payload, ok := raw.Lookup("Members").StringValueOK()
+	if !ok {
+		return members, nil
+	}
+
+	if err := json.Unmarshal([]byte(payload), &members); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// SetMember adds a collaborator to a cart or changes their role
+func (m manager) SetMember(key string, member datastore.Member) error {
+	members, err := m.Members(key)
+	if err != nil {
+		return err
+	}
+
+	updated := false
+	for idx := range members {
+		if members[idx].UserID == member.UserID {
+			members[idx].Role = member.Role
+			updated = true
+		}
+	}
+	if !updated {
+		members = append(members, member)
+	}
+
+	return storeMembers(key, members)
+}
+
+// RemoveMember removes a collaborator from a cart
+func (m manager) RemoveMember(key string, userID string) error {
+	members, err := m.Members(key)
+	if err != nil {
+		return err
+	}
+
+	remaining := make([]datastore.Member, 0, len(members))
+	for _, member := range members {
+		if member.UserID != userID {
+			remaining = append(remaining, member)
+		}
+	}
+
+	return storeMembers(key, remaining)
+}
+
+// storeMembers saves the collaborators of an existing cart
+func storeMembers(key string, members []datastore.Member) error {
+	payload, err := json.Marshal(members)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "Members", Value: string(payload)}}}}
+
+	res, err := dbs.UpdateOne(ctx, filter(key), update)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		return fmt.Errorf("%w: no cart found with key %s", datastore.ErrCartNotFound, key)
+	}
+
+	return nil
+}
+
+// LockCart locks a cart so the items can't be changed
+func (m manager) LockCart(key string, lockID string, d time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "LockID", Value: lockID},
+		{Key: "LockedUntil", Value: time.Now().Add(d).UTC()},
+	}}}
+
+	res, err := dbs.UpdateOne(ctx, unlockedFilter(key), update)
+	if err != nil {
+		return err
+	}
+
+	if res.MatchedCount == 0 {
+		if _, err := getItems(ctx, key); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: cart %s is already locked", datastore.ErrCartLocked, key)
+	}
+
+	return nil
+}
+
+// UnlockCart removes the lock from a cart
+func (m manager) UnlockCart(key string, lockID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.D{{Key: "$unset", Value: bson.D{
+		{Key: "LockID", Value: ""},
+		{Key: "LockedUntil", Value: ""},
+	}}}
+
+	// When nothing matches, the lock has expired and the cart may have been locked again
+	_, err := dbs.UpdateOne(ctx, bson.D{{Key: "SK", Value: key}, {Key: "LockID", Value: lockID}}, update)
+	return err
+}
+
+// CheckoutCart empties a locked cart and optionally archives the items in the cart
+// in the cartarchive collection
+func (m manager) CheckoutCart(key string, lockID string, archive bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return withTransaction(ctx, func(sc context.Context) error {
+		items, err := getItems(sc, key)
+		if err != nil {
+			return err
+		}
+
+		if archive {
+			payload, err := items.Marshal()
+			if err != nil {
+				return err
+			}
+
+			archived := bson.D{
+				{Key: "SK", Value: key + "#" + lockID},
+				{Key: "Payload", Value: string(payload)},
+				{Key: "Updated", Value: time.Now().UTC()},
+			}
+
+			if _, err := dbs.Database().Collection("cartarchive").InsertOne(sc, archived); err != nil {
+				return err
+			}
+		}
+
+		update := bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "Payload", Value: ""},
+				{Key: "Updated", Value: time.Now().UTC()},
+			}},
+			{Key: "$unset", Value: bson.D{
+				{Key: "LockID", Value: ""},
+				{Key: "LockedUntil", Value: ""},
+			}},
+			{Key: "$inc", Value: bson.D{{Key: "Version", Value: int64(1)}}},
+		}
+
+		// The cart is only checked out while the lock is held. Once the lock has expired, the
+		// items may have changed since they were sent with the CartCheckedOut event.
+		locked := bson.D{
+			{Key: "SK", Value: key},
+			{Key: "LockID", Value: lockID},
+			{Key: "LockedUntil", Value: bson.D{{Key: "$gte", Value: time.Now().UTC()}}},
+		}
+
+		version, err := updateVersion(sc, locked, update, false)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return fmt.Errorf("%w: the lock on cart %s has expired", datastore.ErrCartLocked, key)
+		}
+		if err != nil {
+			return err
+		}
+
+		return recordVersion(sc, key, version, "")
+	})
+}
+
+// idempotency returns the collection with the requests that were made with an idempotency key
+func idempotency() *mongo.Collection {
+	return dbs.Database().Collection("cartidempotency")
+}
+
+// isDuplicateKey returns true when a write failed because a document with the same _id exists
+func isDuplicateKey(err error) bool {
+	var we mongo.WriteException
+	if errors.As(err, &we) {
+		for _, e := range we.WriteErrors {
+			if e.Code == 11000 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ClaimIdempotencyKey stores a request made with an idempotency key, unless the key is in use
+func (m manager) ClaimIdempotencyKey(r datastore.IdempotentRequest) (datastore.IdempotentRequest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	doc := bson.D{
+		{Key: "_id", Value: r.Key},
+		{Key: "Fingerprint", Value: r.Fingerprint},
+		{Key: "Expires", Value: r.Expires.UTC()},
+		{Key: "Done", Value: false},
+	}
+
+	// Requests that have expired are replaced. When the key is still in use the filter
+	// doesn't match, and the upsert fails because the _id already exists.
+	f := bson.D{{Key: "_id", Value: r.Key}, {Key: "Expires", Value: bson.D{{Key: "$lt", Value: time.Now().UTC()}}}}
+	_, err := idempotency().ReplaceOne(ctx, f, doc, options.Replace().SetUpsert(true))
+	if !isDuplicateKey(err) {
+		return r, err
+	}
+
+	raw, err := idempotency().FindOne(ctx, bson.D{{Key: "_id", Value: r.Key}}).DecodeBytes()
+
+	// The request was released in the meantime, so it is still being made
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return r, fmt.Errorf("%w: %s", datastore.ErrIdempotencyKeyUsed, r.Key)
+	}
+	if err != nil {
+		return r, err
+	}
+
+	stored := datastore.IdempotentRequest{
+		Key:         r.Key,
+		Fingerprint: raw.Lookup("Fingerprint").StringValue(),
+		Expires:     raw.Lookup("Expires").Time(),
+		Done:        raw.Lookup("Done").Boolean(),
+	}
+	if stored.Done {
+		stored.StatusCode = int(raw.Lookup("StatusCode").Int32())
+		stored.ContentType = raw.Lookup("ContentType").StringValue()
+		stored.Body = raw.Lookup("Body").StringValue()
+	}
+
+	return stored, fmt.Errorf("%w: %s", datastore.ErrIdempotencyKeyUsed, r.Key)
+}
+
+// CompleteIdempotencyKey stores the response of a request made with an idempotency key
+func (m manager) CompleteIdempotencyKey(r datastore.IdempotentRequest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "Done", Value: true},
+		{Key: "StatusCode", Value: int32(r.StatusCode)},
+		{Key: "ContentType", Value: r.ContentType},
+		{Key: "Body", Value: r.Body},
+	}}}
+
+	_, err := idempotency().UpdateOne(ctx, bson.D{{Key: "_id", Value: r.Key}}, update)
+	return err
+}
+
+// ReleaseIdempotencyKey removes a request made with an idempotency key
+func (m manager) ReleaseIdempotencyKey(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := idempotency().DeleteOne(ctx, bson.D{{Key: "_id", Value: key}})
+	return err
+}
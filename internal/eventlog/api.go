@@ -0,0 +1,109 @@
+// Package eventlog records the changes to carts as a log of events, for the event-sourced
+// mode of the Cart service. In that mode every change to the items in a cart is appended
+// to the log, and the stored cart is a projection of the log. Every so many events a snapshot
+// of the projection is stored, so a cart can be rebuilt at any point in time without replaying
+// the whole log. In order to add a new place to store the log, the Log interface needs to be
+// implemented.
+package eventlog
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+)
+
+const (
+	// ItemAdded is the type of event for a line that is added to a cart
+	ItemAdded = "ItemAdded"
+
+	// ItemModified is the type of event for a line in a cart that is changed
+	ItemModified = "ItemModified"
+
+	// ItemRemoved is the type of event for a line that is removed from a cart
+	ItemRemoved = "ItemRemoved"
+
+	// CartCleared is the type of event for a cart of which all lines are removed
+	CartCleared = "CartCleared"
+)
+
+// ErrNoEvents is returned when a cart has no events in the log.
+var ErrNoEvents = errors.New("no events found")
+
+// Event is a change to the items in a cart
+type Event struct {
+	// Key is the key of the cart
+	Key string `json:"key"`
+
+	// Sequence is the number of the event in the log of the cart, starting at 1
+	Sequence int64 `json:"sequence"`
+
+	// Type is the type of event
+	Type string `json:"type"`
+
+	// Item is the line that is added, changed, or removed
+	Item *datastore.CartItem `json:"item,omitempty"`
+
+	// Time is when the change was made
+	Time time.Time `json:"time"`
+}
+
+// Marshal returns the JSON encoding of Event
+func (e *Event) Marshal() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// UnmarshalEvent parses the JSON-encoded data and stores the result in an Event
+func UnmarshalEvent(data []byte) (Event, error) {
+	var e Event
+	err := json.Unmarshal(data, &e)
+	return e, err
+}
+
+// Snapshot is the projection of the log of a cart after an event
+type Snapshot struct {
+	// Key is the key of the cart
+	Key string `json:"key"`
+
+	// Sequence is the number of the last event in the snapshot
+	Sequence int64 `json:"sequence"`
+
+	// Items are the items in the cart after that event
+	Items datastore.CartItems `json:"items"`
+
+	// Time is when the last event in the snapshot happened
+	Time time.Time `json:"time"`
+}
+
+// Marshal returns the JSON encoding of Snapshot
+func (s *Snapshot) Marshal() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// UnmarshalSnapshot parses the JSON-encoded data and stores the result in a Snapshot
+func UnmarshalSnapshot(data []byte) (Snapshot, error) {
+	var s Snapshot
+	err := json.Unmarshal(data, &s)
+	return s, err
+}
+
+// Log is the interface that describes the methods a
+// store needs to implement to keep the event log of carts.
+type Log interface {
+	// Append adds the events to the end of the log of the cart with the key, and
+	// returns them numbered in the order they were added.
+	Append(key string, events []Event) ([]Event, error)
+
+	// Events returns the events of the cart with the key that come after the
+	// event with the sequence number, oldest first.
+	Events(key string, after int64) ([]Event, error)
+
+	// SaveSnapshot stores a snapshot of a cart.
+	SaveSnapshot(s Snapshot) error
+
+	// LatestSnapshot returns the latest snapshot of the cart with the key that was
+	// taken at or before the time. An empty snapshot with sequence number 0 is
+	// returned when there is none.
+	LatestSnapshot(key string, at time.Time) (Snapshot, error)
+}
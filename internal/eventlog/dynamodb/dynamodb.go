@@ -0,0 +1,287 @@
+// Package dynamodb keeps the event log of carts in the Amazon DynamoDB table of the Cart service.
+package dynamodb
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/retgits/acme-serverless-cart/internal/eventlog"
+)
+
+const (
+	// The events of a cart are stored for the access pattern PK = EVENT#KEY SK = SEQUENCE
+	eventPrefix = "EVENT#"
+
+	// The snapshots of a cart are stored for the access pattern PK = SNAPSHOT#KEY SK = SEQUENCE
+	snapshotPrefix = "SNAPSHOT#"
+
+	// The last sequence number of a cart is stored for the access pattern PK = EVENTSEQUENCE SK = KEY
+	sequenceKey = "EVENTSEQUENCE"
+
+	// batchSize is the number of items DynamoDB writes in a single batch
+	batchSize = 25
+)
+
+// The pointer to DynamoDB provides the API operation methods for making requests to Amazon DynamoDB.
+// This specifically creates a single instance of the dynamoDB service which can be reused if the
+// container stays warm.
+var dbs *dynamodb.DynamoDB
+
+// log is an empty struct that implements the methods of the
+// Log interface.
+type log struct{}
+
+// init creates the connection to dynamoDB. If the environment variable
+// DYNAMO_URL is set, the connection is made to that URL instead of
+// relying on the AWS SDK to provide the URL
+func init() {
+	awsSession := session.Must(session.NewSession(&aws.Config{
+		Region: aws.String(os.Getenv("REGION")),
+	}))
+
+	if len(os.Getenv("DYNAMO_URL")) > 0 {
+		awsSession.Config.Endpoint = aws.String(os.Getenv("DYNAMO_URL"))
+	}
+
+	dbs = dynamodb.New(awsSession)
+}
+
+// New creates a new event log using Amazon DynamoDB as backend
+func New() eventlog.Log {
+	return log{}
+}
+
+// Append reserves sequence numbers for the events and writes them in batches
+func (l log) Append(key string, events []eventlog.Event) ([]eventlog.Event, error) {
+	// Reserve the sequence numbers, so events of concurrent changes never overwrite each other
+	km := make(map[string]*dynamodb.AttributeValue)
+	km["PK"] = &dynamodb.AttributeValue{
+		S: aws.String(sequenceKey),
+	}
+	km["SK"] = &dynamodb.AttributeValue{
+		S: aws.String(key),
+	}
+
+	uio, err := dbs.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:        aws.String(os.Getenv("TABLE")),
+		Key:              km,
+		UpdateExpression: aws.String("ADD #sequence :n"),
+		ExpressionAttributeNames: map[string]*string{
+			"#sequence": aws.String("Sequence"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":n": {
+				N: aws.String(strconv.Itoa(len(events))),
+			},
+		},
+		ReturnValues: aws.String("UPDATED_NEW"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	last, err := strconv.ParseInt(*uio.Attributes["Sequence"].N, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	numbered := make([]eventlog.Event, len(events))
+	writes := make([]*dynamodb.WriteRequest, 0, len(events))
+	for i, e := range events {
+		e.Key = key
+		e.Sequence = last - int64(len(events)) + int64(i) + 1
+		numbered[i] = e
+
+		item, err := marshalEvent(e)
+		if err != nil {
+			return nil, err
+		}
+		writes = append(writes, &dynamodb.WriteRequest{
+			PutRequest: &dynamodb.PutRequest{Item: item},
+		})
+	}
+
+	for len(writes) > 0 {
+		n := len(writes)
+		if n > batchSize {
+			n = batchSize
+		}
+
+		if err := writeBatch(writes[:n]); err != nil {
+			return nil, err
+		}
+		writes = writes[n:]
+	}
+
+	return numbered, nil
+}
+
+// Events queries the events of the cart after the sequence number
+func (l log) Events(key string, after int64) ([]eventlog.Event, error) {
+	km := make(map[string]*dynamodb.AttributeValue)
+	km[":pk"] = &dynamodb.AttributeValue{
+		S: aws.String(eventPrefix + key),
+	}
+	km[":after"] = &dynamodb.AttributeValue{
+		S: aws.String(sequence(after)),
+	}
+
+	qi := &dynamodb.QueryInput{
+		TableName:                 aws.String(os.Getenv("TABLE")),
+		KeyConditionExpression:    aws.String("PK = :pk AND SK > :after"),
+		ExpressionAttributeValues: km,
+	}
+
+	events := make([]eventlog.Event, 0)
+	var perr error
+
+	err := dbs.QueryPages(qi, func(qo *dynamodb.QueryOutput, lastPage bool) bool {
+		for _, item := range qo.Items {
+			if item["Payload"] == nil || item["Payload"].S == nil {
+				perr = fmt.Errorf("event %s of cart %s has no payload", aws.StringValue(item["SK"].S), key)
+				return false
+			}
+			e, err := eventlog.UnmarshalEvent([]byte(*item["Payload"].S))
+			if err != nil {
+				perr = err
+				return false
+			}
+			events = append(events, e)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, perr
+}
+
+// SaveSnapshot stores the snapshot in the table
+func (l log) SaveSnapshot(s eventlog.Snapshot) error {
+	payload, err := s.Marshal()
+	if err != nil {
+		return err
+	}
+
+	km := make(map[string]*dynamodb.AttributeValue)
+	km["PK"] = &dynamodb.AttributeValue{
+		S: aws.String(snapshotPrefix + s.Key),
+	}
+	km["SK"] = &dynamodb.AttributeValue{
+		S: aws.String(sequence(s.Sequence)),
+	}
+	km["Payload"] = &dynamodb.AttributeValue{
+		S: aws.String(string(payload)),
+	}
+	km["Taken"] = &dynamodb.AttributeValue{
+		N: aws.String(strconv.FormatInt(s.Time.UnixNano()/int64(time.Millisecond), 10)),
+	}
+
+	_, err = dbs.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(os.Getenv("TABLE")),
+		Item:      km,
+	})
+
+	return err
+}
+
+// LatestSnapshot queries the snapshots of the cart, newest first, for the first one
+// taken at or before the time
+func (l log) LatestSnapshot(key string, at time.Time) (eventlog.Snapshot, error) {
+	km := make(map[string]*dynamodb.AttributeValue)
+	km[":pk"] = &dynamodb.AttributeValue{
+		S: aws.String(snapshotPrefix + key),
+	}
+	km[":at"] = &dynamodb.AttributeValue{
+		N: aws.String(strconv.FormatInt(at.UnixNano()/int64(time.Millisecond), 10)),
+	}
+
+	qi := &dynamodb.QueryInput{
+		TableName:                 aws.String(os.Getenv("TABLE")),
+		KeyConditionExpression:    aws.String("PK = :pk"),
+		FilterExpression:          aws.String("Taken <= :at"),
+		ExpressionAttributeValues: km,
+		ScanIndexForward:          aws.Bool(false),
+	}
+
+	latest := eventlog.Snapshot{Key: key}
+	var perr error
+
+	err := dbs.QueryPages(qi, func(qo *dynamodb.QueryOutput, lastPage bool) bool {
+		if len(qo.Items) == 0 {
+			return true
+		}
+		item := qo.Items[0]
+		if item["Payload"] == nil || item["Payload"].S == nil {
+			perr = fmt.Errorf("snapshot of cart %s has no payload", key)
+			return false
+		}
+		latest, perr = eventlog.UnmarshalSnapshot([]byte(*item["Payload"].S))
+		return false
+	})
+	if err != nil {
+		return latest, err
+	}
+
+	return latest, perr
+}
+
+// marshalEvent returns the item that stores the event
+func marshalEvent(e eventlog.Event) (map[string]*dynamodb.AttributeValue, error) {
+	payload, err := e.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	km := make(map[string]*dynamodb.AttributeValue)
+	km["PK"] = &dynamodb.AttributeValue{
+		S: aws.String(eventPrefix + e.Key),
+	}
+	km["SK"] = &dynamodb.AttributeValue{
+		S: aws.String(sequence(e.Sequence)),
+	}
+	km["Type"] = &dynamodb.AttributeValue{
+		S: aws.String(e.Type),
+	}
+	km["Payload"] = &dynamodb.AttributeValue{
+		S: aws.String(string(payload)),
+	}
+
+	return km, nil
+}
+
+// writeBatch writes the items, and retries the items DynamoDB didn't process
+func writeBatch(writes []*dynamodb.WriteRequest) error {
+	for i := 0; i < 5 && len(writes) > 0; i++ {
+		bo, err := dbs.BatchWriteItem(&dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]*dynamodb.WriteRequest{
+				os.Getenv("TABLE"): writes,
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		writes = bo.UnprocessedItems[os.Getenv("TABLE")]
+		if len(writes) > 0 {
+			time.Sleep(time.Duration(i+1) * 100 * time.Millisecond)
+		}
+	}
+
+	if len(writes) > 0 {
+		return fmt.Errorf("%d events couldn't be written", len(writes))
+	}
+
+	return nil
+}
+
+// sequence pads the sequence number, so events are sorted by number
+func sequence(n int64) string {
+	return fmt.Sprintf("%010d", n)
+}
@@ -0,0 +1,192 @@
+// Package file keeps the event log of carts in files on disk, with one file of events and
+// one file of snapshots per cart. The files can only be shared by a single instance of the
+// Cart service, which makes the package useful to try out the event-sourced mode, and to
+// replay logs that were copied for support investigations.
+package file
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/retgits/acme-serverless-cart/internal/eventlog"
+)
+
+// log is the event log in a directory
+type log struct {
+	dir string
+	mu  *sync.Mutex
+}
+
+// New creates a Log that keeps its files in the directory, which is created when it
+// doesn't exist.
+func New(dir string) (eventlog.Log, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating event log directory: %s", err.Error())
+	}
+
+	return log{
+		dir: dir,
+		mu:  &sync.Mutex{},
+	}, nil
+}
+
+// Append adds the events to the file of the cart
+func (l log) Append(key string, events []eventlog.Event) ([]eventlog.Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	existing, err := l.events(key)
+	if err != nil {
+		return nil, err
+	}
+
+	last := int64(0)
+	if len(existing) > 0 {
+		last = existing[len(existing)-1].Sequence
+	}
+
+	numbered := make([]eventlog.Event, len(events))
+	lines := make([]interface{}, len(events))
+	for i, e := range events {
+		e.Key = key
+		e.Sequence = last + int64(i) + 1
+		numbered[i] = e
+		lines[i] = &numbered[i]
+	}
+
+	if err := appendLines(l.path(key, "events"), lines); err != nil {
+		return nil, err
+	}
+
+	return numbered, nil
+}
+
+// Events returns the events in the file of the cart after the sequence number
+func (l log) Events(key string, after int64) ([]eventlog.Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	events, err := l.events(key)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]eventlog.Event, 0, len(events))
+	for _, e := range events {
+		if e.Sequence > after {
+			res = append(res, e)
+		}
+	}
+
+	return res, nil
+}
+
+// SaveSnapshot adds the snapshot to the snapshot file of the cart
+func (l log) SaveSnapshot(s eventlog.Snapshot) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return appendLines(l.path(s.Key, "snapshots"), []interface{}{&s})
+}
+
+// LatestSnapshot returns the snapshot of the cart with the highest sequence number
+// that was taken at or before the time
+func (l log) LatestSnapshot(key string, at time.Time) (eventlog.Snapshot, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	latest := eventlog.Snapshot{Key: key}
+
+	err := readLines(l.path(key, "snapshots"), func(line []byte) error {
+		s, err := eventlog.UnmarshalSnapshot(line)
+		if err != nil {
+			return err
+		}
+		if !s.Time.After(at) && s.Sequence > latest.Sequence {
+			latest = s
+		}
+		return nil
+	})
+
+	return latest, err
+}
+
+// events reads all events of the cart
+func (l log) events(key string) ([]eventlog.Event, error) {
+	events := make([]eventlog.Event, 0)
+
+	err := readLines(l.path(key, "events"), func(line []byte) error {
+		e, err := eventlog.UnmarshalEvent(line)
+		if err != nil {
+			return err
+		}
+		events = append(events, e)
+		return nil
+	})
+
+	return events, err
+}
+
+// path returns the name of a file of the cart. The key is escaped, as it can contain
+// characters that aren't allowed in file names.
+func (l log) path(key string, kind string) string {
+	return filepath.Join(l.dir, fmt.Sprintf("%s.%s.jsonl", url.PathEscape(key), kind))
+}
+
+// appendLines writes the values as JSON, one per line, to the end of the file
+func appendLines(path string, values []interface{}) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	for _, v := range values {
+		b, err := json.Marshal(v)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		w.Write(b)
+		w.WriteByte('\n')
+	}
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// readLines calls fn for every line in the file. A file that doesn't exist has no lines.
+func readLines(path string, fn func(line []byte) error) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := fn(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
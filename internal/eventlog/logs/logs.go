@@ -0,0 +1,54 @@
+// Package logs creates the event log that the Cart service uses in the event-sourced
+// mode, based on environment variables.
+package logs
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"github.com/retgits/acme-serverless-cart/internal/eventlog"
+	"github.com/retgits/acme-serverless-cart/internal/eventlog/dynamodb"
+	"github.com/retgits/acme-serverless-cart/internal/eventlog/file"
+	"github.com/retgits/acme-serverless-cart/internal/eventlog/mongodb"
+)
+
+// FromEnv creates the Log selected by CART_EVENT_LOG, which is one of dynamodb, mongodb,
+// or file (using CART_EVENT_LOG_DIR). When CART_EVENT_LOG isn't set, nil is returned.
+func FromEnv() (eventlog.Log, error) {
+	switch kind := os.Getenv("CART_EVENT_LOG"); kind {
+	case "dynamodb":
+		return dynamodb.New(), nil
+	case "mongodb":
+		return mongodb.New()
+	case "file":
+		if len(os.Getenv("CART_EVENT_LOG_DIR")) == 0 {
+			return nil, fmt.Errorf("CART_EVENT_LOG_DIR must be set to keep the event log in files")
+		}
+		return file.New(os.Getenv("CART_EVENT_LOG_DIR"))
+	case "":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown CART_EVENT_LOG %s", kind)
+	}
+}
+
+// Wrap returns db in the event-sourced mode when CART_EVENT_LOG is set, with a snapshot
+// every CART_SNAPSHOT_EVERY events (50 by default). Otherwise db is returned as is.
+func Wrap(db datastore.Manager) (datastore.Manager, error) {
+	l, err := FromEnv()
+	if err != nil || l == nil {
+		return db, err
+	}
+
+	every := int64(50)
+	if len(os.Getenv("CART_SNAPSHOT_EVERY")) > 0 {
+		every, err = strconv.ParseInt(os.Getenv("CART_SNAPSHOT_EVERY"), 10, 64)
+		if err != nil || every <= 0 {
+			return db, fmt.Errorf("invalid CART_SNAPSHOT_EVERY %s", os.Getenv("CART_SNAPSHOT_EVERY"))
+		}
+	}
+
+	return eventlog.NewManager(db, l, every), nil
+}
@@ -0,0 +1,195 @@
+package eventlog
+
+import (
+	"errors"
+	"time"
+
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+)
+
+// manager wraps a datastore Manager and appends every change to the items in a cart
+// to the event log. The stored cart is the projection that the Cart service reads from.
+type manager struct {
+	datastore.Manager
+	log           Log
+	snapshotEvery int64
+}
+
+// NewManager returns a Manager in the event-sourced mode. The changes are made in db,
+// and then appended to the log. A snapshot is stored every snapshotEvery events.
+func NewManager(db datastore.Manager, l Log, snapshotEvery int64) datastore.Manager {
+	if snapshotEvery <= 0 {
+		snapshotEvery = 50
+	}
+
+	return manager{
+		Manager:       db,
+		log:           l,
+		snapshotEvery: snapshotEvery,
+	}
+}
+
+// AddItem adds a line to the cart and appends an ItemAdded event
+func (m manager) AddItem(key string, i datastore.CartItem) error {
+	if err := m.Manager.AddItem(key, i); err != nil {
+		return err
+	}
+
+	return m.record(key, []Event{{Key: key, Type: ItemAdded, Item: &i}})
+}
+
+// ClearCart removes all lines from the cart and appends a CartCleared event
+func (m manager) ClearCart(key string) error {
+	if err := m.Manager.ClearCart(key); err != nil {
+		return err
+	}
+
+	return m.record(key, []Event{{Key: key, Type: CartCleared}})
+}
+
+// StoreItems replaces the lines in the cart and appends the events for the changed lines
+func (m manager) StoreItems(key string, i datastore.CartItems) error {
+	before, err := m.items(key)
+	if err != nil {
+		return err
+	}
+
+	if err := m.Manager.StoreItems(key, i); err != nil {
+		return err
+	}
+
+	return m.record(key, Changes(key, before, i))
+}
+
+// StoreItemsIfVersion replaces the lines in the cart at the version and appends the events
+// for the changed lines
+func (m manager) StoreItemsIfVersion(key string, i datastore.CartItems, version int64) error {
+	before, err := m.items(key)
+	if err != nil {
+		return err
+	}
+
+	if err := m.Manager.StoreItemsIfVersion(key, i, version); err != nil {
+		return err
+	}
+
+	return m.record(key, Changes(key, before, i))
+}
+
+// MoveCart moves the lines to the cart toID, and appends the events for both carts
+func (m manager) MoveCart(fromID string, toID string, i datastore.CartItems) error {
+	before, err := m.items(toID)
+	if err != nil {
+		return err
+	}
+
+	if err := m.Manager.MoveCart(fromID, toID, i); err != nil {
+		return err
+	}
+
+	if err := m.record(toID, Changes(toID, before, i)); err != nil {
+		return err
+	}
+
+	return m.record(fromID, []Event{{Key: fromID, Type: CartCleared}})
+}
+
+// DeleteCart removes the cart and appends a CartCleared event
+func (m manager) DeleteCart(userID string, cartID string) error {
+	if err := m.Manager.DeleteCart(userID, cartID); err != nil {
+		return err
+	}
+
+	key := datastore.CartKey(userID, cartID)
+	return m.record(key, []Event{{Key: key, Type: CartCleared}})
+}
+
+// SaveForLater moves the line to the saved items and appends the events for the cart
+func (m manager) SaveForLater(key string, line datastore.CartItem, version int64) error {
+	return m.changeItems(key, func() error {
+		return m.Manager.SaveForLater(key, line, version)
+	})
+}
+
+// MoveToCart moves the line from the saved items and appends the events for the cart
+func (m manager) MoveToCart(key string, line datastore.CartItem, version int64) error {
+	return m.changeItems(key, func() error {
+		return m.Manager.MoveToCart(key, line, version)
+	})
+}
+
+// CheckoutCart checks out the cart and appends a CartCleared event
+func (m manager) CheckoutCart(key string, lockID string, archive bool) error {
+	if err := m.Manager.CheckoutCart(key, lockID, archive); err != nil {
+		return err
+	}
+
+	return m.record(key, []Event{{Key: key, Type: CartCleared}})
+}
+
+// changeItems runs the change and appends the events for the lines that changed
+func (m manager) changeItems(key string, change func() error) error {
+	before, err := m.items(key)
+	if err != nil {
+		return err
+	}
+
+	if err := change(); err != nil {
+		return err
+	}
+
+	after, err := m.items(key)
+	if err != nil {
+		return err
+	}
+
+	return m.record(key, Changes(key, before, after))
+}
+
+// items returns the lines in the cart, or no lines when the cart doesn't exist yet
+func (m manager) items(key string) (datastore.CartItems, error) {
+	items, err := m.Manager.GetItems(key)
+	if errors.Is(err, datastore.ErrCartNotFound) {
+		return datastore.CartItems{}, nil
+	}
+	return items, err
+}
+
+// record appends the events to the log, and stores a snapshot when the events pass
+// a multiple of snapshotEvery.
+func (m manager) record(key string, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	for i := range events {
+		events[i].Key = key
+		events[i].Time = now
+	}
+
+	numbered, err := m.log.Append(key, events)
+	if err != nil {
+		return err
+	}
+
+	first := numbered[0].Sequence
+	last := numbered[len(numbered)-1].Sequence
+	if (first-1)/m.snapshotEvery == last/m.snapshotEvery {
+		return nil
+	}
+
+	// Events of other instances can have a later time than these, because of clock skew,
+	// so the snapshot only uses the sequence numbers.
+	p, err := replay(m.log, key, endOfTime, last)
+	if err != nil {
+		return err
+	}
+
+	return m.log.SaveSnapshot(Snapshot{
+		Key:      key,
+		Sequence: p.Sequence,
+		Items:    p.Items,
+		Time:     numbered[len(numbered)-1].Time,
+	})
+}
@@ -0,0 +1,162 @@
+// Package mongodb keeps the event log of carts in MongoDB, next to the carts of the Cart service.
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/retgits/acme-serverless-cart/internal/eventlog"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// log implements the methods of the Log interface
+type log struct {
+	events    *mongo.Collection
+	snapshots *mongo.Collection
+	sequences *mongo.Collection
+}
+
+// New connects to MongoDB and creates a new event log using it as backend. Unlike the
+// datastore, the connection is made when the log is created, so the package can be used
+// by services that don't keep the event log in MongoDB.
+func New() (eventlog.Log, error) {
+	username := os.Getenv("MONGO_USERNAME")
+	password := os.Getenv("MONGO_PASSWORD")
+	hostname := os.Getenv("MONGO_HOSTNAME")
+	port := os.Getenv("MONGO_PORT")
+
+	connString := fmt.Sprintf("mongodb+srv://%s:%s@%s:%s", username, password, hostname, port)
+	if strings.HasSuffix(connString, ":") {
+		connString = connString[:len(connString)-1]
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(connString))
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to MongoDB: %s", err.Error())
+	}
+
+	db := client.Database("acmeserverless")
+	return log{
+		events:    db.Collection("cartevents"),
+		snapshots: db.Collection("cartsnapshots"),
+		sequences: db.Collection("carteventsequence"),
+	}, nil
+}
+
+// Append reserves sequence numbers for the events and inserts them
+func (l log) Append(key string, events []eventlog.Event) ([]eventlog.Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Reserve the sequence numbers, so events of concurrent changes never overwrite each other
+	res := l.sequences.FindOneAndUpdate(ctx, bson.M{"_id": key}, bson.M{
+		"$inc": bson.M{"Sequence": int64(len(events))},
+	}, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After))
+
+	var seq struct {
+		Sequence int64 `bson:"Sequence"`
+	}
+	if err := res.Decode(&seq); err != nil {
+		return nil, err
+	}
+
+	numbered := make([]eventlog.Event, len(events))
+	docs := make([]interface{}, len(events))
+	for i, e := range events {
+		e.Key = key
+		e.Sequence = seq.Sequence - int64(len(events)) + int64(i) + 1
+		numbered[i] = e
+
+		payload, err := e.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		docs[i] = bson.D{
+			{Key: "SK", Value: key},
+			{Key: "Sequence", Value: e.Sequence},
+			{Key: "Type", Value: e.Type},
+			{Key: "Payload", Value: string(payload)},
+		}
+	}
+
+	if _, err := l.events.InsertMany(ctx, docs); err != nil {
+		return nil, err
+	}
+
+	return numbered, nil
+}
+
+// Events finds the events of the cart after the sequence number
+func (l log) Events(key string, after int64) ([]eventlog.Event, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := l.events.Find(ctx, bson.M{
+		"SK":       key,
+		"Sequence": bson.M{"$gt": after},
+	}, options.Find().SetSort(bson.M{"Sequence": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	events := make([]eventlog.Event, 0)
+	for cursor.Next(ctx) {
+		e, err := eventlog.UnmarshalEvent([]byte(cursor.Current.Lookup("Payload").StringValue()))
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, cursor.Err()
+}
+
+// SaveSnapshot inserts the snapshot
+func (l log) SaveSnapshot(s eventlog.Snapshot) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	payload, err := s.Marshal()
+	if err != nil {
+		return err
+	}
+
+	_, err = l.snapshots.InsertOne(ctx, bson.D{
+		{Key: "SK", Value: s.Key},
+		{Key: "Sequence", Value: s.Sequence},
+		{Key: "Taken", Value: s.Time},
+		{Key: "Payload", Value: string(payload)},
+	})
+
+	return err
+}
+
+// LatestSnapshot finds the snapshot of the cart with the highest sequence number that
+// was taken at or before the time
+func (l log) LatestSnapshot(key string, at time.Time) (eventlog.Snapshot, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res := l.snapshots.FindOne(ctx, bson.M{
+		"SK":    key,
+		"Taken": bson.M{"$lte": at},
+	}, options.FindOne().SetSort(bson.M{"Sequence": -1}))
+
+	raw, err := res.DecodeBytes()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return eventlog.Snapshot{Key: key}, nil
+	}
+	if err != nil {
+		return eventlog.Snapshot{Key: key}, fmt.Errorf("unable to decode bytes: %s", err.Error())
+	}
+
+	return eventlog.UnmarshalSnapshot([]byte(raw.Lookup("Payload").StringValue()))
+}
@@ -0,0 +1,218 @@
+package eventlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+)
+
+// endOfTime is later than any event in the log
+var endOfTime = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// Projection is a cart rebuilt from the event log
+type Projection struct {
+	// Key is the key of the cart
+	Key string `json:"key"`
+
+	// Sequence is the number of the last event in the projection
+	Sequence int64 `json:"sequence"`
+
+	// Snapshot is the number of the event of the snapshot the projection started from,
+	// or 0 when the whole log was replayed
+	Snapshot int64 `json:"snapshot"`
+
+	// Events are the events that were replayed on top of the snapshot
+	Events []Event `json:"events"`
+
+	// Items are the items in the cart
+	Items datastore.CartItems `json:"items"`
+}
+
+// Marshal returns the JSON encoding of Projection
+func (p *Projection) Marshal() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+// Apply returns the items of a cart after the event
+func Apply(items datastore.CartItems, e Event) datastore.CartItems {
+	res := make(datastore.CartItems, 0, len(items)+1)
+
+	switch e.Type {
+	case ItemAdded:
+		res = append(res, items...)
+		if e.Item != nil {
+			res = append(res, *e.Item)
+		}
+	case ItemModified:
+		changed := false
+		for _, item := range items {
+			if !changed && e.Item != nil && item.SameLine(*e.Item) {
+				item = *e.Item
+				changed = true
+			}
+			res = append(res, item)
+		}
+	case ItemRemoved:
+		for _, item := range items {
+			if e.Item != nil && item.SameLine(*e.Item) {
+				continue
+			}
+			res = append(res, item)
+		}
+	case CartCleared:
+	default:
+		res = append(res, items...)
+	}
+
+	return res
+}
+
+// Changes returns the events that turn the items before into the items after. Lines are
+// matched on their identity. When the items can't be described line by line, for example
+// because an item is in the cart twice, the cart is cleared and all items are added again.
+func Changes(key string, before datastore.CartItems, after datastore.CartItems) []Event {
+	if len(after) == 0 {
+		if len(before) == 0 {
+			return nil
+		}
+		return []Event{{Key: key, Type: CartCleared}}
+	}
+
+	events := lineChanges(key, before, after)
+	if events != nil {
+		projected := before
+		for _, e := range events {
+			projected = Apply(projected, e)
+		}
+		if equalItems(projected, after) {
+			return events
+		}
+	}
+
+	events = []Event{{Key: key, Type: CartCleared}}
+	for i := range after {
+		item := after[i]
+		events = append(events, Event{Key: key, Type: ItemAdded, Item: &item})
+	}
+
+	return events
+}
+
+// lineChanges returns the added, changed, and removed lines, or nil if the lines can't be
+// matched on their identity.
+func lineChanges(key string, before datastore.CartItems, after datastore.CartItems) []Event {
+	old, ok := byLineID(before)
+	if !ok {
+		return nil
+	}
+	current, ok := byLineID(after)
+	if !ok {
+		return nil
+	}
+
+	events := make([]Event, 0)
+
+	for i := range before {
+		if _, ok := current[before[i].LineID()]; !ok {
+			item := before[i]
+			events = append(events, Event{Key: key, Type: ItemRemoved, Item: &item})
+		}
+	}
+
+	for i := range after {
+		item := after[i]
+		prev, ok := old[item.LineID()]
+		switch {
+		case !ok:
+			events = append(events, Event{Key: key, Type: ItemAdded, Item: &item})
+		case !equalItems(datastore.CartItems{prev}, datastore.CartItems{item}):
+			events = append(events, Event{Key: key, Type: ItemModified, Item: &item})
+		}
+	}
+
+	return events
+}
+
+// byLineID indexes the items on their identity. It returns false when an item has no
+// itemid or a line is in the cart more than once.
+func byLineID(items datastore.CartItems) (map[string]datastore.CartItem, bool) {
+	res := make(map[string]datastore.CartItem)
+	for _, item := range items {
+		if item.ItemID == nil {
+			return nil, false
+		}
+		if _, ok := res[item.LineID()]; ok {
+			return nil, false
+		}
+		res[item.LineID()] = item
+	}
+	return res, true
+}
+
+// equalItems compares the JSON encoding of two lists of items
+func equalItems(a datastore.CartItems, b datastore.CartItems) bool {
+	x, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	y, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(x, y)
+}
+
+// Replay rebuilds the cart with the key as it was at the time, starting from the latest
+// snapshot taken before then. ErrNoEvents is returned when the log has nothing for the
+// cart before the time.
+func Replay(l Log, key string, at time.Time) (Projection, error) {
+	return replay(l, key, at, math.MaxInt64)
+}
+
+// replay rebuilds the cart with the key up to the event with the sequence number upTo,
+// leaving out events that happened after the time.
+func replay(l Log, key string, at time.Time, upTo int64) (Projection, error) {
+	p := Projection{
+		Key:    key,
+		Events: make([]Event, 0),
+		Items:  make(datastore.CartItems, 0),
+	}
+
+	snapshot, err := l.LatestSnapshot(key, at)
+	if err != nil {
+		return p, err
+	}
+	if snapshot.Sequence > upTo {
+		snapshot = Snapshot{}
+	}
+
+	if snapshot.Sequence > 0 {
+		p.Snapshot = snapshot.Sequence
+		p.Sequence = snapshot.Sequence
+		p.Items = append(p.Items, snapshot.Items...)
+	}
+
+	events, err := l.Events(key, snapshot.Sequence)
+	if err != nil {
+		return p, err
+	}
+
+	for _, e := range events {
+		if e.Sequence > upTo || e.Time.After(at) {
+			break
+		}
+		p.Items = Apply(p.Items, e)
+		p.Sequence = e.Sequence
+		p.Events = append(p.Events, e)
+	}
+
+	if p.Sequence == 0 {
+		return p, fmt.Errorf("%w: cart %s has no events before %s", ErrNoEvents, key, at.Format(time.RFC3339))
+	}
+
+	return p, nil
+}
@@ -0,0 +1,129 @@
+// Package events contains the events that the Cart service sends to the other
+// services in the ACME Serverless Fitness Shop. In order to add a new way to send
+// events, the Publisher interface needs to be implemented.
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	acmeserverless "github.com/retgits/acme-serverless"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+)
+
+const (
+	// CartCheckedOutEventName is the name used for the CartCheckedOut event
+	CartCheckedOutEventName = "CartCheckedOut"
+
+	// CartAbandonedEventName is the name used for the CartAbandoned event
+	CartAbandonedEventName = "CartAbandoned"
+)
+
+// ErrPublishFailed is returned when an event couldn't be sent.
+var ErrPublishFailed = errors.New("unable to publish event")
+
+// Publisher is the interface that describes the methods a
+// message broker needs to implement to send the events
+// of the Cart service.
+type Publisher interface {
+	// Publish sends the JSON-encoded payload of an event with the name.
+	Publish(name string, payload []byte) error
+}
+
+// CartCheckedOutEvent is sent by the Cart service when a user checks out a cart.
+type CartCheckedOutEvent struct {
+	// Metadata for the event.
+	Metadata acmeserverless.Metadata `json:"metadata"`
+
+	// Data contains the payload data for the event.
+	Data CheckoutDetails `json:"data"`
+}
+
+// UnmarshalCartCheckedOutEvent parses the JSON-encoded data and stores the result in a
+// CartCheckedOutEvent.
+func UnmarshalCartCheckedOutEvent(data []byte) (CartCheckedOutEvent, error) {
+	var r CartCheckedOutEvent
+	err := json.Unmarshal(data, &r)
+	return r, err
+}
+
+// Marshal returns the JSON encoding of CartCheckedOutEvent.
+func (e *CartCheckedOutEvent) Marshal() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// CheckoutDetails contains the cart that is checked out.
+type CheckoutDetails struct {
+	// CheckoutID is the unique identifier of the checkout
+	CheckoutID string `json:"checkoutid"`
+
+	// UserID is the unique identifier of the user in the ACME Serverless Fitness Shop
+	UserID string `json:"userid"`
+
+	// Cart is the ID of the cart of the user
+	Cart string `json:"cart"`
+
+	// Items are the items in the cart
+	Items datastore.CartItems `json:"items"`
+
+	// ItemTotal is the number of items in the cart
+	ItemTotal int64 `json:"itemtotal"`
+
+	// Total is the value of the items in the cart
+	Total float64 `json:"total"`
+}
+
+// Marshal returns the JSON encoding of CheckoutDetails.
+func (r *CheckoutDetails) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// CartAbandonedEvent is sent by the Cart service when a cart with items hasn't been
+// changed for a while, so the user can be reminded of it.
+type CartAbandonedEvent struct {
+	// Metadata for the event.
+	Metadata acmeserverless.Metadata `json:"metadata"`
+
+	// Data contains the payload data for the event.
+	Data AbandonedCart `json:"data"`
+}
+
+// UnmarshalCartAbandonedEvent parses the JSON-encoded data and stores the result in a
+// CartAbandonedEvent.
+func UnmarshalCartAbandonedEvent(data []byte) (CartAbandonedEvent, error) {
+	var r CartAbandonedEvent
+	err := json.Unmarshal(data, &r)
+	return r, err
+}
+
+// Marshal returns the JSON encoding of CartAbandonedEvent.
+func (e *CartAbandonedEvent) Marshal() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// AbandonedCart contains the cart that is abandoned.
+type AbandonedCart struct {
+	// UserID is the unique identifier of the user in the ACME Serverless Fitness Shop
+	UserID string `json:"userid"`
+
+	// Cart is the ID of the cart of the user
+	Cart string `json:"cart"`
+
+	// Items are the items in the cart
+	Items datastore.CartItems `json:"items"`
+
+	// ItemTotal is the number of items in the cart
+	ItemTotal int64 `json:"itemtotal"`
+
+	// Total is the value of the items in the cart
+	Total float64 `json:"total"`
+
+	// UpdatedAt is when the cart was last changed
+	UpdatedAt time.Time `json:"updatedat"`
+}
+
+// Marshal returns the JSON encoding of AbandonedCart.
+func (r *AbandonedCart) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
@@ -0,0 +1,59 @@
+// Package eventbridge sends the events of the Cart service to Amazon EventBridge, a
+// serverless event bus that makes it easy to connect applications together.
+package eventbridge
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/retgits/acme-serverless-cart/internal/events"
+)
+
+// source is the source of all events the Cart service sends
+const source = "acmeserverless.cart"
+
+// publisher implements the methods of the Publisher interface
+// using an Amazon EventBridge event bus.
+type publisher struct {
+	eventBus string
+	svc      *eventbridge.EventBridge
+}
+
+// New creates a new Publisher that sends events to the event bus in the region
+func New(region string, eventBus string) events.Publisher {
+	awsSession := session.Must(session.NewSession(&aws.Config{
+		Region: aws.String(region),
+	}))
+
+	return publisher{
+		eventBus: eventBus,
+		svc:      eventbridge.New(awsSession),
+	}
+}
+
+// Publish sends the event to the event bus, with the name of the event as detail type
+func (p publisher) Publish(name string, payload []byte) error {
+	pei := &eventbridge.PutEventsInput{
+		Entries: []*eventbridge.PutEventsRequestEntry{
+			{
+				Detail:       aws.String(string(payload)),
+				DetailType:   aws.String(name),
+				EventBusName: aws.String(p.eventBus),
+				Source:       aws.String(source),
+			},
+		},
+	}
+
+	peo, err := p.svc.PutEvents(pei)
+	if err != nil {
+		return fmt.Errorf("%w: %s", events.ErrPublishFailed, err.Error())
+	}
+
+	if aws.Int64Value(peo.FailedEntryCount) > 0 {
+		return fmt.Errorf("%w: %s", events.ErrPublishFailed, aws.StringValue(peo.Entries[0].ErrorMessage))
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+// Package memory keeps the events that the Cart service sends in memory. It is meant for
+// testing and for running the Cart service without a message broker.
+package memory
+
+import (
+	"sync"
+)
+
+// Message is an event that was published
+type Message struct {
+	// Name is the name of the event
+	Name string
+
+	// Payload is the JSON-encoded event
+	Payload []byte
+}
+
+// Publisher keeps all published events in memory.
+type Publisher struct {
+	mu       sync.Mutex
+	messages []Message
+	err      error
+}
+
+// New creates a new in-memory publisher
+func New() *Publisher {
+	return &Publisher{
+		messages: make([]Message, 0),
+	}
+}
+
+// Publish stores the event, or returns the error set with SetError
+func (p *Publisher) Publish(name string, payload []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err != nil {
+		return p.err
+	}
+
+	p.messages = append(p.messages, Message{
+		Name:    name,
+		Payload: payload,
+	})
+
+	return nil
+}
+
+// Messages returns the events that have been published
+func (p *Publisher) Messages() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	messages := make([]Message, len(p.messages))
+	copy(messages, p.messages)
+	return messages
+}
+
+// SetError makes all calls to Publish fail with the error, until it is set to nil
+func (p *Publisher) SetError(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.err = err
+}
@@ -0,0 +1,44 @@
+// Package nats sends the events of the Cart service to NATS, a simple, secure and
+// high performance open source messaging system.
+package nats
+
+import (
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+	"github.com/retgits/acme-serverless-cart/internal/events"
+)
+
+// publisher implements the methods of the Publisher interface
+// using a connection to a NATS server.
+type publisher struct {
+	conn    *nats.Conn
+	subject string
+}
+
+// New creates a new Publisher that connects to the NATS server at url. Events are
+// published on the subject followed by the name of the event, like acmeserverless.cart.CartCheckedOut.
+func New(url string, subject string) (events.Publisher, error) {
+	conn, err := nats.Connect(url, nats.Name("acmeserverless-cart"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to NATS: %s", err.Error())
+	}
+
+	return publisher{
+		conn:    conn,
+		subject: subject,
+	}, nil
+}
+
+// Publish sends the event and waits until the server has received it
+func (p publisher) Publish(name string, payload []byte) error {
+	if err := p.conn.Publish(fmt.Sprintf("%s.%s", p.subject, name), payload); err != nil {
+		return fmt.Errorf("%w: %s", events.ErrPublishFailed, err.Error())
+	}
+
+	if err := p.conn.Flush(); err != nil {
+		return fmt.Errorf("%w: %s", events.ErrPublishFailed, err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+// Package publishers creates the Publisher that the Cart service uses to send events,
+// based on environment variables.
+package publishers
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/retgits/acme-serverless-cart/internal/events"
+	"github.com/retgits/acme-serverless-cart/internal/events/eventbridge"
+	"github.com/retgits/acme-serverless-cart/internal/events/memory"
+	"github.com/retgits/acme-serverless-cart/internal/events/nats"
+	"github.com/retgits/acme-serverless-cart/internal/events/sqs"
+)
+
+// FromEnv creates the Publisher selected by EVENT_PUBLISHER, which is one of sqs (using
+// EVENT_QUEUE_URL), eventbridge (using EVENT_BUS), nats (using NATS_URL and NATS_SUBJECT),
+// or memory. When EVENT_PUBLISHER isn't set, events are only kept in memory when STAGE is
+// dev, so a missing setting doesn't lose the events of a deployed service.
+func FromEnv() (events.Publisher, error) {
+	switch kind := os.Getenv("EVENT_PUBLISHER"); kind {
+	case "sqs":
+		if len(os.Getenv("EVENT_QUEUE_URL")) == 0 {
+			return nil, fmt.Errorf("EVENT_QUEUE_URL must be set to publish events to SQS")
+		}
+		return sqs.New(os.Getenv("REGION"), os.Getenv("EVENT_QUEUE_URL")), nil
+	case "eventbridge":
+		if len(os.Getenv("EVENT_BUS")) == 0 {
+			return nil, fmt.Errorf("EVENT_BUS must be set to publish events to EventBridge")
+		}
+		return eventbridge.New(os.Getenv("REGION"), os.Getenv("EVENT_BUS")), nil
+	case "nats":
+		subject := os.Getenv("NATS_SUBJECT")
+		if len(subject) == 0 {
+			subject = "acmeserverless.cart"
+		}
+		return nats.New(os.Getenv("NATS_URL"), subject)
+	case "":
+		if os.Getenv("STAGE") != "dev" {
+			return nil, fmt.Errorf("EVENT_PUBLISHER must be set, unless STAGE is dev")
+		}
+		return memory.New(), nil
+	case "memory":
+		return memory.New(), nil
+	default:
+		return nil, fmt.Errorf("unknown EVENT_PUBLISHER %s", kind)
+	}
+}
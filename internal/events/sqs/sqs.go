@@ -0,0 +1,53 @@
+// Package sqs sends the events of the Cart service to an Amazon Simple Queue Service
+// (SQS) queue, a fully managed message queuing service.
+package sqs
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/retgits/acme-serverless-cart/internal/events"
+)
+
+// publisher implements the methods of the Publisher interface
+// using an Amazon SQS queue.
+type publisher struct {
+	queueURL string
+	svc      *sqs.SQS
+}
+
+// New creates a new Publisher that sends events to the SQS queue at queueURL in the region
+func New(region string, queueURL string) events.Publisher {
+	awsSession := session.Must(session.NewSession(&aws.Config{
+		Region: aws.String(region),
+	}))
+
+	return publisher{
+		queueURL: queueURL,
+		svc:      sqs.New(awsSession),
+	}
+}
+
+// Publish sends the event as a message to the queue. The name of the event is
+// added as the EventName message attribute.
+func (p publisher) Publish(name string, payload []byte) error {
+	smi := &sqs.SendMessageInput{
+		QueueUrl:    aws.String(p.queueURL),
+		MessageBody: aws.String(string(payload)),
+		MessageAttributes: map[string]*sqs.MessageAttributeValue{
+			"EventName": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String(name),
+			},
+		},
+	}
+
+	_, err := p.svc.SendMessage(smi)
+	if err != nil {
+		return fmt.Errorf("%w: %s", events.ErrPublishFailed, err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+// Package idempotency makes sure that requests with an Idempotency-Key header are only
+// handled once. The response to the first request is stored in the datastore, and returned
+// again when the request is retried with the same key, until the key expires.
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+)
+
+const (
+	// Header is the header with the idempotency key of a request
+	Header = "Idempotency-Key"
+
+	// ReplayedHeader is set on responses that are returned again for a retried request
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+var (
+	// ErrKeyReused is returned when an idempotency key is used for a different request
+	ErrKeyReused = errors.New("idempotency key is used for a different request")
+
+	// ErrInProgress is returned when the first request with an idempotency key isn't done yet
+	ErrInProgress = errors.New("request with the idempotency key is in progress")
+)
+
+// DefaultTTL is how long idempotency keys are kept when IDEMPOTENCY_TTL isn't set
+const DefaultTTL = time.Hour * 24
+
+// TTLFromEnv returns how long idempotency keys are kept from IDEMPOTENCY_TTL, or DefaultTTL
+// when it isn't set.
+func TTLFromEnv() (time.Duration, error) {
+	if len(os.Getenv("IDEMPOTENCY_TTL")) == 0 {
+		return DefaultTTL, nil
+	}
+
+	d, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_TTL"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid IDEMPOTENCY_TTL: %s", err.Error())
+	}
+
+	return d, nil
+}
+
+// Applies returns true for requests with the method that can be made with an idempotency key
+func Applies(method string) bool {
+	return method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch
+}
+
+// Key returns the key under which a request with the idempotency key is stored. Keys are
+// scoped to the user making the request, so users can't see each others responses. Requests
+// that aren't made by a user, like creating a guest cart without authentication, are scoped
+// to the IP address of the client instead.
+func Key(caller string, clientIP string, idempotencyKey string) string {
+	if len(caller) == 0 {
+		return "ip:" + clientIP + "#" + idempotencyKey
+	}
+	return caller + "#" + idempotencyKey
+}
+
+// Fingerprint returns a hash of a request, so a retried request can be told apart from a
+// different request with the same idempotency key.
+func Fingerprint(method string, path string, query string, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s?%s\n", method, path, query)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Response is the response to a request made with an idempotency key
+type Response struct {
+	// StatusCode is the HTTP status code of the response
+	StatusCode int
+
+	// ContentType is the content type of the response
+	ContentType string
+
+	// Body is the body of the response
+	Body string
+}
+
+// Begin claims the key for a request. When the request was made before with the same key,
+// the response to that request is returned and the request shouldn't be handled again.
+// ErrKeyReused is returned when the key was used for another request, and ErrInProgress
+// when the first request with the key isn't done yet.
+func Begin(db datastore.Manager, key string, fingerprint string, ttl time.Duration) (*Response, error) {
+	r := datastore.IdempotentRequest{
+		Key:         key,
+		Fingerprint: fingerprint,
+		Expires:     time.Now().Add(ttl),
+	}
+
+	stored, err := db.ClaimIdempotencyKey(r)
+	if !errors.Is(err, datastore.ErrIdempotencyKeyUsed) {
+		return nil, err
+	}
+
+	switch {
+	case stored.Fingerprint != fingerprint:
+		return nil, fmt.Errorf("%w: %s", ErrKeyReused, err.Error())
+	case !stored.Done:
+		return nil, fmt.Errorf("%w: %s", ErrInProgress, err.Error())
+	default:
+		return &Response{
+			StatusCode:  stored.StatusCode,
+			ContentType: stored.ContentType,
+			Body:        stored.Body,
+		}, nil
+	}
+}
+
+// End stores the response to a request for which the key was claimed with Begin. Only
+// successful responses are stored, for other responses the key is released so the
+// request can be retried.
+func End(db datastore.Manager, key string, res Response) error {
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return db.ReleaseIdempotencyKey(key)
+	}
+
+	return db.CompleteIdempotencyKey(datastore.IdempotentRequest{
+		Key:         key,
+		StatusCode:  res.StatusCode,
+		ContentType: res.ContentType,
+		Body:        res.Body,
+	})
+}
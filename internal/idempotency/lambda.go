@@ -0,0 +1,81 @@
+package idempotency
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+)
+
+// Handler is the handler of a Lambda function behind API Gateway
+type Handler func(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// ErrorHandler returns the API Gateway Proxy Response for an error that occured in the area
+type ErrorHandler func(area string, headers map[string]string, err error) (events.APIGatewayProxyResponse, error)
+
+// Lambda wraps the handler of a Lambda function, so requests with an Idempotency-Key
+// header are only handled once. Keys are kept for IDEMPOTENCY_TTL. Errors while checking
+// the key are returned with handleError.
+func Lambda(db datastore.Manager, h Handler, handleError ErrorHandler) Handler {
+	return func(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		idempotencyKey, _ := cart.LookupHeader(request.Headers, Header)
+		if len(idempotencyKey) == 0 || !Applies(request.HTTPMethod) {
+			return h(request)
+		}
+
+		headers := map[string]string{
+			"Access-Control-Allow-Origin": "*",
+			cart.RequestIDHeader:          request.RequestContext.RequestID,
+		}
+
+		ttl, err := TTLFromEnv()
+		if err != nil {
+			return handleError("checking idempotency key", headers, err)
+		}
+
+		query := make(url.Values)
+		for k, v := range request.QueryStringParameters {
+			query.Set(k, v)
+		}
+
+		key := Key(cart.Caller(request.Headers, request.PathParameters["userid"]), request.RequestContext.Identity.SourceIP, idempotencyKey)
+		fingerprint := Fingerprint(request.HTTPMethod, request.Path, query.Encode(), []byte(request.Body))
+
+		res, err := Begin(db, key, fingerprint, ttl)
+		if err != nil {
+			return handleError("checking idempotency key", headers, err)
+		}
+
+		// The request was made before, so the response is returned again
+		if res != nil {
+			headers["Content-Type"] = res.ContentType
+			headers[ReplayedHeader] = "true"
+			return events.APIGatewayProxyResponse{
+				StatusCode: res.StatusCode,
+				Body:       res.Body,
+				Headers:    headers,
+			}, nil
+		}
+
+		response, err := h(request)
+		if err != nil {
+			response.StatusCode = http.StatusInternalServerError
+		}
+
+		// The response was made already, so failing to store it only means a retry is handled again
+		contentType, _ := cart.LookupHeader(response.Headers, "Content-Type")
+		endErr := End(db, key, Response{
+			StatusCode:  response.StatusCode,
+			ContentType: contentType,
+			Body:        response.Body,
+		})
+		if endErr != nil {
+			log.Printf("error storing response for idempotency key %s: %s", idempotencyKey, endErr.Error())
+		}
+
+		return response, err
+	}
+}
@@ -0,0 +1,108 @@
+// Package inventory contains the interfaces that the Cart service
+// in the ACME Serverless Fitness Shop needs to check and reserve
+// stock for the items that users put in their cart. Reservations
+// are soft holds that expire when the cart isn't touched for a while.
+// In order to add a new inventory source, the Inventory interface
+// needs to be implemented.
+package inventory
+
+import (
+	"errors"
+
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+)
+
+// ErrInsufficientStock is returned by an Inventory when there isn't
+// enough stock available to place a hold.
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// Inventory is the interface that describes the methods the
+// inventory needs to implement to be able to work with the
+// Cart service of the ACME Serverless Fitness Shop.
+type Inventory interface {
+	// Reserve sets the hold of the user for an item to quantity and
+	// refreshes the expiry of the hold. It returns ErrInsufficientStock
+	// when not enough items are available.
+	Reserve(userID string, itemID string, quantity int64) error
+
+	// Release removes the hold of the user for an item.
+	Release(userID string, itemID string) error
+}
+
+// Sync updates the holds of a user so they match the items in the cart after a change.
+// Holds for all items in after are placed (which refreshes their expiry) and holds for
+// items that are no longer in the cart are released. When a hold can't be placed, the
+// holds that were already changed are rolled back to match before.
+func Sync(inv Inventory, userID string, before datastore.CartItems, after datastore.CartItems) error {
+	oldQuantities := quantities(before)
+	newQuantities := quantities(after)
+
+	changed := make([]string, 0, len(newQuantities))
+
+	for itemID, quantity := range newQuantities {
+		if err := inv.Reserve(userID, itemID, quantity); err != nil {
+			rollback(inv, userID, changed, oldQuantities)
+			return err
+		}
+		changed = append(changed, itemID)
+	}
+
+	for itemID := range oldQuantities {
+		if _, ok := newQuantities[itemID]; ok {
+			continue
+		}
+		if err := inv.Release(userID, itemID); err != nil {
+			rollback(inv, userID, changed, oldQuantities)
+			return err
+		}
+		changed = append(changed, itemID)
+	}
+
+	return nil
+}
+
+// Revert restores the holds of a user after Sync, when the change to the cart couldn't be
+// stored. The holds match the items in before again. Errors are ignored since the holds
+// expire anyway.
+func Revert(inv Inventory, userID string, before datastore.CartItems, after datastore.CartItems) {
+	oldQuantities := quantities(before)
+
+	itemIDs := make([]string, 0, len(oldQuantities))
+	for itemID := range oldQuantities {
+		itemIDs = append(itemIDs, itemID)
+	}
+	for itemID := range quantities(after) {
+		if _, ok := oldQuantities[itemID]; !ok {
+			itemIDs = append(itemIDs, itemID)
+		}
+	}
+
+	rollback(inv, userID, itemIDs, oldQuantities)
+}
+
+// rollback restores the holds for the items to the given quantities. Errors are
+// ignored since the holds expire anyway.
+func rollback(inv Inventory, userID string, itemIDs []string, quantities map[string]int64) {
+	for _, itemID := range itemIDs {
+		if quantity, ok := quantities[itemID]; ok {
+			inv.Reserve(userID, itemID, quantity)
+			continue
+		}
+		inv.Release(userID, itemID)
+	}
+}
+
+// quantities returns the total quantity per itemid, with bundles counted by their
+// components. Items without an itemid or a quantity that isn't positive are skipped.
+func quantities(items datastore.CartItems) map[string]int64 {
+	q := make(map[string]int64)
+
+	for _, item := range datastore.Components(items) {
+		if item.ItemID == nil || item.Quantity <= 0 {
+			continue
+		}
+		q[*item.ItemID] = q[*item.ItemID] + item.Quantity
+	}
+
+	return q
+}
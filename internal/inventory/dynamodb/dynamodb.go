@@ -0,0 +1,231 @@
+// Package dynamodb keeps stock levels and holds in an Amazon DynamoDB table, so all instances
+// of the Cart service, including the Lambda functions, share the same holds.
+package dynamodb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/retgits/acme-serverless-cart/internal/inventory"
+)
+
+const (
+	// The stock of an item is stored for the access pattern PK = INVENTORY#ITEMID SK = STOCK
+	stockPrefix = "INVENTORY#"
+
+	// attempts is how many times the holds of an item are read and written before giving
+	// up, when other instances change the holds at the same time
+	attempts = 3
+)
+
+// hold is a soft reservation of a number of items that expires at a certain time
+type hold struct {
+	Quantity int64     `json:"quantity"`
+	Expires  time.Time `json:"expires"`
+}
+
+// stock is the stock level of an item and the holds on it
+type stock struct {
+	quantity int64
+	holds    map[string]hold
+	version  int64
+}
+
+// Inventory implements the methods of the Inventory interface using a DynamoDB table
+type Inventory struct {
+	dbs   *dynamodb.DynamoDB
+	table string
+	ttl   time.Duration
+}
+
+// New creates an Inventory that keeps the holds in the DynamoDB table. Holds expire when
+// they haven't been refreshed for the duration of ttl. The stock level of an item is the
+// Stock attribute of the item with the PK INVENTORY#<itemid> and the SK STOCK. Items that
+// have no stock level set are not limited. If the environment variable DYNAMO_URL is set,
+// the connection is made to that URL instead of relying on the AWS SDK to provide the URL.
+func New(table string, ttl time.Duration) *Inventory {
+	awsSession := session.Must(session.NewSession(&aws.Config{
+		Region: aws.String(os.Getenv("REGION")),
+	}))
+
+	if len(os.Getenv("DYNAMO_URL")) > 0 {
+		awsSession.Config.Endpoint = aws.String(os.Getenv("DYNAMO_URL"))
+	}
+
+	return &Inventory{
+		dbs:   dynamodb.New(awsSession),
+		table: table,
+		ttl:   ttl,
+	}
+}
+
+// Reserve sets the hold of the user for an item to quantity. The holds are only written
+// when they didn't change since they were read, so instances can't reserve the same stock.
+func (i *Inventory) Reserve(userID string, itemID string, quantity int64) error {
+	for n := 0; n < attempts; n++ {
+		s, found, err := i.get(itemID)
+		if err != nil {
+			return err
+		}
+
+		// Items without a stock level are not limited, so there is nothing to hold
+		if !found {
+			return nil
+		}
+
+		now := time.Now()
+		available := s.quantity
+		for holder, h := range s.holds {
+			if now.After(h.Expires) {
+				delete(s.holds, holder)
+				continue
+			}
+			if holder != userID {
+				available = available - h.Quantity
+			}
+		}
+
+		if quantity > available {
+			return fmt.Errorf("%w: %d of item %s requested, %d available", inventory.ErrInsufficientStock, quantity, itemID, available)
+		}
+
+		s.holds[userID] = hold{
+			Quantity: quantity,
+			Expires:  now.Add(i.ttl),
+		}
+
+		err = i.put(itemID, s)
+		if isConditionalCheckFailed(err) {
+			continue
+		}
+
+		return err
+	}
+
+	return fmt.Errorf("holds of item %s are changed too often to reserve stock", itemID)
+}
+
+// Release removes the hold of the user for an item
+func (i *Inventory) Release(userID string, itemID string) error {
+	for n := 0; n < attempts; n++ {
+		s, found, err := i.get(itemID)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := s.holds[userID]; !found || !ok {
+			return nil
+		}
+		delete(s.holds, userID)
+
+		err = i.put(itemID, s)
+		if isConditionalCheckFailed(err) {
+			continue
+		}
+
+		return err
+	}
+
+	return fmt.Errorf("holds of item %s are changed too often to release stock", itemID)
+}
+
+// get returns the stock level and holds of an item, and false when the item has no stock level
+func (i *Inventory) get(itemID string) (stock, bool, error) {
+	gio, err := i.dbs.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(i.table),
+		Key:            stockKey(itemID),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return stock{}, false, err
+	}
+
+	if gio.Item == nil || gio.Item["Stock"] == nil || gio.Item["Stock"].N == nil {
+		return stock{}, false, nil
+	}
+
+	s := stock{
+		holds: make(map[string]hold),
+	}
+
+	s.quantity, err = strconv.ParseInt(*gio.Item["Stock"].N, 10, 64)
+	if err != nil {
+		return s, false, err
+	}
+
+	if v := gio.Item["Version"]; v != nil && v.N != nil {
+		s.version, err = strconv.ParseInt(*v.N, 10, 64)
+		if err != nil {
+			return s, false, err
+		}
+	}
+
+	if v := gio.Item["Holds"]; v != nil && v.S != nil {
+		if err := json.Unmarshal([]byte(*v.S), &s.holds); err != nil {
+			return s, false, err
+		}
+	}
+
+	return s, true, nil
+}
+
+// put stores the holds of an item as the next version, as long as the holds are still at the
+// version that was read. The stock level isn't changed, so it can be updated at any time.
+func (i *Inventory) put(itemID string, s stock) error {
+	payload, err := json.Marshal(s.holds)
+	if err != nil {
+		return err
+	}
+
+	em := make(map[string]*dynamodb.AttributeValue)
+	em[":holds"] = &dynamodb.AttributeValue{
+		S: aws.String(string(payload)),
+	}
+	em[":next"] = &dynamodb.AttributeValue{
+		N: aws.String(strconv.FormatInt(s.version+1, 10)),
+	}
+
+	condition := "attribute_not_exists(Version)"
+	if s.version > 0 {
+		em[":version"] = &dynamodb.AttributeValue{
+			N: aws.String(strconv.FormatInt(s.version, 10)),
+		}
+		condition = "Version = :version"
+	}
+
+	_, err = i.dbs.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 aws.String(i.table),
+		Key:                       stockKey(itemID),
+		ExpressionAttributeValues: em,
+		UpdateExpression:          aws.String("SET Holds = :holds, Version = :next"),
+		ConditionExpression:       aws.String("attribute_exists(Stock) AND " + condition),
+	})
+
+	return err
+}
+
+// stockKey returns the table keys of the stock of an item
+func stockKey(itemID string) map[string]*dynamodb.AttributeValue {
+	km := make(map[string]*dynamodb.AttributeValue)
+	km["PK"] = &dynamodb.AttributeValue{
+		S: aws.String(stockPrefix + itemID),
+	}
+	km["SK"] = &dynamodb.AttributeValue{
+		S: aws.String("STOCK"),
+	}
+	return km
+}
+
+// isConditionalCheckFailed returns true if a write failed because of its condition expression
+func isConditionalCheckFailed(err error) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+}
@@ -0,0 +1,118 @@
+// Package memory keeps stock levels and holds in memory. It is meant for tests and for
+// running a single instance of the Cart service, since holds are not shared between instances.
+package memory
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/retgits/acme-serverless-cart/internal/inventory"
+)
+
+// hold is a soft reservation of a number of items that expires at a certain time.
+type hold struct {
+	quantity int64
+	expires  time.Time
+}
+
+// Inventory implements the methods of the Inventory interface using maps of
+// stock levels and holds. Items that have no stock level set are not limited.
+type Inventory struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	stock map[string]int64
+
+	// holds contains the holds per itemID and userID
+	holds map[string]map[string]hold
+}
+
+// New creates a new in-memory Inventory with the given stock levels. Holds expire
+// when they haven't been refreshed for the duration of ttl.
+func New(stock map[string]int64, ttl time.Duration) *Inventory {
+	inv := &Inventory{
+		ttl:   ttl,
+		stock: make(map[string]int64),
+		holds: make(map[string]map[string]hold),
+	}
+
+	for itemID, quantity := range stock {
+		inv.stock[itemID] = quantity
+	}
+
+	return inv
+}
+
+// SetStock sets the number of items in stock for an item
+func (i *Inventory) SetStock(itemID string, quantity int64) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.stock[itemID] = quantity
+}
+
+// Available returns the number of items that can still be reserved, or -1 if the
+// item is not limited.
+func (i *Inventory) Available(itemID string) int64 {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if _, ok := i.stock[itemID]; !ok {
+		return -1
+	}
+
+	return i.available(itemID, "")
+}
+
+// Reserve sets the hold of the user for an item to quantity
+func (i *Inventory) Reserve(userID string, itemID string, quantity int64) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if _, ok := i.stock[itemID]; ok {
+		if available := i.available(itemID, userID); quantity > available {
+			return fmt.Errorf("%w: %d of item %s requested, %d available", inventory.ErrInsufficientStock, quantity, itemID, available)
+		}
+	}
+
+	if _, ok := i.holds[itemID]; !ok {
+		i.holds[itemID] = make(map[string]hold)
+	}
+
+	i.holds[itemID][userID] = hold{
+		quantity: quantity,
+		expires:  time.Now().Add(i.ttl),
+	}
+
+	return nil
+}
+
+// Release removes the hold of the user for an item
+func (i *Inventory) Release(userID string, itemID string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	delete(i.holds[itemID], userID)
+
+	return nil
+}
+
+// available returns the stock of an item minus all holds that haven't expired yet,
+// ignoring the hold of the given user. Expired holds are removed. It must be called
+// while holding the lock.
+func (i *Inventory) available(itemID string, userID string) int64 {
+	available := i.stock[itemID]
+	now := time.Now()
+
+	for holder, h := range i.holds[itemID] {
+		if now.After(h.expires) {
+			delete(i.holds[itemID], holder)
+			continue
+		}
+		if holder != userID {
+			available = available - h.quantity
+		}
+	}
+
+	return available
+}
@@ -0,0 +1,66 @@
+// Package sources creates the Inventory that the Cart service reserves stock in, based on
+// environment variables.
+package sources
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/retgits/acme-serverless-cart/internal/inventory"
+	"github.com/retgits/acme-serverless-cart/internal/inventory/dynamodb"
+	"github.com/retgits/acme-serverless-cart/internal/inventory/memory"
+)
+
+// FromEnv creates the Inventory selected by INVENTORY_BACKEND, which is either memory (with
+// the stock levels from the JSON file at INVENTORY_FILE) or dynamodb (with the stock levels
+// and holds in the table INVENTORY_TABLE). When INVENTORY_BACKEND isn't set, the memory
+// inventory is used if INVENTORY_FILE is set. Holds expire after INVENTORY_HOLD_TTL, which
+// is 30 minutes when it isn't set. When no inventory is configured, nil is returned.
+func FromEnv() (inventory.Inventory, error) {
+	ttl := time.Minute * 30
+	if len(os.Getenv("INVENTORY_HOLD_TTL")) > 0 {
+		d, err := time.ParseDuration(os.Getenv("INVENTORY_HOLD_TTL"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid INVENTORY_HOLD_TTL: %s", err.Error())
+		}
+		ttl = d
+	}
+
+	switch backend := os.Getenv("INVENTORY_BACKEND"); backend {
+	case "":
+		if len(os.Getenv("INVENTORY_FILE")) == 0 {
+			return nil, nil
+		}
+		return fromFile(os.Getenv("INVENTORY_FILE"), ttl)
+	case "memory":
+		if len(os.Getenv("INVENTORY_FILE")) == 0 {
+			return nil, fmt.Errorf("INVENTORY_FILE must be set to keep the inventory in memory")
+		}
+		return fromFile(os.Getenv("INVENTORY_FILE"), ttl)
+	case "dynamodb":
+		if len(os.Getenv("INVENTORY_TABLE")) == 0 {
+			return nil, fmt.Errorf("INVENTORY_TABLE must be set to keep the inventory in DynamoDB")
+		}
+		return dynamodb.New(os.Getenv("INVENTORY_TABLE"), ttl), nil
+	default:
+		return nil, fmt.Errorf("unknown INVENTORY_BACKEND %s", backend)
+	}
+}
+
+// fromFile creates an in-memory inventory with the stock levels from a JSON file
+func fromFile(filename string, ttl time.Duration) (inventory.Inventory, error) {
+	bytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	stock := make(map[string]int64)
+	if err := json.Unmarshal(bytes, &stock); err != nil {
+		return nil, fmt.Errorf("unable to read stock levels from %s: %s", filename, err.Error())
+	}
+
+	return memory.New(stock, ttl), nil
+}
@@ -0,0 +1,34 @@
+//go:build ignore
+// +build ignore
+
+// gen writes api/openapi.json to spec.go, so the specification is built into the service
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+)
+
+func main() {
+	data, err := ioutil.ReadFile("../../api/openapi.json")
+	if err != nil {
+		log.Fatalf("error reading OpenAPI specification: %s", err.Error())
+	}
+
+	if bytes.Contains(data, []byte("`")) {
+		log.Fatal("the OpenAPI specification can't contain backticks")
+	}
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by go generate; DO NOT EDIT.\n\n")
+	b.WriteString("package openapi\n\n")
+	b.WriteString("// specJSON is the OpenAPI specification in api/openapi.json\n")
+	b.WriteString("const specJSON = `")
+	b.Write(data)
+	b.WriteString("`\n")
+
+	if err := ioutil.WriteFile("spec.go", b.Bytes(), 0644); err != nil {
+		log.Fatalf("error writing spec.go: %s", err.Error())
+	}
+}
@@ -0,0 +1,44 @@
+package openapi
+
+import (
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+)
+
+// Handler is the handler of a Lambda function behind API Gateway
+type Handler = func(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// ErrorHandler returns the API Gateway Proxy Response for an error that occured in the area
+type ErrorHandler = func(area string, headers map[string]string, err error) (events.APIGatewayProxyResponse, error)
+
+// Lambda wraps the handler of a Lambda function, so requests are validated against the
+// specification before they are handled. Requests that aren't valid are returned with
+// handleError.
+func Lambda(h Handler, handleError ErrorHandler) Handler {
+	spec, err := Load()
+
+	return func(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		headers := map[string]string{
+			"Access-Control-Allow-Origin": "*",
+			cart.RequestIDHeader:          request.RequestContext.RequestID,
+		}
+
+		if err != nil {
+			return handleError("loading OpenAPI specification", headers, err)
+		}
+
+		verr := spec.Validate(Request{
+			Method:  request.HTTPMethod,
+			Path:    request.Path,
+			Route:   request.Resource,
+			Query:   request.QueryStringParameters,
+			Headers: request.Headers,
+			Body:    []byte(request.Body),
+		})
+		if verr != nil {
+			return handleError("validating request", headers, verr)
+		}
+
+		return h(request)
+	}
+}
@@ -0,0 +1,215 @@
+// Package openapi validates requests against the OpenAPI specification of the Cart service
+// in api/openapi.json, so requests with path parameters, query parameters, headers, or
+// JSON bodies that don't match the specification are rejected before they are handled.
+package openapi
+
+//go:generate go run gen.go
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// methods are the HTTP methods that can have an operation in a path of the specification
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Spec is the part of an OpenAPI specification that is needed to validate requests
+type Spec struct {
+	// paths are the operations by path template and HTTP method
+	paths map[string]map[string]*Operation
+
+	// schemas are the schemas in the components of the specification, by name
+	schemas map[string]*Schema
+}
+
+// Operation is a single operation on a path
+type Operation struct {
+	// Parameters are the path, query, and header parameters of the operation
+	Parameters []Parameter `json:"parameters"`
+
+	// RequestBody describes the body of a request, if the operation has one
+	RequestBody *RequestBody `json:"requestBody"`
+}
+
+// Parameter is a parameter of an operation
+type Parameter struct {
+	// Name is the name of the parameter
+	Name string `json:"name"`
+
+	// In is where the parameter is sent, either path, query, or header
+	In string `json:"in"`
+
+	// Required is true when the parameter must be sent
+	Required bool `json:"required"`
+
+	// Schema describes the value of the parameter
+	Schema *Schema `json:"schema"`
+}
+
+// RequestBody describes the body of a request
+type RequestBody struct {
+	// Required is true when a request must have a body
+	Required bool `json:"required"`
+
+	// Content are the schemas of the body, by media type
+	Content map[string]MediaType `json:"content"`
+}
+
+// MediaType describes a body with a media type
+type MediaType struct {
+	// Schema describes the body
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is the subset of JSON Schema that the specification uses. The exported fields
+// are the keywords with the same name.
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Nullable             bool               `json:"nullable"`
+	Enum                 []interface{}      `json:"enum"`
+	AllOf                []*Schema          `json:"allOf"`
+	Required             []string           `json:"required"`
+	Properties           map[string]*Schema `json:"properties"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+	MaxProperties        *int               `json:"maxProperties"`
+	Items                *Schema            `json:"items"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Pattern              string             `json:"pattern"`
+
+	// pattern is the compiled Pattern
+	pattern *regexp.Regexp
+
+	// additional is the schema of additional properties, and noAdditional is true when
+	// additional properties aren't allowed
+	additional   *Schema
+	noAdditional bool
+}
+
+// Parse reads an OpenAPI specification
+func Parse(data []byte) (*Spec, error) {
+	var doc struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]*Schema `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error parsing OpenAPI specification: %s", err.Error())
+	}
+
+	s := &Spec{
+		paths:   make(map[string]map[string]*Operation, len(doc.Paths)),
+		schemas: doc.Components.Schemas,
+	}
+
+	for path, item := range doc.Paths {
+		s.paths[path] = make(map[string]*Operation)
+		for _, method := range methods {
+			raw, ok := item[method]
+			if !ok {
+				continue
+			}
+			var o Operation
+			if err := json.Unmarshal(raw, &o); err != nil {
+				return nil, fmt.Errorf("error parsing %s %s: %s", strings.ToUpper(method), path, err.Error())
+			}
+			s.paths[path][method] = &o
+		}
+	}
+
+	// Patterns are compiled once, so requests don't have to
+	for name, schema := range s.schemas {
+		if err := schema.compile(); err != nil {
+			return nil, fmt.Errorf("error in schema %s: %s", name, err.Error())
+		}
+	}
+	for path, operations := range s.paths {
+		for method, o := range operations {
+			if err := o.compile(); err != nil {
+				return nil, fmt.Errorf("error in %s %s: %s", strings.ToUpper(method), path, err.Error())
+			}
+		}
+	}
+
+	return s, nil
+}
+
+var (
+	loaded    *Spec
+	loadErr   error
+	loadSpecs sync.Once
+)
+
+// Load returns the specification in api/openapi.json, which is built into the service
+// with go generate. The specification is only parsed once.
+func Load() (*Spec, error) {
+	loadSpecs.Do(func() {
+		loaded, loadErr = Parse([]byte(specJSON))
+	})
+	return loaded, loadErr
+}
+
+// compile compiles the patterns in the schemas of the operation
+func (o *Operation) compile() error {
+	for _, p := range o.Parameters {
+		if err := p.Schema.compile(); err != nil {
+			return fmt.Errorf("parameter %s: %s", p.Name, err.Error())
+		}
+	}
+	if o.RequestBody != nil {
+		for mediaType, c := range o.RequestBody.Content {
+			if err := c.Schema.compile(); err != nil {
+				return fmt.Errorf("body %s: %s", mediaType, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// compile compiles the pattern of the schema and the schemas in it
+func (s *Schema) compile() error {
+	if s == nil {
+		return nil
+	}
+
+	if len(s.Pattern) > 0 {
+		p, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %s: %s", s.Pattern, err.Error())
+		}
+		s.pattern = p
+	}
+
+	if len(s.AdditionalProperties) > 0 {
+		switch strings.TrimSpace(string(s.AdditionalProperties)) {
+		case "true":
+		case "false":
+			s.noAdditional = true
+		default:
+			s.additional = &Schema{}
+			if err := json.Unmarshal(s.AdditionalProperties, s.additional); err != nil {
+				return fmt.Errorf("invalid additionalProperties: %s", err.Error())
+			}
+		}
+	}
+
+	children := append([]*Schema{s.Items, s.additional}, s.AllOf...)
+	for _, p := range s.Properties {
+		children = append(children, p)
+	}
+	for _, c := range children {
+		if err := c.compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
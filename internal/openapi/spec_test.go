@@ -0,0 +1,21 @@
+package openapi
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestSpecIsGenerated(t *testing.T) {
+	data, err := ioutil.ReadFile("../../api/openapi.json")
+	if err != nil {
+		t.Fatalf("error reading OpenAPI specification: %s", err.Error())
+	}
+
+	if string(data) != specJSON {
+		t.Error("spec.go doesn't match api/openapi.json, run go generate ./internal/openapi")
+	}
+
+	if _, err := Load(); err != nil {
+		t.Error(err.Error())
+	}
+}
@@ -0,0 +1,313 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+)
+
+// Request is a request that is validated against the specification
+type Request struct {
+	// Method is the HTTP method of the request
+	Method string
+
+	// Path is the path of the request, like /cart/items/dan
+	Path string
+
+	// Route is the path template the request was routed with, like /cart/items/{userid}.
+	// When it isn't set, the route is found by matching the path with the specification.
+	Route string
+
+	// Query are the query parameters of the request
+	Query map[string]string
+
+	// Headers are the headers of the request
+	Headers map[string]string
+
+	// Body is the body of the request
+	Body []byte
+}
+
+// Validate checks the request against the operation in the specification. Requests
+// for operations that aren't in the specification aren't checked, so the router can
+// handle them. The error wraps datastore.ErrInvalidRequest and lists everything
+// that is wrong with the request.
+func (s *Spec) Validate(r Request) error {
+	o, params := s.find(r)
+	if o == nil {
+		return nil
+	}
+
+	errs := make([]string, 0)
+	for _, p := range o.Parameters {
+		var value string
+		var ok bool
+		switch p.In {
+		case "path":
+			value, ok = params[p.Name]
+		case "query":
+			value, ok = r.Query[p.Name]
+		case "header":
+			value, ok = cart.LookupHeader(r.Headers, p.Name)
+		default:
+			continue
+		}
+
+		at := fmt.Sprintf("%s parameter %s", p.In, p.Name)
+		if !ok || len(value) == 0 {
+			if p.Required {
+				errs = append(errs, fmt.Sprintf("%s is required", at))
+			}
+			continue
+		}
+		s.validate(p.Schema, parameterValue(p.Schema, value), at, &errs)
+	}
+
+	if o.RequestBody != nil {
+		s.validateBody(o.RequestBody, r, &errs)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %s", datastore.ErrInvalidRequest, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// find returns the operation for the request and the values of the path parameters
+func (s *Spec) find(r Request) (*Operation, map[string]string) {
+	method := strings.ToLower(r.Method)
+
+	if len(r.Route) > 0 {
+		if o, ok := s.paths[r.Route][method]; ok {
+			params, _ := match(r.Route, r.Path)
+			return o, params
+		}
+	}
+
+	// Paths without parameters are preferred over paths with them
+	var found *Operation
+	var foundParams map[string]string
+	for route, operations := range s.paths {
+		o, ok := operations[method]
+		if !ok {
+			continue
+		}
+		params, ok := match(route, r.Path)
+		if ok && (found == nil || len(params) < len(foundParams)) {
+			found, foundParams = o, params
+		}
+	}
+	return found, foundParams
+}
+
+// match returns the values of the parameters in the route when the path matches it
+func match(route string, path string) (map[string]string, bool) {
+	rs := strings.Split(strings.Trim(route, "/"), "/")
+	ps := strings.Split(strings.Trim(path, "/"), "/")
+	if len(rs) != len(ps) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i := range rs {
+		if strings.HasPrefix(rs[i], "{") && strings.HasSuffix(rs[i], "}") {
+			params[rs[i][1:len(rs[i])-1]] = ps[i]
+			continue
+		}
+		if rs[i] != ps[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// parameterValue returns the value of a parameter as the type in the schema, or the
+// value itself when it isn't of that type, so the schema reports it
+func parameterValue(schema *Schema, value string) interface{} {
+	if schema == nil {
+		return value
+	}
+	switch schema.Type {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			return json.Number(value)
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+// validateBody checks the body of the request against the schema for its media type.
+// Bodies are read as JSON when the operation only takes JSON, whatever their media type,
+// and bodies of other media types are left to the handler.
+func (s *Spec) validateBody(rb *RequestBody, r Request, errs *[]string) {
+	if len(bytes.TrimSpace(r.Body)) == 0 {
+		if rb.Required {
+			*errs = append(*errs, "body is required")
+		}
+		return
+	}
+
+	contentType, _ := cart.LookupHeader(r.Headers, "Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	c, ok := rb.Content[mediaType]
+	if err != nil || !ok {
+		c, ok = rb.Content["application/json"]
+		if !ok {
+			return
+		}
+	}
+
+	d := json.NewDecoder(bytes.NewReader(r.Body))
+	d.UseNumber()
+	var body interface{}
+	if err := d.Decode(&body); err != nil {
+		*errs = append(*errs, fmt.Sprintf("body is not valid JSON: %s", err.Error()))
+		return
+	}
+
+	s.validate(c.Schema, body, "body", errs)
+}
+
+// validate checks the value against the schema, and adds what is wrong to errs
+func (s *Spec) validate(schema *Schema, v interface{}, at string, errs *[]string) {
+	if schema == nil {
+		return
+	}
+
+	if len(schema.Ref) > 0 {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		ref, ok := s.schemas[name]
+		if !ok {
+			*errs = append(*errs, fmt.Sprintf("%s has unknown schema %s", at, schema.Ref))
+			return
+		}
+		s.validate(ref, v, at, errs)
+		return
+	}
+
+	for _, sub := range schema.AllOf {
+		s.validate(sub, v, at, errs)
+	}
+
+	if v == nil {
+		if len(schema.Type) > 0 && !schema.Nullable {
+			*errs = append(*errs, fmt.Sprintf("%s must not be null", at))
+		}
+		return
+	}
+
+	if len(schema.Type) > 0 && !hasType(v, schema.Type) {
+		*errs = append(*errs, fmt.Sprintf("%s must be of type %s", at, schema.Type))
+		return
+	}
+
+	if len(schema.Enum) > 0 && !inEnum(v, schema.Enum) {
+		*errs = append(*errs, fmt.Sprintf("%s must be one of %s", at, enumString(schema.Enum)))
+	}
+
+	switch value := v.(type) {
+	case string:
+		n := utf8.RuneCountInString(value)
+		if schema.MinLength != nil && n < *schema.MinLength {
+			*errs = append(*errs, fmt.Sprintf("%s must have at least %d characters", at, *schema.MinLength))
+		}
+		if schema.MaxLength != nil && n > *schema.MaxLength {
+			*errs = append(*errs, fmt.Sprintf("%s must have at most %d characters", at, *schema.MaxLength))
+		}
+		if schema.pattern != nil && !schema.pattern.MatchString(value) {
+			*errs = append(*errs, fmt.Sprintf("%s must match %s", at, schema.Pattern))
+		}
+	case json.Number:
+		f, _ := value.Float64()
+		if schema.Minimum != nil && f < *schema.Minimum {
+			*errs = append(*errs, fmt.Sprintf("%s must be at least %v", at, *schema.Minimum))
+		}
+		if schema.Maximum != nil && f > *schema.Maximum {
+			*errs = append(*errs, fmt.Sprintf("%s must be at most %v", at, *schema.Maximum))
+		}
+	case []interface{}:
+		if schema.MinItems != nil && len(value) < *schema.MinItems {
+			*errs = append(*errs, fmt.Sprintf("%s must have at least %d items", at, *schema.MinItems))
+		}
+		if schema.MaxItems != nil && len(value) > *schema.MaxItems {
+			*errs = append(*errs, fmt.Sprintf("%s must have at most %d items", at, *schema.MaxItems))
+			return
+		}
+		for i, item := range value {
+			s.validate(schema.Items, item, fmt.Sprintf("%s[%d]", at, i), errs)
+		}
+	case map[string]interface{}:
+		for _, name := range schema.Required {
+			if _, ok := value[name]; !ok {
+				*errs = append(*errs, fmt.Sprintf("%s.%s is required", at, name))
+			}
+		}
+		if schema.MaxProperties != nil && len(value) > *schema.MaxProperties {
+			*errs = append(*errs, fmt.Sprintf("%s must have at most %d properties", at, *schema.MaxProperties))
+			return
+		}
+		for name, prop := range value {
+			if p, ok := schema.Properties[name]; ok {
+				s.validate(p, prop, at+"."+name, errs)
+				continue
+			}
+			if schema.noAdditional {
+				*errs = append(*errs, fmt.Sprintf("%s.%s is not allowed", at, name))
+				continue
+			}
+			s.validate(schema.additional, prop, at+"."+name, errs)
+		}
+	}
+}
+
+// hasType returns true when the JSON value is of the type
+func hasType(v interface{}, t string) bool {
+	switch value := v.(type) {
+	case string:
+		return t == "string"
+	case bool:
+		return t == "boolean"
+	case json.Number:
+		if t == "integer" {
+			_, err := value.Int64()
+			return err == nil
+		}
+		return t == "number"
+	case []interface{}:
+		return t == "array"
+	case map[string]interface{}:
+		return t == "object"
+	default:
+		return false
+	}
+}
+
+// inEnum returns true when the value is one of the values of the enum
+func inEnum(v interface{}, enum []interface{}) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// enumString returns the values of the enum as a list
+func enumString(enum []interface{}) string {
+	values := make([]string, len(enum))
+	for i, e := range enum {
+		values[i] = fmt.Sprint(e)
+	}
+	return strings.Join(values, ", ")
+}
@@ -0,0 +1,462 @@
+// Package patch applies JSON Merge Patch (RFC 7396) and JSON Patch (RFC 6902) documents
+// to JSON documents.
+package patch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MergePatchType is the media type of a JSON Merge Patch
+	MergePatchType = "application/merge-patch+json"
+
+	// JSONPatchType is the media type of a JSON Patch
+	JSONPatchType = "application/json-patch+json"
+)
+
+var (
+	// ErrUnsupportedMediaType is returned when a patch is neither a JSON Merge Patch nor a JSON Patch
+	ErrUnsupportedMediaType = errors.New("unsupported patch media type")
+
+	// ErrTestFailed is returned when a test operation of a JSON Patch fails
+	ErrTestFailed = errors.New("patch test failed")
+
+	// ErrInvalidPatch is returned when a patch is malformed or can't be applied to the document
+	ErrInvalidPatch = errors.New("invalid patch")
+)
+
+// Apply applies the patch to the document, as a JSON Merge Patch or a JSON Patch
+// depending on the media type in contentType.
+func Apply(contentType string, doc []byte, patch []byte) ([]byte, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s", ErrUnsupportedMediaType, contentType)
+	}
+
+	switch mediaType {
+	case MergePatchType:
+		return MergePatch(doc, patch)
+	case JSONPatchType:
+		return JSONPatch(doc, patch)
+	default:
+		return nil, fmt.Errorf("%w %s", ErrUnsupportedMediaType, mediaType)
+	}
+}
+
+// MergePatch applies a JSON Merge Patch to the document. Members of the patch that are
+// null are removed from the document, objects are merged, and all other values replace
+// the value in the document.
+func MergePatch(doc []byte, patch []byte) ([]byte, error) {
+	d, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := decode(patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPatch, err.Error())
+	}
+
+	return json.Marshal(merge(d, p))
+}
+
+// merge returns the target with the patch merged into it
+func merge(target interface{}, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = merge(t[k], v)
+	}
+
+	return t
+}
+
+// operation is a single operation of a JSON Patch
+type operation struct {
+	op    string
+	path  []string
+	from  []string
+	value interface{}
+}
+
+// JSONPatch applies a JSON Patch to the document. The operations are applied in order,
+// and the document isn't changed when one of them fails.
+func JSONPatch(doc []byte, patch []byte) ([]byte, error) {
+	d, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	ops, err := parseOperations(patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPatch, err.Error())
+	}
+
+	for idx, o := range ops {
+		d, err = o.apply(d)
+		if errors.Is(err, ErrTestFailed) {
+			return nil, fmt.Errorf("operation %d (%s): %w", idx, o.op, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: operation %d (%s): %s", ErrInvalidPatch, idx, o.op, err.Error())
+		}
+	}
+
+	return json.Marshal(d)
+}
+
+// parseOperations reads the operations of a JSON Patch
+func parseOperations(patch []byte) ([]operation, error) {
+	var raw []map[string]json.RawMessage
+	err := json.Unmarshal(patch, &raw)
+	if err != nil {
+		return nil, err
+	}
+
+	ops := make([]operation, len(raw))
+	for idx, r := range raw {
+		var o operation
+
+		err = json.Unmarshal(r["op"], &o.op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d has no op", idx)
+		}
+
+		var path string
+		err = json.Unmarshal(r["path"], &path)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d has no path", idx)
+		}
+		o.path, err = parsePointer(path)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %s", idx, err.Error())
+		}
+
+		switch o.op {
+		case "add", "replace", "test":
+			value, ok := r["value"]
+			if !ok {
+				return nil, fmt.Errorf("operation %d has no value", idx)
+			}
+			o.value, err = decode(value)
+			if err != nil {
+				return nil, fmt.Errorf("operation %d: %s", idx, err.Error())
+			}
+		case "move", "copy":
+			var from string
+			err = json.Unmarshal(r["from"], &from)
+			if err != nil {
+				return nil, fmt.Errorf("operation %d has no from", idx)
+			}
+			o.from, err = parsePointer(from)
+			if err != nil {
+				return nil, fmt.Errorf("operation %d: %s", idx, err.Error())
+			}
+		case "remove":
+		default:
+			return nil, fmt.Errorf("operation %d has unknown op %s", idx, o.op)
+		}
+
+		ops[idx] = o
+	}
+
+	return ops, nil
+}
+
+// apply returns the document with the operation applied to it
+func (o operation) apply(doc interface{}) (interface{}, error) {
+	switch o.op {
+	case "add":
+		return add(doc, o.path, o.value)
+	case "remove":
+		doc, _, err := remove(doc, o.path)
+		return doc, err
+	case "replace":
+		doc, _, err := remove(doc, o.path)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, o.path, o.value)
+	case "move":
+		if isPrefix(o.from, o.path) && len(o.from) < len(o.path) {
+			return nil, fmt.Errorf("can't move %s into itself", pointer(o.from))
+		}
+		doc, value, err := remove(doc, o.from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, o.path, value)
+	case "copy":
+		value, err := get(doc, o.from)
+		if err != nil {
+			return nil, err
+		}
+		value, err = deepCopy(value)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, o.path, value)
+	default:
+		value, err := get(doc, o.path)
+		if err != nil {
+			return nil, err
+		}
+		if !equal(value, o.value) {
+			return nil, fmt.Errorf("%w: value at %s is different", ErrTestFailed, pointer(o.path))
+		}
+		return doc, nil
+	}
+}
+
+// add returns the document with the value added at the path
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return update(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			c[token] = value
+			return c, nil
+		case []interface{}:
+			if token == "-" {
+				return append(c, value), nil
+			}
+			idx, err := index(token, len(c)+1)
+			if err != nil {
+				return nil, err
+			}
+			c = append(c, nil)
+			copy(c[idx+1:], c[idx:])
+			c[idx] = value
+			return c, nil
+		default:
+			return nil, fmt.Errorf("%s is not an object or an array", pointer(path[:len(path)-1]))
+		}
+	})
+}
+
+// remove returns the document without the value at the path, and the value that was removed
+func remove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+
+	var removed interface{}
+	doc, err := update(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			value, ok := c[token]
+			if !ok {
+				return nil, fmt.Errorf("%s doesn't exist", pointer(path))
+			}
+			removed = value
+			delete(c, token)
+			return c, nil
+		case []interface{}:
+			idx, err := index(token, len(c))
+			if err != nil {
+				return nil, err
+			}
+			removed = c[idx]
+			return append(c[:idx], c[idx+1:]...), nil
+		default:
+			return nil, fmt.Errorf("%s is not an object or an array", pointer(path[:len(path)-1]))
+		}
+	})
+
+	return doc, removed, err
+}
+
+// update returns the document with the container that holds the last token of the path
+// replaced by the result of fn
+func update(node interface{}, path []string, fn func(container interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(node, path[0])
+	}
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, ok := n[path[0]]
+		if !ok {
+			return nil, fmt.Errorf("%s doesn't exist", path[0])
+		}
+		child, err := update(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[path[0]] = child
+		return n, nil
+	case []interface{}:
+		idx, err := index(path[0], len(n))
+		if err != nil {
+			return nil, err
+		}
+		child, err := update(n[idx], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[idx] = child
+		return n, nil
+	default:
+		return nil, fmt.Errorf("%s is not an object or an array", path[0])
+	}
+}
+
+// get returns the value at the path
+func get(doc interface{}, path []string) (interface{}, error) {
+	node := doc
+	for i, token := range path {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			child, ok := n[token]
+			if !ok {
+				return nil, fmt.Errorf("%s doesn't exist", pointer(path[:i+1]))
+			}
+			node = child
+		case []interface{}:
+			idx, err := index(token, len(n))
+			if err != nil {
+				return nil, err
+			}
+			node = n[idx]
+		default:
+			return nil, fmt.Errorf("%s doesn't exist", pointer(path[:i+1]))
+		}
+	}
+	return node, nil
+}
+
+// index returns the array index in the token, which must be less than max
+func index(token string, max int) (int, error) {
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%s is not an array index", token)
+	}
+	if idx >= max {
+		return 0, fmt.Errorf("array index %d is out of range", idx)
+	}
+	return idx, nil
+}
+
+// parsePointer returns the reference tokens of a JSON Pointer
+func parsePointer(p string) ([]string, error) {
+	if len(p) == 0 {
+		return nil, nil
+	}
+	if p[0] != '/' {
+		return nil, fmt.Errorf("%s is not a json pointer", p)
+	}
+
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(t, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+// pointer returns the JSON Pointer of the reference tokens
+func pointer(tokens []string) string {
+	var sb strings.Builder
+	for _, t := range tokens {
+		sb.WriteString("/")
+		sb.WriteString(strings.Replace(strings.Replace(t, "~", "~0", -1), "/", "~1", -1))
+	}
+	return sb.String()
+}
+
+// isPrefix returns true when the tokens of prefix are the first tokens of path
+func isPrefix(prefix []string, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// equal returns true when both values are the same JSON value
+func equal(a interface{}, b interface{}) bool {
+	switch x := a.(type) {
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			w, ok := y[k]
+			if !ok || !equal(v, w) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fx, errx := x.Float64()
+		fy, erry := y.Float64()
+		return errx == nil && erry == nil && fx == fy
+	default:
+		return a == b
+	}
+}
+
+// deepCopy returns a copy of the value that shares nothing with it
+func deepCopy(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return decode(data)
+}
+
+// decode reads a JSON value, keeping numbers as they are written
+func decode(data []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+
+	var v interface{}
+	err := d.Decode(&v)
+	if err != nil {
+		return nil, err
+	}
+	if d.More() {
+		return nil, fmt.Errorf("unexpected data after json value")
+	}
+	return v, nil
+}
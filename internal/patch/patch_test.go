@@ -0,0 +1,136 @@
+package patch
+
+import (
+	"errors"
+	"testing"
+)
+
+// sameJSON returns true when both documents are the same JSON value
+func sameJSON(t *testing.T, a []byte, b string) bool {
+	x, err := decode(a)
+	if err != nil {
+		t.Fatalf("error decoding %s: %s", a, err.Error())
+	}
+	y, err := decode([]byte(b))
+	if err != nil {
+		t.Fatalf("error decoding %s: %s", b, err.Error())
+	}
+	return equal(x, y)
+}
+
+func TestJSONPatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+		err   error
+	}{
+		{"add with ~1 in the pointer", `{}`, `[{"op":"add","path":"/a~1b","value":1}]`, `{"a/b":1}`, nil},
+		{"add with ~0 in the pointer", `{}`, `[{"op":"add","path":"/a~0b","value":1}]`, `{"a~b":1}`, nil},
+		{"~01 is ~1, not /", `{"~1":1,"/":2}`, `[{"op":"remove","path":"/~01"}]`, `{"/":2}`, nil},
+		{"replace with escaped pointer", `{"m~n":{"a/b":1}}`, `[{"op":"replace","path":"/m~0n/a~1b","value":2}]`, `{"m~n":{"a/b":2}}`, nil},
+		{"add - appends to array", `{"a":[1,2]}`, `[{"op":"add","path":"/a/-","value":3}]`, `{"a":[1,2,3]}`, nil},
+		{"add at index inserts", `{"a":[1,3]}`, `[{"op":"add","path":"/a/1","value":2}]`, `{"a":[1,2,3]}`, nil},
+		{"add at length appends", `{"a":[1,2]}`, `[{"op":"add","path":"/a/2","value":3}]`, `{"a":[1,2,3]}`, nil},
+		{"add after length", `{"a":[1,2]}`, `[{"op":"add","path":"/a/3","value":3}]`, ``, ErrInvalidPatch},
+		{"remove -", `{"a":[1,2]}`, `[{"op":"remove","path":"/a/-"}]`, ``, ErrInvalidPatch},
+		{"remove with leading zero", `{"a":[1,2]}`, `[{"op":"remove","path":"/a/01"}]`, ``, ErrInvalidPatch},
+		{"test number", `{"a":1}`, `[{"op":"test","path":"/a","value":1.0}]`, `{"a":1}`, nil},
+		{"test object", `{"a":{"b":[1,"c"]}}`, `[{"op":"test","path":"/a","value":{"b":[1,"c"]}}]`, `{"a":{"b":[1,"c"]}}`, nil},
+		{"test escaped pointer", `{"a/b":"c"}`, `[{"op":"test","path":"/a~1b","value":"c"}]`, `{"a/b":"c"}`, nil},
+		{"test different value", `{"a":1}`, `[{"op":"test","path":"/a","value":2}]`, ``, ErrTestFailed},
+		{"test different type", `{"a":1}`, `[{"op":"test","path":"/a","value":"1"}]`, ``, ErrTestFailed},
+		{"test missing value", `{"a":1}`, `[{"op":"test","path":"/b","value":1}]`, ``, ErrInvalidPatch},
+		{"failed test undoes operations", `{"a":1}`, `[{"op":"add","path":"/b","value":2},{"op":"test","path":"/a","value":2}]`, ``, ErrTestFailed},
+		{"move", `{"a":{"b":1},"c":{}}`, `[{"op":"move","from":"/a/b","path":"/c/d"}]`, `{"a":{},"c":{"d":1}}`, nil},
+		{"move in array", `{"a":[1,2,3]}`, `[{"op":"move","from":"/a/0","path":"/a/-"}]`, `{"a":[2,3,1]}`, nil},
+		{"move into itself", `{"a":{"b":{}}}`, `[{"op":"move","from":"/a","path":"/a/b/c"}]`, ``, ErrInvalidPatch},
+		{"move missing value", `{"a":1}`, `[{"op":"move","from":"/b","path":"/c"}]`, ``, ErrInvalidPatch},
+		{"copy", `{"a":{"b":1}}`, `[{"op":"copy","from":"/a","path":"/c"}]`, `{"a":{"b":1},"c":{"b":1}}`, nil},
+		{"copy is not shared", `{"a":{"b":1}}`, `[{"op":"copy","from":"/a","path":"/c"},{"op":"replace","path":"/c/b","value":2}]`, `{"a":{"b":1},"c":{"b":2}}`, nil},
+		{"copy to -", `{"a":[1],"b":2}`, `[{"op":"copy","from":"/b","path":"/a/-"}]`, `{"a":[1,2],"b":2}`, nil},
+		{"copy missing value", `{"a":1}`, `[{"op":"copy","from":"/b","path":"/c"}]`, ``, ErrInvalidPatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := JSONPatch([]byte(tt.doc), []byte(tt.patch))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected %v, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error applying patch: %s", err.Error())
+			}
+			if !sameJSON(t, got, tt.want) {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		doc   string
+		patch string
+		want  string
+	}{
+		{"null removes member", `{"a":1,"b":2}`, `{"a":null}`, `{"b":2}`},
+		{"null removes nested member", `{"a":{"b":1,"c":2}}`, `{"a":{"b":null}}`, `{"a":{"c":2}}`},
+		{"null for missing member", `{"a":1}`, `{"b":null}`, `{"a":1}`},
+		{"null in new object is dropped", `{}`, `{"a":{"b":null,"c":1}}`, `{"a":{"c":1}}`},
+		{"objects are merged", `{"a":{"b":1}}`, `{"a":{"c":2}}`, `{"a":{"b":1,"c":2}}`},
+		{"arrays are replaced", `{"a":[1,2]}`, `{"a":[3]}`, `{"a":[3]}`},
+		{"null in array is kept", `{"a":[1]}`, `{"a":[null]}`, `{"a":[null]}`},
+		{"object replaces value", `{"a":1}`, `{"a":{"b":2}}`, `{"a":{"b":2}}`},
+		{"non-object patch replaces document", `{"a":1}`, `[1]`, `[1]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("error applying patch: %s", err.Error())
+			}
+			if !sameJSON(t, got, tt.want) {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestApplyMediaType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		patch       string
+		err         error
+	}{
+		{"application/merge-patch+json", `{"a":null}`, nil},
+		{"application/merge-patch+json; charset=utf-8", `{"a":null}`, nil},
+		{"application/json-patch+json", `[{"op":"remove","path":"/a"}]`, nil},
+		{"application/json", `{"a":null}`, ErrUnsupportedMediaType},
+		{"", `{"a":null}`, ErrUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			got, err := Apply(tt.contentType, []byte(`{"a":1}`), []byte(tt.patch))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected %v, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error applying patch: %s", err.Error())
+			}
+			if !sameJSON(t, got, `{}`) {
+				t.Errorf("expected {}, got %s", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+// Package problem turns errors into problem details (RFC 7807), so both the Cloud Run
+// service and the Lambda functions return errors in the same format. Errors of the
+// service itself are returned without their message, since that can contain details
+// of the datastore or other services.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/retgits/acme-serverless-cart/internal/auth"
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/catalog"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	cartevents "github.com/retgits/acme-serverless-cart/internal/events"
+	"github.com/retgits/acme-serverless-cart/internal/idempotency"
+	"github.com/retgits/acme-serverless-cart/internal/inventory"
+	"github.com/retgits/acme-serverless-cart/internal/patch"
+	"github.com/retgits/acme-serverless-cart/internal/ratelimit"
+	"github.com/retgits/acme-serverless-cart/internal/rules"
+)
+
+const (
+	// ContentType is the media type of problem details
+	ContentType = "application/problem+json"
+
+	// TypePrefix is the prefix of the type of a problem, which is followed by its code
+	TypePrefix = "urn:acme-serverless-cart:problem:"
+)
+
+// Problem is the body of an error response, as described in RFC 7807
+type Problem struct {
+	// Type is a URI that identifies the kind of problem
+	Type string `json:"type"`
+
+	// Title is a short summary of the kind of problem
+	Title string `json:"title"`
+
+	// Status is the HTTP status code of the response
+	Status int `json:"status"`
+
+	// Detail explains what went wrong with this request
+	Detail string `json:"detail,omitempty"`
+
+	// Code identifies the kind of problem, so clients can handle it without parsing the title
+	Code string `json:"code"`
+
+	// RequestID is the ID of the request, so it can be found in the logs
+	RequestID string `json:"requestid,omitempty"`
+
+	// Violations are the business rules that the cart breaks
+	Violations []rules.Violation `json:"violations,omitempty"`
+}
+
+// Marshal returns the JSON encoding of Problem
+func (p Problem) Marshal() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+// kind is a kind of problem and the error it is returned for
+type kind struct {
+	err    error
+	status int
+	code   string
+	title  string
+}
+
+// kinds are the problems that errors are returned as, the first kind that matches an
+// error is used
+var kinds = []kind{
+	{datastore.ErrInvalidRequest, http.StatusBadRequest, "invalid_request", "The request is not valid"},
+	{patch.ErrInvalidPatch, http.StatusBadRequest, "invalid_patch", "The patch is not valid"},
+	{catalog.ErrItemNotFound, http.StatusBadRequest, "unknown_item", "The item is not in the catalog"},
+	{cart.ErrEmptyCart, http.StatusBadRequest, "empty_cart", "The cart is empty"},
+	{auth.ErrUnauthorized, http.StatusUnauthorized, "unauthorized", "The request is not authenticated"},
+	{cart.ErrForbidden, http.StatusForbidden, "forbidden", "Access to the cart is not allowed"},
+	{cart.ErrInvalidInvite, http.StatusForbidden, "invalid_invite", "The invite is not valid"},
+	{datastore.ErrCartNotFound, http.StatusNotFound, "cart_not_found", "The cart does not exist"},
+	{datastore.ErrItemNotFound, http.StatusNotFound, "item_not_found", "The item is not in the cart"},
+	{datastore.ErrVersionNotFound, http.StatusNotFound, "version_not_found", "The version of the cart is not kept"},
+	{inventory.ErrInsufficientStock, http.StatusConflict, "insufficient_stock", "There is not enough stock"},
+	{datastore.ErrCartExists, http.StatusConflict, "cart_exists", "The cart already exists"},
+	{datastore.ErrCartLocked, http.StatusConflict, "cart_locked", "The cart is being checked out"},
+	{datastore.ErrVersionMismatch, http.StatusConflict, "cart_changed", "The cart has changed"},
+	{patch.ErrTestFailed, http.StatusConflict, "patch_test_failed", "A test in the patch failed"},
+	{idempotency.ErrInProgress, http.StatusConflict, "request_in_progress", "A request with the idempotency key is in progress"},
+	{cart.ErrPreconditionFailed, http.StatusPreconditionFailed, "precondition_failed", "The cart does not match the If-Match header"},
+	{patch.ErrUnsupportedMediaType, http.StatusUnsupportedMediaType, "unsupported_media_type", "The media type of the patch is not supported"},
+	{rules.ErrRulesViolated, http.StatusUnprocessableEntity, "rules_violated", "The cart violates business rules"},
+	{idempotency.ErrKeyReused, http.StatusUnprocessableEntity, "idempotency_key_reused", "The idempotency key is used for a different request"},
+	{ratelimit.ErrRateLimited, http.StatusTooManyRequests, "rate_limited", "Too many requests"},
+	{cartevents.ErrPublishFailed, http.StatusBadGateway, "publish_failed", "The checkout event could not be sent"},
+}
+
+// internal is the kind of problem for errors that don't match any other kind
+var internal = kind{nil, http.StatusInternalServerError, "internal_error", "An unexpected error occurred"}
+
+// FromError returns the problem for an error. Errors that aren't caused by the request
+// only get a title, so their message doesn't reach the client.
+func FromError(err error, requestID string) Problem {
+	k := internal
+	for _, c := range kinds {
+		if errors.Is(err, c.err) {
+			k = c
+			break
+		}
+	}
+
+	// Requests that aren't valid JSON are returned with the error of the parser
+	var se *json.SyntaxError
+	var te *json.UnmarshalTypeError
+	if k.err == nil && (errors.As(err, &se) || errors.As(err, &te)) {
+		k = kinds[0]
+	}
+
+	p := Problem{
+		Type:      TypePrefix + k.code,
+		Title:     k.title,
+		Status:    k.status,
+		Code:      k.code,
+		RequestID: requestID,
+	}
+
+	if k.status < http.StatusInternalServerError {
+		p.Detail = err.Error()
+	}
+
+	var ve *rules.ViolationsError
+	if errors.As(err, &ve) {
+		p.Violations = ve.Violations
+	}
+
+	return p
+}
+
+// Response returns the API Gateway Proxy Response for an error. The request ID is read
+// from the RequestIDHeader in the headers, which are returned with the response.
+func Response(headers map[string]string, err error) events.APIGatewayProxyResponse {
+	res := make(map[string]string, len(headers)+1)
+	for k, v := range headers {
+		if !strings.EqualFold(k, "Content-Type") {
+			res[k] = v
+		}
+	}
+	res["Content-Type"] = ContentType
+
+	p := FromError(err, headers[cart.RequestIDHeader])
+	payload, err := p.Marshal()
+	if err != nil {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body:       internal.title,
+			Headers:    headers,
+		}
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: p.Status,
+		Body:       string(payload),
+		Headers:    res,
+	}
+}
@@ -0,0 +1,180 @@
+// Package dynamodb keeps the token buckets of clients in an Amazon DynamoDB table, so all
+// instances of the service share the same buckets.
+package dynamodb
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/retgits/acme-serverless-cart/internal/ratelimit"
+)
+
+const (
+	// The buckets are stored for the access pattern PK = RATELIMIT#KEY SK = BUCKET
+	bucketPrefix = "RATELIMIT#"
+
+	// attempts is how many times a bucket is read and written before the request is
+	// denied, when other instances change the bucket at the same time
+	attempts = 3
+)
+
+// limiter is a DynamoDB implementation of the Limiter interface
+type limiter struct {
+	dbs   dynamodbiface.DynamoDBAPI
+	table string
+	limit ratelimit.Limit
+}
+
+// New creates a Limiter that keeps the buckets in the DynamoDB table. If the environment
+// variable DYNAMO_URL is set, the connection is made to that URL instead of relying on
+// the AWS SDK to provide the URL. Buckets get a TTL attribute, so they can be
+// removed using the Time to Live of the table.
+func New(table string, limit ratelimit.Limit) ratelimit.Limiter {
+	awsSession := session.Must(session.NewSession(&aws.Config{
+		Region: aws.String(os.Getenv("REGION")),
+	}))
+
+	if len(os.Getenv("DYNAMO_URL")) > 0 {
+		awsSession.Config.Endpoint = aws.String(os.Getenv("DYNAMO_URL"))
+	}
+
+	return &limiter{
+		dbs:   dynamodb.New(awsSession),
+		table: table,
+		limit: limit,
+	}
+}
+
+// Allow takes a token from the bucket of the client with the key. The bucket is only
+// written when its version didn't change since it was read, so no tokens are lost when
+// instances take tokens at the same time. When other instances keep changing the bucket,
+// the client is making requests faster than a token can be taken, so the request is
+// denied rather than failing with an error, which would let it through.
+func (l *limiter) Allow(key string) (ratelimit.Decision, error) {
+	for i := 0; i < attempts; i++ {
+		bucket, version, err := l.get(key)
+		if err != nil {
+			return ratelimit.Decision{}, err
+		}
+
+		updated, decision := l.limit.Take(bucket, time.Now())
+
+		err = l.put(key, updated, version)
+		if isConditionalCheckFailed(err) {
+			continue
+		}
+		if err != nil {
+			return ratelimit.Decision{}, err
+		}
+
+		return decision, nil
+	}
+
+	return ratelimit.Decision{RetryAfter: time.Duration(float64(time.Second) / l.limit.Rate)}, nil
+}
+
+// get returns the bucket of the client with the key and its version, or an empty Bucket
+// with version zero when it doesn't exist
+func (l *limiter) get(key string) (ratelimit.Bucket, int64, error) {
+	gio, err := l.dbs.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(l.table),
+		Key:            bucketKey(key),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return ratelimit.Bucket{}, 0, err
+	}
+
+	if gio.Item == nil || gio.Item["Tokens"] == nil || gio.Item["Updated"] == nil || gio.Item["Version"] == nil {
+		return ratelimit.Bucket{}, 0, nil
+	}
+
+	tokens, err := strconv.ParseFloat(aws.StringValue(gio.Item["Tokens"].N), 64)
+	if err != nil {
+		return ratelimit.Bucket{}, 0, err
+	}
+
+	updated, err := strconv.ParseInt(aws.StringValue(gio.Item["Updated"].N), 10, 64)
+	if err != nil {
+		return ratelimit.Bucket{}, 0, err
+	}
+
+	version, err := strconv.ParseInt(aws.StringValue(gio.Item["Version"].N), 10, 64)
+	if err != nil {
+		return ratelimit.Bucket{}, 0, err
+	}
+
+	bucket := ratelimit.Bucket{
+		Tokens:  tokens,
+		Updated: time.Unix(0, updated*int64(time.Millisecond)),
+	}
+
+	return bucket, version, nil
+}
+
+// put stores the bucket as the next version, as long as the stored bucket is still at the
+// version that was read
+func (l *limiter) put(key string, updated ratelimit.Bucket, version int64) error {
+	item := bucketKey(key)
+	item["Tokens"] = &dynamodb.AttributeValue{
+		N: aws.String(strconv.FormatFloat(updated.Tokens, 'f', -1, 64)),
+	}
+	item["Updated"] = &dynamodb.AttributeValue{
+		N: aws.String(millis(updated.Updated)),
+	}
+	item["Version"] = &dynamodb.AttributeValue{
+		N: aws.String(strconv.FormatInt(version+1, 10)),
+	}
+	item["TTL"] = &dynamodb.AttributeValue{
+		N: aws.String(strconv.FormatInt(updated.Updated.Add(l.limit.FullAfter()).Unix()+1, 10)),
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(l.table),
+		Item:      item,
+	}
+
+	if version == 0 {
+		input.ConditionExpression = aws.String("attribute_not_exists(PK) OR attribute_not_exists(Version)")
+	} else {
+		input.ConditionExpression = aws.String("Version = :version")
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":version": {
+				N: aws.String(strconv.FormatInt(version, 10)),
+			},
+		}
+	}
+
+	_, err := l.dbs.PutItem(input)
+	return err
+}
+
+// bucketKey returns the table keys of the bucket of the client with the key
+func bucketKey(key string) map[string]*dynamodb.AttributeValue {
+	km := make(map[string]*dynamodb.AttributeValue)
+	km["PK"] = &dynamodb.AttributeValue{
+		S: aws.String(bucketPrefix + key),
+	}
+	km["SK"] = &dynamodb.AttributeValue{
+		S: aws.String("BUCKET"),
+	}
+	return km
+}
+
+// millis returns the time as milliseconds since the epoch
+func millis(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+}
+
+// isConditionalCheckFailed returns true if a write failed because of its condition expression
+func isConditionalCheckFailed(err error) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+}
@@ -0,0 +1,49 @@
+package dynamodb
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/retgits/acme-serverless-cart/internal/ratelimit"
+)
+
+// contendedTable is a DynamoDB table where another instance changes every bucket between
+// reading and writing it
+type contendedTable struct {
+	dynamodbiface.DynamoDBAPI
+	puts int
+}
+
+func (tbl *contendedTable) GetItem(in *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{}, nil
+}
+
+func (tbl *contendedTable) PutItem(in *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	tbl.puts++
+	return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "the conditional request failed", nil)
+}
+
+func TestAllowDeniesContendedBucket(t *testing.T) {
+	tbl := &contendedTable{}
+	l := &limiter{
+		dbs:   tbl,
+		table: "cart",
+		limit: ratelimit.Limit{Rate: 2, Burst: 10},
+	}
+
+	decision, err := l.Allow("ip:192.0.2.1")
+	if err != nil {
+		t.Fatalf("expected a decision instead of an error, got %s", err.Error())
+	}
+	if decision.Allowed {
+		t.Error("expected the request to be denied when the bucket can't be written")
+	}
+	if decision.RetryAfter <= 0 {
+		t.Errorf("expected a wait before the next request, got %s", decision.RetryAfter)
+	}
+	if tbl.puts != attempts {
+		t.Errorf("expected %d attempts to write the bucket, got %d", attempts, tbl.puts)
+	}
+}
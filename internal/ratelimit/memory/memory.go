@@ -0,0 +1,57 @@
+// Package memory keeps the token buckets of clients in memory. Buckets aren't shared
+// between instances of the service, so each instance allows the full rate.
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/retgits/acme-serverless-cart/internal/ratelimit"
+)
+
+// sweepInterval is how often buckets that are full again are removed
+const sweepInterval = time.Minute
+
+// limiter is an in-memory implementation of the Limiter interface
+type limiter struct {
+	mu      sync.Mutex
+	limit   ratelimit.Limit
+	buckets map[string]ratelimit.Bucket
+	swept   time.Time
+}
+
+// New creates a Limiter that keeps the buckets in memory
+func New(limit ratelimit.Limit) ratelimit.Limiter {
+	return &limiter{
+		limit:   limit,
+		buckets: make(map[string]ratelimit.Bucket),
+		swept:   time.Now(),
+	}
+}
+
+// Allow takes a token from the bucket of the client with the key
+func (l *limiter) Allow(key string) (ratelimit.Decision, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.swept) > sweepInterval {
+		l.sweep(now)
+	}
+
+	bucket, decision := l.limit.Take(l.buckets[key], now)
+	l.buckets[key] = bucket
+
+	return decision, nil
+}
+
+// sweep removes the buckets that are full again, since a missing bucket is full as well
+func (l *limiter) sweep(now time.Time) {
+	full := l.limit.FullAfter()
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.Updated) >= full {
+			delete(l.buckets, key)
+		}
+	}
+	l.swept = now
+}
@@ -0,0 +1,155 @@
+// Package ratelimit contains the interface that the Cart service uses to limit how many
+// requests a client can make. Every client has a token bucket: each request takes a token,
+// and tokens are added back at a fixed rate up to the size of the bucket. In order to keep
+// the buckets somewhere else, the Limiter interface needs to be implemented.
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryAfterHeader is the header that tells a client how many seconds to wait before
+// making another request
+const RetryAfterHeader = "Retry-After"
+
+// ErrRateLimited is returned when a client has made too many requests
+var ErrRateLimited = errors.New("rate limited")
+
+// Limit is the rate at which a client can make requests
+type Limit struct {
+	// Rate is the number of tokens that are added to a bucket every second
+	Rate float64
+
+	// Burst is the size of a bucket, which is the number of requests a client can make
+	// at once
+	Burst int
+}
+
+// Bucket is the token bucket of a client
+type Bucket struct {
+	// Tokens is the number of tokens in the bucket
+	Tokens float64
+
+	// Updated is when the number of tokens was last changed
+	Updated time.Time
+}
+
+// Decision is the result of taking a token from a bucket
+type Decision struct {
+	// Allowed is true when the request can be handled
+	Allowed bool
+
+	// Remaining is the number of requests that can be made right away
+	Remaining int
+
+	// RetryAfter is how long to wait before a request is allowed again
+	RetryAfter time.Duration
+}
+
+// Limiter is the interface that describes the methods a place to keep token buckets
+// needs to implement to be used by the Cart service.
+type Limiter interface {
+	// Allow takes a token from the bucket of the client with the key.
+	Allow(key string) (Decision, error)
+}
+
+// ParseLimit returns the limit for a rate like 10/s, 600/m, or 100/10s, which is the
+// number of requests per period. When burst is zero, clients can make as many requests
+// at once as the number of requests per period.
+func ParseLimit(rate string, burst int) (Limit, error) {
+	parts := strings.SplitN(rate, "/", 2)
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("rate %q must be a number of requests per period, like 10/s", rate)
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("rate %q must have a positive number of requests", rate)
+	}
+
+	period := strings.TrimSpace(parts[1])
+	if len(period) > 0 && (period[0] < '0' || period[0] > '9') {
+		period = "1" + period
+	}
+
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("rate %q must have a positive period", rate)
+	}
+
+	if burst < 0 {
+		return Limit{}, fmt.Errorf("burst %d can't be negative", burst)
+	}
+	if burst == 0 {
+		burst = n
+	}
+
+	return Limit{Rate: float64(n) / d.Seconds(), Burst: burst}, nil
+}
+
+// Take refills the bucket for the time since it was last updated, and takes a token when
+// there is one. A bucket that was never updated is full.
+func (l Limit) Take(b Bucket, now time.Time) (Bucket, Decision) {
+	tokens := float64(l.Burst)
+	if !b.Updated.IsZero() {
+		tokens = math.Min(float64(l.Burst), b.Tokens+now.Sub(b.Updated).Seconds()*l.Rate)
+	}
+
+	if tokens < 1 {
+		wait := time.Duration((1 - tokens) / l.Rate * float64(time.Second))
+		return Bucket{Tokens: tokens, Updated: now}, Decision{RetryAfter: wait}
+	}
+
+	tokens--
+	return Bucket{Tokens: tokens, Updated: now}, Decision{Allowed: true, Remaining: int(tokens)}
+}
+
+// FullAfter returns how long it takes for an empty bucket to be full again. Buckets that
+// haven't been used for that long don't need to be kept.
+func (l Limit) FullAfter() time.Duration {
+	return time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+}
+
+// RetryAfterSeconds returns the value of the Retry-After header for a decision, which is
+// the wait rounded up to whole seconds
+func RetryAfterSeconds(d Decision) string {
+	return strconv.Itoa(int(math.Ceil(d.RetryAfter.Seconds())))
+}
+
+// UserKey returns the key of the bucket of an authenticated user
+func UserKey(userID string) string {
+	return "user:" + userID
+}
+
+// IPKey returns the key of the bucket of the IP address of a client
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// ClientIP returns the IP address of the client that made a request. When the service
+// runs behind trustedProxies proxies, like a load balancer, the address is taken from
+// the X-Forwarded-For header those proxies add to. Otherwise it is the address the
+// request came from, since clients can put anything in the header.
+func ClientIP(remoteIP string, forwardedFor string, trustedProxies int) string {
+	if trustedProxies <= 0 || len(forwardedFor) == 0 {
+		return remoteIP
+	}
+
+	addrs := strings.Split(forwardedFor, ",")
+	idx := len(addrs) - trustedProxies
+	if idx < 0 {
+		idx = 0
+	}
+
+	ip := net.ParseIP(strings.TrimSpace(addrs[idx]))
+	if ip == nil {
+		return remoteIP
+	}
+	return ip.String()
+}
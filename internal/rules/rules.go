@@ -0,0 +1,268 @@
+// Package rules enforces the business rules of the ACME Serverless Fitness Shop on the
+// items in a cart. The rules are declared in a YAML or JSON file, and every change to a
+// cart is checked against them. All rules that are broken are returned together, so the
+// user can fix them at once.
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/retgits/acme-serverless-cart/internal/cart"
+	"github.com/retgits/acme-serverless-cart/internal/datastore"
+	"gopkg.in/yaml.v2"
+)
+
+// RegionHeader is the header with the region the order will be shipped to.
+const RegionHeader = "X-Region"
+
+const (
+	// PositiveQuantity is the rule that every line has a quantity of at least 1
+	PositiveQuantity = "positivequantity"
+
+	// MaxQuantity is the rule that limits the quantity of an item
+	MaxQuantity = "maxquantity"
+
+	// MaxLines is the rule that limits the number of distinct lines
+	MaxLines = "maxlines"
+
+	// MaxValue is the rule that limits the value of a cart
+	MaxValue = "maxvalue"
+
+	// Incompatible is the rule that some items can't be in a cart together
+	Incompatible = "incompatible"
+
+	// Region is the rule that some items can only be shipped to some regions
+	Region = "region"
+)
+
+// ErrRulesViolated is wrapped by a ViolationsError.
+var ErrRulesViolated = errors.New("cart violates business rules")
+
+// Rules are the business rules for carts. Limits that are 0 aren't enforced.
+type Rules struct {
+	// PositiveQuantity requires every line to have a quantity of at least 1
+	PositiveQuantity bool `json:"positivequantity" yaml:"positivequantity"`
+
+	// MaxQuantity is the highest quantity of a single item in a cart
+	MaxQuantity int64 `json:"maxquantity" yaml:"maxquantity"`
+
+	// ItemMaxQuantity overrides MaxQuantity for specific items, by itemid
+	ItemMaxQuantity map[string]int64 `json:"itemmaxquantity" yaml:"itemmaxquantity"`
+
+	// MaxLines is the highest number of distinct lines in a cart
+	MaxLines int `json:"maxlines" yaml:"maxlines"`
+
+	// MaxValue is the highest value of a cart
+	MaxValue float64 `json:"maxvalue" yaml:"maxvalue"`
+
+	// Incompatible are groups of itemids of which only one can be in a cart
+	Incompatible [][]string `json:"incompatible" yaml:"incompatible"`
+
+	// Regions are the regions that items can be shipped to, by itemid. Items that
+	// aren't listed can be shipped anywhere.
+	Regions map[string][]string `json:"regions" yaml:"regions"`
+}
+
+// Violation is a rule that a cart breaks
+type Violation struct {
+	// Rule is the name of the rule
+	Rule string `json:"rule"`
+
+	// ItemID is the item that breaks the rule, if the rule applies to an item
+	ItemID string `json:"itemid,omitempty"`
+
+	// Message describes what is wrong
+	Message string `json:"message"`
+}
+
+// ViolationsError is returned when the items in a cart break one or more rules
+type ViolationsError struct {
+	// Violations are the rules that are broken
+	Violations []Violation `json:"violations"`
+}
+
+// Error returns the messages of the violations
+func (e *ViolationsError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.Message
+	}
+	return fmt.Sprintf("%s: %s", ErrRulesViolated.Error(), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns ErrRulesViolated
+func (e *ViolationsError) Unwrap() error {
+	return ErrRulesViolated
+}
+
+// Parse reads the rules from YAML or JSON. Since JSON is valid YAML, both are read
+// with the same parser.
+func Parse(data []byte) (Rules, error) {
+	var r Rules
+	err := yaml.UnmarshalStrict(data, &r)
+	return r, err
+}
+
+// Load reads the rules from a YAML or JSON file
+func Load(filename string) (Rules, error) {
+	data, err := ioutil.ReadFile(filepath.Clean(filename))
+	if err != nil {
+		return Rules{}, err
+	}
+
+	r, err := Parse(data)
+	if err != nil {
+		return r, fmt.Errorf("error parsing %s: %s", filename, err.Error())
+	}
+
+	return r, nil
+}
+
+// FromEnv reads the rules from CART_RULES, which contains the rules themselves, or from
+// the file at CART_RULES_FILE. It returns nil when neither is set.
+func FromEnv() (*Rules, error) {
+	var (
+		r   Rules
+		err error
+	)
+
+	switch {
+	case len(os.Getenv("CART_RULES")) > 0:
+		r, err = Parse([]byte(os.Getenv("CART_RULES")))
+	case len(os.Getenv("CART_RULES_FILE")) > 0:
+		r, err = Load(os.Getenv("CART_RULES_FILE"))
+	default:
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// RegionFromHeaders returns the region from the headers of a request, or an empty string when
+// the RegionHeader isn't set.
+func RegionFromHeaders(headers map[string]string) string {
+	v, _ := cart.LookupHeader(headers, RegionHeader)
+	return v
+}
+
+// Check returns a ViolationsError when the items break any of the rules, and nil
+// otherwise. The region is where the order will be shipped to.
+func (r *Rules) Check(items datastore.CartItems, region string) error {
+	if r == nil {
+		return nil
+	}
+
+	violations := r.Evaluate(items, region)
+	if len(violations) > 0 {
+		return &ViolationsError{Violations: violations}
+	}
+
+	return nil
+}
+
+// Evaluate returns all rules that the items break
+func (r *Rules) Evaluate(items datastore.CartItems, region string) []Violation {
+	violations := make([]Violation, 0)
+
+	quantities := make(map[string]int64)
+	order := make([]string, 0)
+	value := 0.0
+
+	for _, item := range items {
+		itemID := ""
+		if item.ItemID != nil {
+			itemID = *item.ItemID
+		}
+
+		if r.PositiveQuantity && item.Quantity <= 0 {
+			violations = append(violations, Violation{
+				Rule:    PositiveQuantity,
+				ItemID:  itemID,
+				Message: fmt.Sprintf("item %s has a quantity of %d", itemID, item.Quantity),
+			})
+		}
+
+		if _, ok := quantities[itemID]; !ok {
+			order = append(order, itemID)
+		}
+		quantities[itemID] = quantities[itemID] + item.Quantity
+		value = value + (float64(item.Quantity) * item.Price)
+	}
+
+	for _, itemID := range order {
+		max := r.MaxQuantity
+		if m, ok := r.ItemMaxQuantity[itemID]; ok {
+			max = m
+		}
+		if max > 0 && quantities[itemID] > max {
+			violations = append(violations, Violation{
+				Rule:    MaxQuantity,
+				ItemID:  itemID,
+				Message: fmt.Sprintf("item %s has a quantity of %d, which is more than %d", itemID, quantities[itemID], max),
+			})
+		}
+
+		if allowed, ok := r.Regions[itemID]; ok && !contains(allowed, region) {
+			msg := fmt.Sprintf("item %s can't be shipped to %s", itemID, region)
+			if len(region) == 0 {
+				msg = fmt.Sprintf("item %s can only be shipped to %s, set the %s header", itemID, strings.Join(allowed, ", "), RegionHeader)
+			}
+			violations = append(violations, Violation{
+				Rule:    Region,
+				ItemID:  itemID,
+				Message: msg,
+			})
+		}
+	}
+
+	if r.MaxLines > 0 && len(items) > r.MaxLines {
+		violations = append(violations, Violation{
+			Rule:    MaxLines,
+			Message: fmt.Sprintf("cart has %d lines, which is more than %d", len(items), r.MaxLines),
+		})
+	}
+
+	if r.MaxValue > 0 && value > r.MaxValue {
+		violations = append(violations, Violation{
+			Rule:    MaxValue,
+			Message: fmt.Sprintf("cart has a value of %.2f, which is more than %.2f", value, r.MaxValue),
+		})
+	}
+
+	for _, group := range r.Incompatible {
+		found := make([]string, 0)
+		for _, itemID := range group {
+			if _, ok := quantities[itemID]; ok {
+				found = append(found, itemID)
+			}
+		}
+		if len(found) > 1 {
+			violations = append(violations, Violation{
+				Rule:    Incompatible,
+				ItemID:  found[len(found)-1],
+				Message: fmt.Sprintf("items %s can't be combined in a cart", strings.Join(found, ", ")),
+			})
+		}
+	}
+
+	return violations
+}
+
+// contains returns true when the region is in the list, ignoring case
+func contains(regions []string, region string) bool {
+	for _, r := range regions {
+		if strings.EqualFold(r, region) {
+			return true
+		}
+	}
+	return false
+}
@@ -7,6 +7,7 @@ import (
 	"path"
 
 	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/apigateway"
+	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/cloudwatch"
 	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/dynamodb"
 	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/iam"
 	"github.com/pulumi/pulumi-aws/sdk/v2/go/aws/lambda"
@@ -48,6 +49,66 @@ type GenericConfig struct {
 
 	// WavefrontToken is your Wavefront API token
 	WavefrontToken string `json:"wavefronttoken"`
+
+	// CatalogURL is the URL of the Catalog service used to validate items
+	CatalogURL string `json:"catalogurl"`
+
+	// MergePolicy is the default policy to merge guest carts into user carts
+	MergePolicy string `json:"mergepolicy"`
+
+	// InviteSecret is the secret used to sign invites to shared carts
+	InviteSecret string `json:"invitesecret"`
+
+	// EventBus is the EventBridge event bus the CartCheckedOut events are sent to
+	EventBus string `json:"eventbus"`
+
+	// CheckoutMode is either clear or archive, to keep the items of checked out carts
+	CheckoutMode string `json:"checkoutmode"`
+
+	// AbandonedAfter is how long a cart needs to be unchanged before it is abandoned
+	AbandonedAfter string `json:"abandonedafter"`
+
+	// EventLog is where changes to carts are logged in the event-sourced mode, or empty to turn it off
+	EventLog string `json:"eventlog"`
+
+	// Rules are the business rules for carts, as YAML or JSON
+	Rules string `json:"rules"`
+
+	// Bundles are the bundles that can be added to carts, as YAML or JSON
+	Bundles string `json:"bundles"`
+
+	// ItemCountPolicy decides how bundles are counted in the number of items (lines or components)
+	ItemCountPolicy string `json:"itemcountpolicy"`
+
+	// IdempotencyTTL is how long idempotency keys are kept
+	IdempotencyTTL string `json:"idempotencyttl"`
+
+	// Inventory is where stock is reserved for the items in carts (dynamodb, or empty to turn it off)
+	Inventory string `json:"inventory"`
+
+	// HoldTTL is how long stock stays reserved for a cart that isn't changed
+	HoldTTL string `json:"holdttl"`
+
+	// JWKSURL is the URL of the keys that JSON Web Tokens are signed with, or empty to turn off authentication
+	JWKSURL string `json:"jwksurl"`
+
+	// UserClaim is the claim of the JSON Web Token with the ID of the user
+	UserClaim string `json:"userclaim"`
+
+	// Issuer is the issuer that JSON Web Tokens must be issued by
+	Issuer string `json:"issuer"`
+
+	// Audience is the audience that JSON Web Tokens must be issued for
+	Audience string `json:"audience"`
+
+	// AdminScope is the scope that makes a user an admin
+	AdminScope string `json:"adminscope"`
+
+	// AdminRole is the role that makes a user an admin
+	AdminRole string `json:"adminrole"`
+
+	// RolesClaim is the claim of the JSON Web Token with the roles of the user
+	RolesClaim string `json:"rolesclaim"`
 }
 
 func main() {
@@ -83,14 +144,37 @@ func main() {
 
 		// functions are the functions that need to be deployed
 		functions := []string{
+			"cart-abandonment",
 			"lambda-cart-additem",
 			"lambda-cart-all",
+			"lambda-cart-checkout",
 			"lambda-cart-clear",
+			"lambda-cart-create",
+			"lambda-cart-delete",
+			"lambda-cart-diff",
+			"lambda-cart-export",
+			"lambda-cart-guest",
 			"lambda-cart-itemmodify",
+			"lambda-cart-itemremove",
 			"lambda-cart-itemtotal",
+			"lambda-cart-join",
+			"lambda-cart-list",
+			"lambda-cart-memberremove",
+			"lambda-cart-members",
+			"lambda-cart-merge",
 			"lambda-cart-modify",
+			"lambda-cart-patch",
+			"lambda-cart-rename",
+			"lambda-cart-reprice",
+			"lambda-cart-restore",
+			"lambda-cart-save",
+			"lambda-cart-saved",
+			"lambda-cart-share",
+			"lambda-cart-summary",
 			"lambda-cart-total",
+			"lambda-cart-undo",
 			"lambda-cart-user",
+			"lambda-cart-versions",
 		}
 
 		// Compile and zip the AWS Lambda functions
@@ -130,6 +214,14 @@ func main() {
 			return err
 		}
 
+		// eventsPolicy adds permissions to send the CartCheckedOut and CartAbandoned
+		// events to Amazon EventBridge
+		iamFactory.AddEventBridgePutEventsPolicy(genericConfig.EventBus)
+		eventsPolicy, err := iamFactory.GetPolicyStatement()
+		if err != nil {
+			return err
+		}
+
 		roles := make(map[string]*iam.Role)
 
 		// Create a new IAM role for each Lambda function
@@ -155,11 +247,16 @@ func main() {
 				return err
 			}
 
-			// Add the DynamoDB policy
+			// Add the DynamoDB policy, and the EventBridge policy for the functions that send events
+			policy := dynamoPolicy
+			if function == "lambda-cart-checkout" || function == "cart-abandonment" {
+				policy = eventsPolicy
+			}
+
 			_, err = iam.NewRolePolicy(ctx, fmt.Sprintf("ACMEServerlessCartPolicy-%s", function), &iam.RolePolicyArgs{
 				Name:   pulumi.String(fmt.Sprintf("ACMEServerlessCartPolicy-%s", function)),
 				Role:   role.Name,
-				Policy: pulumi.String(dynamoPolicy),
+				Policy: pulumi.String(policy),
 			})
 			if err != nil {
 				return err
@@ -179,6 +276,28 @@ func main() {
 		variables["TABLE"] = pulumi.String(dynamoTable.Name)
 		variables["WAVEFRONT_URL"] = pulumi.String(genericConfig.WavefrontURL)
 		variables["WAVEFRONT_API_TOKEN"] = pulumi.String(genericConfig.WavefrontToken)
+		variables["CATALOG_URL"] = pulumi.String(genericConfig.CatalogURL)
+		variables["MERGE_POLICY"] = pulumi.String(genericConfig.MergePolicy)
+		variables["CART_INVITE_SECRET"] = pulumi.String(genericConfig.InviteSecret)
+		variables["EVENT_PUBLISHER"] = pulumi.String("eventbridge")
+		variables["EVENT_BUS"] = pulumi.String(genericConfig.EventBus)
+		variables["CHECKOUT_MODE"] = pulumi.String(genericConfig.CheckoutMode)
+		variables["ABANDONED_AFTER"] = pulumi.String(genericConfig.AbandonedAfter)
+		variables["CART_EVENT_LOG"] = pulumi.String(genericConfig.EventLog)
+		variables["CART_RULES"] = pulumi.String(genericConfig.Rules)
+		variables["CART_BUNDLES"] = pulumi.String(genericConfig.Bundles)
+		variables["ITEM_COUNT_POLICY"] = pulumi.String(genericConfig.ItemCountPolicy)
+		variables["IDEMPOTENCY_TTL"] = pulumi.String(genericConfig.IdempotencyTTL)
+		variables["INVENTORY_BACKEND"] = pulumi.String(genericConfig.Inventory)
+		variables["INVENTORY_TABLE"] = pulumi.String(dynamoTable.Name)
+		variables["INVENTORY_HOLD_TTL"] = pulumi.String(genericConfig.HoldTTL)
+		variables["AUTH_JWKS_URL"] = pulumi.String(genericConfig.JWKSURL)
+		variables["AUTH_USER_CLAIM"] = pulumi.String(genericConfig.UserClaim)
+		variables["AUTH_ISSUER"] = pulumi.String(genericConfig.Issuer)
+		variables["AUTH_AUDIENCE"] = pulumi.String(genericConfig.Audience)
+		variables["AUTH_ADMIN_SCOPE"] = pulumi.String(genericConfig.AdminScope)
+		variables["AUTH_ADMIN_ROLE"] = pulumi.String(genericConfig.AdminRole)
+		variables["AUTH_ROLES_CLAIM"] = pulumi.String(genericConfig.RolesClaim)
 
 		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-cart-additem", ctx.Stack()))
 		environment := lambda.FunctionEnvironmentArgs{
@@ -389,202 +508,1332 @@ func main() {
 
 		ctx.Export("lambda-cart-user::Arn", cartUserFunction.Arn)
 
-		// Create the API Gateway Policy
-		iamFactory.ClearPolicies()
-		iamFactory.AddAssumeRoleLambda()
-		iamFactory.AddExecuteAPI()
-		policies, err := iamFactory.GetPolicyStatement()
+		// Create the Reprice function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-cart-reprice", ctx.Stack()))
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
+
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("A Lambda function to update the prices of items in a cart to the current prices in the catalog"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-lambda-cart-reprice", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(10),
+			Handler:     pulumi.String("lambda-cart-reprice"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/lambda-cart-reprice/lambda-cart-reprice.zip"),
+			Role:        roles["lambda-cart-reprice"].Arn,
+			Tags:        pulumi.Map(tagMap),
+		}
+
+		cartRepriceFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-lambda-cart-reprice", ctx.Stack()), functionArgs)
 		if err != nil {
 			return err
 		}
 
-		// Read the OpenAPI specification
-		bytes, err := ioutil.ReadFile("../api/openapi.json")
+		ctx.Export("lambda-cart-reprice::Arn", cartRepriceFunction.Arn)
+
+		// Create the ItemRemove function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-cart-itemremove", ctx.Stack()))
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
+
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("A Lambda function to remove an item from a cart"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-lambda-cart-itemremove", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(10),
+			Handler:     pulumi.String("lambda-cart-itemremove"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/lambda-cart-itemremove/lambda-cart-itemremove.zip"),
+			Role:        roles["lambda-cart-itemremove"].Arn,
+			Tags:        pulumi.Map(tagMap),
+		}
+
+		cartItemRemoveFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-lambda-cart-itemremove", ctx.Stack()), functionArgs)
 		if err != nil {
 			return err
 		}
 
-		// Create an API Gateway
-		gateway, err := apigateway.NewRestApi(ctx, "CartService", &apigateway.RestApiArgs{
-			Name:        pulumi.String("CartService"),
-			Description: pulumi.String("ACME Serverless Fitness Shop - Cart"),
+		ctx.Export("lambda-cart-itemremove::Arn", cartItemRemoveFunction.Arn)
+
+		// Create the Guest function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-cart-guest", ctx.Stack()))
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
+
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("A Lambda function to create a cart for an anonymous user"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-lambda-cart-guest", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(10),
+			Handler:     pulumi.String("lambda-cart-guest"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/lambda-cart-guest/lambda-cart-guest.zip"),
+			Role:        roles["lambda-cart-guest"].Arn,
 			Tags:        pulumi.Map(tagMap),
-			Policy:      pulumi.String(policies),
-			Body:        pulumi.StringPtr(string(bytes)),
-		})
+		}
+
+		cartGuestFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-lambda-cart-guest", ctx.Stack()), functionArgs)
 		if err != nil {
 			return err
 		}
 
-		gatewayURL := gateway.ID().ToStringOutput().ApplyString(func(id string) string {
-			resource := gw.MustGetGatewayResource(ctx, id, "/cart/item/add/{userid}")
+		ctx.Export("lambda-cart-guest::Arn", cartGuestFunction.Arn)
 
-			i1, err := apigateway.NewIntegration(ctx, "AddItemAPIIntegration", &apigateway.IntegrationArgs{
-				HttpMethod:            pulumi.String("POST"),
-				IntegrationHttpMethod: pulumi.String("POST"),
-				ResourceId:            pulumi.String(resource.Id),
-				RestApi:               gateway.ID(),
-				Type:                  pulumi.String("AWS_PROXY"),
-				Uri:                   cartAddItemFunction.InvokeArn,
-			})
+		// Create the Merge function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-cart-merge", ctx.Stack()))
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
 
-			_, err = lambda.NewPermission(ctx, "AddItemAPIPermission", &lambda.PermissionArgs{
-				Action:    pulumi.String("lambda:InvokeFunction"),
-				Function:  cartAddItemFunction.Name,
-				Principal: pulumi.String("apigateway.amazonaws.com"),
-				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/POST/cart/item/add/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
-			})
-			if err != nil {
-				fmt.Println(err)
-			}
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("A Lambda function to merge the cart of an anonymous user into the cart of a user"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-lambda-cart-merge", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(10),
+			Handler:     pulumi.String("lambda-cart-merge"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/lambda-cart-merge/lambda-cart-merge.zip"),
+			Role:        roles["lambda-cart-merge"].Arn,
+			Tags:        pulumi.Map(tagMap),
+		}
 
-			resource = gw.MustGetGatewayResource(ctx, id, "/cart/all")
+		cartMergeFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-lambda-cart-merge", ctx.Stack()), functionArgs)
+		if err != nil {
+			return err
+		}
 
-			i2, err := apigateway.NewIntegration(ctx, "AllCartsAPIIntegration", &apigateway.IntegrationArgs{
-				HttpMethod:            pulumi.String("GET"),
-				IntegrationHttpMethod: pulumi.String("POST"),
-				ResourceId:            pulumi.String(resource.Id),
-				RestApi:               gateway.ID(),
-				Type:                  pulumi.String("AWS_PROXY"),
-				Uri:                   cartAllFunction.InvokeArn,
-			})
-			if err != nil {
-				fmt.Println(err)
-			}
+		ctx.Export("lambda-cart-merge::Arn", cartMergeFunction.Arn)
 
-			_, err = lambda.NewPermission(ctx, "AllCartsAPIPermission", &lambda.PermissionArgs{
-				Action:    pulumi.String("lambda:InvokeFunction"),
-				Function:  cartAllFunction.Name,
-				Principal: pulumi.String("apigateway.amazonaws.com"),
-				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/GET/cart/all", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
-			})
-			if err != nil {
-				fmt.Println(err)
-			}
+		// Create the List function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-cart-list", ctx.Stack()))
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
 
-			resource = gw.MustGetGatewayResource(ctx, id, "/cart/clear/{userid}")
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("A Lambda function to list the carts of a user"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-lambda-cart-list", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(10),
+			Handler:     pulumi.String("lambda-cart-list"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/lambda-cart-list/lambda-cart-list.zip"),
+			Role:        roles["lambda-cart-list"].Arn,
+			Tags:        pulumi.Map(tagMap),
+		}
 
-			i3, err := apigateway.NewIntegration(ctx, "ClearCartAPIIntegration", &apigateway.IntegrationArgs{
-				HttpMethod:            pulumi.String("GET"),
-				IntegrationHttpMethod: pulumi.String("POST"),
-				ResourceId:            pulumi.String(resource.Id),
-				RestApi:               gateway.ID(),
-				Type:                  pulumi.String("AWS_PROXY"),
-				Uri:                   cartClearFunction.InvokeArn,
-			})
-			if err != nil {
-				fmt.Println(err)
-			}
+		cartListFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-lambda-cart-list", ctx.Stack()), functionArgs)
+		if err != nil {
+			return err
+		}
 
-			_, err = lambda.NewPermission(ctx, "ClearCartAPIPermission", &lambda.PermissionArgs{
-				Action:    pulumi.String("lambda:InvokeFunction"),
-				Function:  cartClearFunction.Name,
-				Principal: pulumi.String("apigateway.amazonaws.com"),
-				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/GET/cart/clear/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
-			})
-			if err != nil {
-				fmt.Println(err)
-			}
+		ctx.Export("lambda-cart-list::Arn", cartListFunction.Arn)
 
-			resource = gw.MustGetGatewayResource(ctx, id, "/cart/item/modify/{userid}")
+		// Create the Create function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-cart-create", ctx.Stack()))
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
 
-			i4, err := apigateway.NewIntegration(ctx, "ItemModifyAPIIntegration", &apigateway.IntegrationArgs{
-				HttpMethod:            pulumi.String("POST"),
-				IntegrationHttpMethod: pulumi.String("POST"),
-				ResourceId:            pulumi.String(resource.Id),
-				RestApi:               gateway.ID(),
-				Type:                  pulumi.String("AWS_PROXY"),
-				Uri:                   cartItemModifyFunction.InvokeArn,
-			})
-			if err != nil {
-				fmt.Println(err)
-			}
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("A Lambda function to create a named cart for a user"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-lambda-cart-create", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(10),
+			Handler:     pulumi.String("lambda-cart-create"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/lambda-cart-create/lambda-cart-create.zip"),
+			Role:        roles["lambda-cart-create"].Arn,
+			Tags:        pulumi.Map(tagMap),
+		}
 
-			_, err = lambda.NewPermission(ctx, "ItemModifyAPIPermission", &lambda.PermissionArgs{
-				Action:    pulumi.String("lambda:InvokeFunction"),
-				Function:  cartItemModifyFunction.Name,
-				Principal: pulumi.String("apigateway.amazonaws.com"),
-				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/POST/cart/item/modify/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
-			})
-			if err != nil {
-				fmt.Println(err)
-			}
+		cartCreateFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-lambda-cart-create", ctx.Stack()), functionArgs)
+		if err != nil {
+			return err
+		}
 
-			resource = gw.MustGetGatewayResource(ctx, id, "/cart/items/total/{userid}")
+		ctx.Export("lambda-cart-create::Arn", cartCreateFunction.Arn)
 
-			i5, err := apigateway.NewIntegration(ctx, "ItemTotalAPIIntegration", &apigateway.IntegrationArgs{
-				HttpMethod:            pulumi.String("GET"),
-				IntegrationHttpMethod: pulumi.String("POST"),
-				ResourceId:            pulumi.String(resource.Id),
-				RestApi:               gateway.ID(),
-				Type:                  pulumi.String("AWS_PROXY"),
-				Uri:                   cartItemTotalFunction.InvokeArn,
-			})
-			if err != nil {
-				fmt.Println(err)
-			}
+		// Create the Rename function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-cart-rename", ctx.Stack()))
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
 
-			_, err = lambda.NewPermission(ctx, "ItemTotalAPIPermission", &lambda.PermissionArgs{
-				Action:    pulumi.String("lambda:InvokeFunction"),
-				Function:  cartItemTotalFunction.Name,
-				Principal: pulumi.String("apigateway.amazonaws.com"),
-				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/POST/cart/items/total/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
-			})
-			if err != nil {
-				fmt.Println(err)
-			}
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("A Lambda function to rename a cart of a user"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-lambda-cart-rename", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(10),
+			Handler:     pulumi.String("lambda-cart-rename"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/lambda-cart-rename/lambda-cart-rename.zip"),
+			Role:        roles["lambda-cart-rename"].Arn,
+			Tags:        pulumi.Map(tagMap),
+		}
 
-			resource = gw.MustGetGatewayResource(ctx, id, "/cart/modify/{userid}")
+		cartRenameFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-lambda-cart-rename", ctx.Stack()), functionArgs)
+		if err != nil {
+			return err
+		}
 
-			i6, err := apigateway.NewIntegration(ctx, "CartModifyAPIIntegration", &apigateway.IntegrationArgs{
-				HttpMethod:            pulumi.String("POST"),
-				IntegrationHttpMethod: pulumi.String("POST"),
-				ResourceId:            pulumi.String(resource.Id),
-				RestApi:               gateway.ID(),
-				Type:                  pulumi.String("AWS_PROXY"),
-				Uri:                   cartModifyFunction.InvokeArn,
-			})
-			if err != nil {
-				fmt.Println(err)
-			}
+		ctx.Export("lambda-cart-rename::Arn", cartRenameFunction.Arn)
 
-			_, err = lambda.NewPermission(ctx, "CartModifyAPIPermission", &lambda.PermissionArgs{
-				Action:    pulumi.String("lambda:InvokeFunction"),
-				Function:  cartModifyFunction.Name,
-				Principal: pulumi.String("apigateway.amazonaws.com"),
-				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/POST/cart/modify/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
-			})
-			if err != nil {
-				fmt.Println(err)
-			}
+		// Create the Delete function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-cart-delete", ctx.Stack()))
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
+
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("A Lambda function to delete a named cart of a user"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-lambda-cart-delete", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(10),
+			Handler:     pulumi.String("lambda-cart-delete"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/lambda-cart-delete/lambda-cart-delete.zip"),
+			Role:        roles["lambda-cart-delete"].Arn,
+			Tags:        pulumi.Map(tagMap),
+		}
+
+		cartDeleteFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-lambda-cart-delete", ctx.Stack()), functionArgs)
+		if err != nil {
+			return err
+		}
+
+		ctx.Export("lambda-cart-delete::Arn", cartDeleteFunction.Arn)
+
+		// Create the Saved function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-cart-saved", ctx.Stack()))
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
+
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("Serverless Cart - Get saved items"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-lambda-cart-saved", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(10),
+			Handler:     pulumi.String("lambda-cart-saved"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/lambda-cart-saved/lambda-cart-saved.zip"),
+			Role:        roles["lambda-cart-saved"].Arn,
+			Tags:        pulumi.Map(tagMap),
+		}
+
+		cartSavedFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-lambda-cart-saved", ctx.Stack()), functionArgs)
+		if err != nil {
+			return err
+		}
+
+		ctx.Export("lambda-cart-saved::Arn", cartSavedFunction.Arn)
+
+		// Create the Save function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-cart-save", ctx.Stack()))
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
+
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("Serverless Cart - Save item for later"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-lambda-cart-save", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(10),
+			Handler:     pulumi.String("lambda-cart-save"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/lambda-cart-save/lambda-cart-save.zip"),
+			Role:        roles["lambda-cart-save"].Arn,
+			Tags:        pulumi.Map(tagMap),
+		}
+
+		cartSaveFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-lambda-cart-save", ctx.Stack()), functionArgs)
+		if err != nil {
+			return err
+		}
+
+		ctx.Export("lambda-cart-save::Arn", cartSaveFunction.Arn)
+
+		// Create the Restore function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-cart-restore", ctx.Stack()))
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
+
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("Serverless Cart - Move saved item to cart"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-lambda-cart-restore", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(10),
+			Handler:     pulumi.String("lambda-cart-restore"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/lambda-cart-restore/lambda-cart-restore.zip"),
+			Role:        roles["lambda-cart-restore"].Arn,
+			Tags:        pulumi.Map(tagMap),
+		}
+
+		cartRestoreFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-lambda-cart-restore", ctx.Stack()), functionArgs)
+		if err != nil {
+			return err
+		}
+
+		ctx.Export("lambda-cart-restore::Arn", cartRestoreFunction.Arn)
+
+		// Create the Share function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-cart-share", ctx.Stack()))
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
+
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("Serverless Cart - Share cart"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-lambda-cart-share", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(10),
+			Handler:     pulumi.String("lambda-cart-share"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/lambda-cart-share/lambda-cart-share.zip"),
+			Role:        roles["lambda-cart-share"].Arn,
+			Tags:        pulumi.Map(tagMap),
+		}
+
+		cartShareFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-lambda-cart-share", ctx.Stack()), functionArgs)
+		if err != nil {
+			return err
+		}
+
+		ctx.Export("lambda-cart-share::Arn", cartShareFunction.Arn)
+
+		// Create the Join function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-cart-join", ctx.Stack()))
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
+
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("Serverless Cart - Join shared cart"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-lambda-cart-join", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(10),
+			Handler:     pulumi.String("lambda-cart-join"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/lambda-cart-join/lambda-cart-join.zip"),
+			Role:        roles["lambda-cart-join"].Arn,
+			Tags:        pulumi.Map(tagMap),
+		}
+
+		cartJoinFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-lambda-cart-join", ctx.Stack()), functionArgs)
+		if err != nil {
+			return err
+		}
+
+		ctx.Export("lambda-cart-join::Arn", cartJoinFunction.Arn)
+
+		// Create the Members function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-cart-members", ctx.Stack()))
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
+
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("Serverless Cart - Get cart members"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-lambda-cart-members", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(10),
+			Handler:     pulumi.String("lambda-cart-members"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/lambda-cart-members/lambda-cart-members.zip"),
+			Role:        roles["lambda-cart-members"].Arn,
+			Tags:        pulumi.Map(tagMap),
+		}
+
+		cartMembersFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-lambda-cart-members", ctx.Stack()), functionArgs)
+		if err != nil {
+			return err
+		}
+
+		ctx.Export("lambda-cart-members::Arn", cartMembersFunction.Arn)
+
+		// Create the MemberRemove function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-cart-memberremove", ctx.Stack()))
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
+
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("Serverless Cart - Remove cart member"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-lambda-cart-memberremove", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(10),
+			Handler:     pulumi.String("lambda-cart-memberremove"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/lambda-cart-memberremove/lambda-cart-memberremove.zip"),
+			Role:        roles["lambda-cart-memberremove"].Arn,
+			Tags:        pulumi.Map(tagMap),
+		}
+
+		cartMemberRemoveFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-lambda-cart-memberremove", ctx.Stack()), functionArgs)
+		if err != nil {
+			return err
+		}
+
+		ctx.Export("lambda-cart-memberremove::Arn", cartMemberRemoveFunction.Arn)
+
+		// Create the Checkout function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-cart-checkout", ctx.Stack()))
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
+
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("ACME Serverless Fitness Shop - Cart - Checkout"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-lambda-cart-checkout", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(10),
+			Handler:     pulumi.String("lambda-cart-checkout"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/lambda-cart-checkout/lambda-cart-checkout.zip"),
+			Role:        roles["lambda-cart-checkout"].Arn,
+			Tags:        pulumi.Map(tagMap),
+		}
+
+		cartCheckoutFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-lambda-cart-checkout", ctx.Stack()), functionArgs)
+		if err != nil {
+			return err
+		}
+
+		ctx.Export("lambda-cart-checkout::Arn", cartCheckoutFunction.Arn)
+
+		// Create the Abandonment function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-cart-abandonment", ctx.Stack()))
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
+
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("ACME Serverless Fitness Shop - Cart - Abandonment"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-cart-abandonment", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(300),
+			Handler:     pulumi.String("cart-abandonment"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/cart-abandonment/cart-abandonment.zip"),
+			Role:        roles["cart-abandonment"].Arn,
+			Tags:        pulumi.Map(tagMap),
+		}
+
+		cartAbandonmentFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-cart-abandonment", ctx.Stack()), functionArgs)
+		if err != nil {
+			return err
+		}
+
+		ctx.Export("cart-abandonment::Arn", cartAbandonmentFunction.Arn)
+
+		// Run the Abandonment function every hour
+		abandonmentRule, err := cloudwatch.NewEventRule(ctx, fmt.Sprintf("%s-cart-abandonment-schedule", ctx.Stack()), &cloudwatch.EventRuleArgs{
+			Description:        pulumi.String("ACME Serverless Fitness Shop - Cart - Abandonment schedule"),
+			ScheduleExpression: pulumi.String("rate(1 hour)"),
+			Tags:               pulumi.Map(tagMap),
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = cloudwatch.NewEventTarget(ctx, fmt.Sprintf("%s-cart-abandonment-target", ctx.Stack()), &cloudwatch.EventTargetArgs{
+			Arn:  cartAbandonmentFunction.Arn,
+			Rule: abandonmentRule.Name,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = lambda.NewPermission(ctx, "CartAbandonmentSchedulePermission", &lambda.PermissionArgs{
+			Action:    pulumi.String("lambda:InvokeFunction"),
+			Function:  cartAbandonmentFunction.Name,
+			Principal: pulumi.String("events.amazonaws.com"),
+			SourceArn: abandonmentRule.Arn,
+		})
+		if err != nil {
+			return err
+		}
+
+		// Create the Versions function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-cart-versions", ctx.Stack()))
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
+
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("ACME Serverless Fitness Shop - Cart - Versions"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-lambda-cart-versions", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(10),
+			Handler:     pulumi.String("lambda-cart-versions"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/lambda-cart-versions/lambda-cart-versions.zip"),
+			Role:        roles["lambda-cart-versions"].Arn,
+			Tags:        pulumi.Map(tagMap),
+		}
+
+		cartVersionsFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-lambda-cart-versions", ctx.Stack()), functionArgs)
+		if err != nil {
+			return err
+		}
+
+		ctx.Export("lambda-cart-versions::Arn", cartVersionsFunction.Arn)
+
+		// Create the Export function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-cart-export", ctx.Stack()))
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
+
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("ACME Serverless Fitness Shop - Cart - Export"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-lambda-cart-export", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(10),
+			Handler:     pulumi.String("lambda-cart-export"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/lambda-cart-export/lambda-cart-export.zip"),
+			Role:        roles["lambda-cart-export"].Arn,
+			Tags:        pulumi.Map(tagMap),
+		}
+
+		cartExportFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-lambda-cart-export", ctx.Stack()), functionArgs)
+		if err != nil {
+			return err
+		}
+
+		ctx.Export("lambda-cart-export::Arn", cartExportFunction.Arn)
+
+		// Create the Diff function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-cart-diff", ctx.Stack()))
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
+
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("ACME Serverless Fitness Shop - Cart - Diff"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-lambda-cart-diff", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(10),
+			Handler:     pulumi.String("lambda-cart-diff"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/lambda-cart-diff/lambda-cart-diff.zip"),
+			Role:        roles["lambda-cart-diff"].Arn,
+			Tags:        pulumi.Map(tagMap),
+		}
+
+		cartDiffFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-lambda-cart-diff", ctx.Stack()), functionArgs)
+		if err != nil {
+			return err
+		}
+
+		ctx.Export("lambda-cart-diff::Arn", cartDiffFunction.Arn)
+
+		// Create the Undo function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-cart-undo", ctx.Stack()))
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
+
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("ACME Serverless Fitness Shop - Cart - Undo"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-lambda-cart-undo", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(10),
+			Handler:     pulumi.String("lambda-cart-undo"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/lambda-cart-undo/lambda-cart-undo.zip"),
+			Role:        roles["lambda-cart-undo"].Arn,
+			Tags:        pulumi.Map(tagMap),
+		}
+
+		cartUndoFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-lambda-cart-undo", ctx.Stack()), functionArgs)
+		if err != nil {
+			return err
+		}
+
+		ctx.Export("lambda-cart-undo::Arn", cartUndoFunction.Arn)
+
+		// Create the Summary function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-cart-summary", ctx.Stack()))
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
+
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("A Lambda function to get the summary of a cart"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-lambda-cart-summary", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(10),
+			Handler:     pulumi.String("lambda-cart-summary"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/lambda-cart-summary/lambda-cart-summary.zip"),
+			Role:        roles["lambda-cart-summary"].Arn,
+			Tags:        pulumi.Map(tagMap),
+		}
+
+		cartSummaryFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-lambda-cart-summary", ctx.Stack()), functionArgs)
+		if err != nil {
+			return err
+		}
+
+		ctx.Export("lambda-cart-summary::Arn", cartSummaryFunction.Arn)
+
+		// Create the Patch function
+		variables["FUNCTION_NAME"] = pulumi.String(fmt.Sprintf("%s-lambda-cart-patch", ctx.Stack()))
+		environment = lambda.FunctionEnvironmentArgs{
+			Variables: pulumi.StringMap(variables),
+		}
+
+		functionArgs = &lambda.FunctionArgs{
+			Description: pulumi.String("A Lambda function to apply a patch to a cart"),
+			Runtime:     pulumi.String("go1.x"),
+			Name:        pulumi.String(fmt.Sprintf("%s-lambda-cart-patch", ctx.Stack())),
+			MemorySize:  pulumi.Int(256),
+			Timeout:     pulumi.Int(10),
+			Handler:     pulumi.String("lambda-cart-patch"),
+			Environment: environment,
+			Code:        pulumi.NewFileArchive("../cmd/lambda-cart-patch/lambda-cart-patch.zip"),
+			Role:        roles["lambda-cart-patch"].Arn,
+			Tags:        pulumi.Map(tagMap),
+		}
+
+		cartPatchFunction, err := lambda.NewFunction(ctx, fmt.Sprintf("%s-lambda-cart-patch", ctx.Stack()), functionArgs)
+		if err != nil {
+			return err
+		}
+
+		ctx.Export("lambda-cart-patch::Arn", cartPatchFunction.Arn)
+
+		// Create the API Gateway Policy
+		iamFactory.ClearPolicies()
+		iamFactory.AddAssumeRoleLambda()
+		iamFactory.AddExecuteAPI()
+		policies, err := iamFactory.GetPolicyStatement()
+		if err != nil {
+			return err
+		}
+
+		// Read the OpenAPI specification
+		bytes, err := ioutil.ReadFile("../api/openapi.json")
+		if err != nil {
+			return err
+		}
+
+		// Create an API Gateway
+		gateway, err := apigateway.NewRestApi(ctx, "CartService", &apigateway.RestApiArgs{
+			Name:        pulumi.String("CartService"),
+			Description: pulumi.String("ACME Serverless Fitness Shop - Cart"),
+			Tags:        pulumi.Map(tagMap),
+			Policy:      pulumi.String(policies),
+			Body:        pulumi.StringPtr(string(bytes)),
+		})
+		if err != nil {
+			return err
+		}
+
+		gatewayURL := gateway.ID().ToStringOutput().ApplyString(func(id string) string {
+			resource := gw.MustGetGatewayResource(ctx, id, "/cart/item/add/{userid}")
+
+			i1, err := apigateway.NewIntegration(ctx, "AddItemAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("POST"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartAddItemFunction.InvokeArn,
+			})
+
+			_, err = lambda.NewPermission(ctx, "AddItemAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartAddItemFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/POST/cart/item/add/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/all")
+
+			i2, err := apigateway.NewIntegration(ctx, "AllCartsAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("GET"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartAllFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "AllCartsAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartAllFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/GET/cart/all", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/clear/{userid}")
+
+			i3, err := apigateway.NewIntegration(ctx, "ClearCartAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("GET"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartClearFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "ClearCartAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartClearFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/GET/cart/clear/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/item/modify/{userid}")
+
+			i4, err := apigateway.NewIntegration(ctx, "ItemModifyAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("POST"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartItemModifyFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "ItemModifyAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartItemModifyFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/POST/cart/item/modify/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/items/total/{userid}")
+
+			i5, err := apigateway.NewIntegration(ctx, "ItemTotalAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("GET"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartItemTotalFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "ItemTotalAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartItemTotalFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/POST/cart/items/total/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/modify/{userid}")
+
+			i6, err := apigateway.NewIntegration(ctx, "CartModifyAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("POST"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartModifyFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "CartModifyAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartModifyFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/POST/cart/modify/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
 
 			resource = gw.MustGetGatewayResource(ctx, id, "/cart/total/{userid}")
 
-			i7, err := apigateway.NewIntegration(ctx, "CartTotalAPIIntegration", &apigateway.IntegrationArgs{
+			i7, err := apigateway.NewIntegration(ctx, "CartTotalAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("GET"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartTotalFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "CartTotalAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartTotalFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/GET/cart/total/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/items/{userid}")
+
+			i8, err := apigateway.NewIntegration(ctx, "CartUserTotalAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("GET"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartUserFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "CartUserTotalAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartUserFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/GET/cart/items/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/reprice/{userid}")
+
+			i9, err := apigateway.NewIntegration(ctx, "CartRepriceAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("POST"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartRepriceFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "CartRepriceAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartRepriceFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/POST/cart/reprice/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/item/remove/{userid}")
+
+			i10, err := apigateway.NewIntegration(ctx, "ItemRemoveAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("POST"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartItemRemoveFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "ItemRemoveAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartItemRemoveFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/POST/cart/item/remove/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/guest")
+
+			i11, err := apigateway.NewIntegration(ctx, "CartGuestAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("POST"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartGuestFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "CartGuestAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartGuestFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/POST/cart/guest", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/merge")
+
+			i12, err := apigateway.NewIntegration(ctx, "CartMergeAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("POST"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartMergeFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "CartMergeAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartMergeFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/POST/cart/merge", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/list/{userid}")
+
+			i13, err := apigateway.NewIntegration(ctx, "CartListAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("GET"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartListFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "CartListAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartListFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/GET/cart/list/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/create/{userid}")
+
+			i14, err := apigateway.NewIntegration(ctx, "CartCreateAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("POST"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartCreateFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "CartCreateAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartCreateFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/POST/cart/create/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/rename/{userid}")
+
+			i15, err := apigateway.NewIntegration(ctx, "CartRenameAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("POST"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartRenameFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "CartRenameAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartRenameFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/POST/cart/rename/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/delete/{userid}")
+
+			i16, err := apigateway.NewIntegration(ctx, "CartDeleteAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("POST"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartDeleteFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "CartDeleteAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartDeleteFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/POST/cart/delete/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/saved/{userid}")
+
+			i17, err := apigateway.NewIntegration(ctx, "CartSavedAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("GET"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartSavedFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "CartSavedAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartSavedFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/GET/cart/saved/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/item/save/{userid}")
+
+			i18, err := apigateway.NewIntegration(ctx, "CartSaveAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("POST"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartSaveFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "CartSaveAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartSaveFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/POST/cart/item/save/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/saved/restore/{userid}")
+
+			i19, err := apigateway.NewIntegration(ctx, "CartRestoreAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("POST"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartRestoreFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "CartRestoreAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartRestoreFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/POST/cart/saved/restore/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/share/{userid}")
+
+			i20, err := apigateway.NewIntegration(ctx, "CartShareAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("POST"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartShareFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "CartShareAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartShareFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/POST/cart/share/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/join/{userid}")
+
+			i21, err := apigateway.NewIntegration(ctx, "CartJoinAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("POST"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartJoinFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "CartJoinAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartJoinFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/POST/cart/join/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/members/{userid}")
+
+			i22, err := apigateway.NewIntegration(ctx, "CartMembersAPIIntegration", &apigateway.IntegrationArgs{
 				HttpMethod:            pulumi.String("GET"),
 				IntegrationHttpMethod: pulumi.String("POST"),
 				ResourceId:            pulumi.String(resource.Id),
 				RestApi:               gateway.ID(),
 				Type:                  pulumi.String("AWS_PROXY"),
-				Uri:                   cartTotalFunction.InvokeArn,
+				Uri:                   cartMembersFunction.InvokeArn,
 			})
 			if err != nil {
 				fmt.Println(err)
 			}
 
-			_, err = lambda.NewPermission(ctx, "CartTotalAPIPermission", &lambda.PermissionArgs{
+			_, err = lambda.NewPermission(ctx, "CartMembersAPIPermission", &lambda.PermissionArgs{
 				Action:    pulumi.String("lambda:InvokeFunction"),
-				Function:  cartTotalFunction.Name,
+				Function:  cartMembersFunction.Name,
 				Principal: pulumi.String("apigateway.amazonaws.com"),
-				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/GET/cart/total/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/GET/cart/members/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
 			})
 			if err != nil {
 				fmt.Println(err)
 			}
 
-			resource = gw.MustGetGatewayResource(ctx, id, "/cart/items/{userid}")
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/members/remove/{userid}")
 
-			i8, err := apigateway.NewIntegration(ctx, "CartUserTotalAPIIntegration", &apigateway.IntegrationArgs{
+			i23, err := apigateway.NewIntegration(ctx, "CartMemberRemoveAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("POST"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartMemberRemoveFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "CartMemberRemoveAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartMemberRemoveFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/POST/cart/members/remove/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/checkout/{userid}")
+
+			i24, err := apigateway.NewIntegration(ctx, "CartCheckoutAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("POST"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartCheckoutFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "CartCheckoutAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartCheckoutFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/POST/cart/checkout/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/versions/{userid}")
+
+			i25, err := apigateway.NewIntegration(ctx, "CartVersionsAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("GET"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartVersionsFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "CartVersionsAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartVersionsFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/GET/cart/versions/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/diff/{userid}")
+
+			i26, err := apigateway.NewIntegration(ctx, "CartDiffAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("GET"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartDiffFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "CartDiffAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartDiffFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/GET/cart/diff/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/undo/{userid}")
+
+			i27, err := apigateway.NewIntegration(ctx, "CartUndoAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("POST"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartUndoFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "CartUndoAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartUndoFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/POST/cart/undo/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/summary/{userid}")
+
+			i28, err := apigateway.NewIntegration(ctx, "CartSummaryAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("GET"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartSummaryFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "CartSummaryAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartSummaryFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/GET/cart/summary/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/v2/carts/{userid}")
+
+			i29, err := apigateway.NewIntegration(ctx, "V2GetCartAPIIntegration", &apigateway.IntegrationArgs{
 				HttpMethod:            pulumi.String("GET"),
 				IntegrationHttpMethod: pulumi.String("POST"),
 				ResourceId:            pulumi.String(resource.Id),
@@ -596,11 +1845,179 @@ func main() {
 				fmt.Println(err)
 			}
 
-			_, err = lambda.NewPermission(ctx, "CartUserTotalAPIPermission", &lambda.PermissionArgs{
+			_, err = lambda.NewPermission(ctx, "V2GetCartAPIPermission", &lambda.PermissionArgs{
 				Action:    pulumi.String("lambda:InvokeFunction"),
 				Function:  cartUserFunction.Name,
 				Principal: pulumi.String("apigateway.amazonaws.com"),
-				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/GET/cart/items/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/GET/v2/carts/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/v2/carts/{userid}")
+
+			i30, err := apigateway.NewIntegration(ctx, "V2PutCartAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("PUT"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartModifyFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "V2PutCartAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartModifyFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/PUT/v2/carts/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/v2/carts/{userid}")
+
+			i31, err := apigateway.NewIntegration(ctx, "V2DeleteCartAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("DELETE"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartClearFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "V2DeleteCartAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartClearFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/DELETE/v2/carts/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/v2/carts/{userid}/items")
+
+			i32, err := apigateway.NewIntegration(ctx, "V2PostItemAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("POST"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartAddItemFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "V2PostItemAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartAddItemFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/POST/v2/carts/*/items", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/v2/carts/{userid}/items/{itemid}")
+
+			i33, err := apigateway.NewIntegration(ctx, "V2PatchItemAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("PATCH"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartItemModifyFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "V2PatchItemAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartItemModifyFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/PATCH/v2/carts/*/items/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/v2/carts/{userid}/items/{itemid}")
+
+			i34, err := apigateway.NewIntegration(ctx, "V2DeleteItemAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("DELETE"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartItemRemoveFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "V2DeleteItemAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartItemRemoveFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/DELETE/v2/carts/*/items/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/v2/carts/{userid}")
+
+			i35, err := apigateway.NewIntegration(ctx, "V2PatchCartAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("PATCH"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartPatchFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "V2PatchCartAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartPatchFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/PATCH/v2/carts/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			resource = gw.MustGetGatewayResource(ctx, id, "/cart/export/{userid}")
+
+			i36, err := apigateway.NewIntegration(ctx, "CartExportAPIIntegration", &apigateway.IntegrationArgs{
+				HttpMethod:            pulumi.String("GET"),
+				IntegrationHttpMethod: pulumi.String("POST"),
+				ResourceId:            pulumi.String(resource.Id),
+				RestApi:               gateway.ID(),
+				Type:                  pulumi.String("AWS_PROXY"),
+				Uri:                   cartExportFunction.InvokeArn,
+			})
+			if err != nil {
+				fmt.Println(err)
+			}
+
+			_, err = lambda.NewPermission(ctx, "CartExportAPIPermission", &lambda.PermissionArgs{
+				Action:    pulumi.String("lambda:InvokeFunction"),
+				Function:  cartExportFunction.Name,
+				Principal: pulumi.String("apigateway.amazonaws.com"),
+				SourceArn: pulumi.Sprintf("arn:aws:execute-api:%s:%s:%s/*/GET/cart/export/*", genericConfig.Region, genericConfig.AccountID, gateway.ID()),
 			})
 			if err != nil {
 				fmt.Println(err)
@@ -612,7 +2029,7 @@ func main() {
 				RestApi:          gateway.ID(),
 				StageDescription: pulumi.String("Prod Stage"),
 				StageName:        pulumi.String("Prod"),
-			}, pulumi.DependsOn([]pulumi.Resource{i1, i2, i3, i4, i5, i6, i7, i8}))
+			}, pulumi.DependsOn([]pulumi.Resource{i1, i2, i3, i4, i5, i6, i7, i8, i9, i10, i11, i12, i13, i14, i15, i16, i17, i18, i19, i20, i21, i22, i23, i24, i25, i26, i27, i28, i29, i30, i31, i32, i33, i34, i35, i36}))
 			if err != nil {
 				fmt.Println(err)
 			}
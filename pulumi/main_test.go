@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// specRoutes returns the method and path of every operation in the OpenAPI specification
+func specRoutes(t *testing.T) map[string]bool {
+	data, err := ioutil.ReadFile("../api/openapi.json")
+	if err != nil {
+		t.Fatalf("error reading OpenAPI specification: %s", err.Error())
+	}
+
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		t.Fatalf("error parsing OpenAPI specification: %s", err.Error())
+	}
+
+	routes := make(map[string]bool)
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			if method == "parameters" {
+				continue
+			}
+			routes[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+	return routes
+}
+
+// gatewayRoutes returns the method and path of every API Gateway integration in main.go.
+// Integrations are created for the resource that was looked up last, so the source is
+// walked in order.
+func gatewayRoutes(t *testing.T) map[string]bool {
+	f, err := parser.ParseFile(token.NewFileSet(), "main.go", nil, 0)
+	if err != nil {
+		t.Fatalf("error parsing main.go: %s", err.Error())
+	}
+
+	routes := make(map[string]bool)
+	resource := ""
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			if sel, ok := n.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "MustGetGatewayResource" && len(n.Args) == 3 {
+				resource = stringLit(t, n.Args[2])
+			}
+		case *ast.CompositeLit:
+			if sel, ok := n.Type.(*ast.SelectorExpr); !ok || sel.Sel.Name != "IntegrationArgs" {
+				return true
+			}
+			for _, elt := range n.Elts {
+				kv, ok := elt.(*ast.KeyValueExpr)
+				if !ok || kv.Key.(*ast.Ident).Name != "HttpMethod" {
+					continue
+				}
+				call, ok := kv.Value.(*ast.CallExpr)
+				if !ok || len(call.Args) != 1 {
+					t.Fatalf("HttpMethod of the integration for %s is not a pulumi.String", resource)
+				}
+				routes[stringLit(t, call.Args[0])+" "+resource] = true
+			}
+		}
+		return true
+	})
+	return routes
+}
+
+// stringLit returns the value of a string literal
+func stringLit(t *testing.T, e ast.Expr) string {
+	lit, ok := e.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		t.Fatalf("expected a string literal, got %T", e)
+	}
+	s, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		t.Fatalf("error reading string literal %s: %s", lit.Value, err.Error())
+	}
+	return s
+}
+
+// diff returns the routes in a that aren't in b, sorted
+func diff(a map[string]bool, b map[string]bool) []string {
+	res := make([]string, 0)
+	for r := range a {
+		if !b[r] {
+			res = append(res, r)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestGatewayMatchesSpec(t *testing.T) {
+	routes := gatewayRoutes(t)
+	spec := specRoutes(t)
+
+	for _, route := range diff(routes, spec) {
+		t.Errorf("integration %s is not in api/openapi.json", route)
+	}
+	for _, route := range diff(spec, routes) {
+		t.Errorf("route %s in api/openapi.json has no integration", route)
+	}
+}